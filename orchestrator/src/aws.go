@@ -2,12 +2,10 @@ package itn_orchestrator
 
 import (
 	"context"
-	"encoding/json"
-	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	logging "github.com/ipfs/go-log/v2"
 )
 
 type AwsContext struct {
@@ -31,34 +29,32 @@ func (awsctx AwsContext) ReadObject(ctx context.Context, key *string) (*s3.GetOb
 	})
 }
 
-func (config AwsContext) iterateSubmissions(ctx context.Context, log logging.StandardLogger, startAfter string, handleAddress func(MiniMetaToBeSaved)) error {
-	resp, err := config.ListObjects(ctx, startAfter, nil)
+// IterateSubmissions reads submissions from the uptime bucket
+func (awsctx AwsContext) IterateSubmissions(config Config, since time.Time, handleAddress func(MiniMetaToBeSaved)) error {
+	ctx, log := config.Ctx, config.Log
+	startAfter := prefixByTime(since)
+	resp, err := awsctx.ListObjects(ctx, startAfter, nil)
 	if err != nil {
 		return err
 	}
 	for {
 		for _, obj := range resp.Contents {
 			name := *obj.Key
-			r, err := config.ReadObject(ctx, obj.Key)
+			r, err := awsctx.ReadObject(ctx, obj.Key)
 			if err != nil {
 				log.Warnf("Error reading submission %s: %v", name, err)
 				continue
 			}
-			var meta MetaToBeSaved
-			d := json.NewDecoder(r.Body)
-			err = d.Decode(&meta)
+			meta, err := decodeSubmission(r.Body)
+			r.Body.Close()
 			if err != nil {
 				log.Warnf("Error decoding submission %s: %v", name, err)
 				continue
 			}
-			colonIx := strings.IndexRune(meta.RemoteAddr, ':')
-			if colonIx < 0 {
-				meta.RemoteAddr = meta.RemoteAddr[:colonIx]
-			}
-			handleAddress(meta.MiniMetaToBeSaved)
+			handleAddress(meta)
 		}
 		if resp.IsTruncated {
-			resp, err = config.ListObjects(ctx, startAfter, resp.NextContinuationToken)
+			resp, err = awsctx.ListObjects(ctx, startAfter, resp.NextContinuationToken)
 			if err != nil {
 				return err
 			}
@@ -67,3 +63,5 @@ func (config AwsContext) iterateSubmissions(ctx context.Context, log logging.Sta
 		}
 	}
 }
+
+var _ SubmissionSource = AwsContext{}
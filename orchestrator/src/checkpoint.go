@@ -0,0 +1,115 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+type PendingActionIO struct {
+	Step   int             `json:"step"`
+	Params json.RawMessage `json:"params"`
+}
+
+// Checkpoint captures the state of an experiment run after a step is
+// completed: number of steps performed, all outputs of the run (including
+// sensitive ones) and the batch action accumulated but not yet executed
+type Checkpoint struct {
+	Step          int               `json:"step"`
+	Outputs       []Output          `json:"outputs"`
+	PendingAction string            `json:"pendingAction,omitempty"`
+	Pending       []PendingActionIO `json:"pending,omitempty"`
+}
+
+func MakeCheckpoint(step int, outCache outCacheT, prevAction BatchAction, actionAccum []ActionIO) Checkpoint {
+	cp := Checkpoint{Step: step, Outputs: outCache.outputs("")}
+	if prevAction != nil {
+		cp.PendingAction = prevAction.Name()
+		for _, aIO := range actionAccum {
+			cp.Pending = append(cp.Pending, PendingActionIO{Step: aIO.Step, Params: aIO.Params})
+		}
+	}
+	return cp
+}
+
+// WriteCheckpoint atomically replaces the checkpoint file.
+// File is only readable by the owner as it may contain private keys.
+func WriteCheckpoint(filename string, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint file: %v", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint file: %v", err)
+	}
+	if err = os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to replace checkpoint file %s: %v", filename, err)
+	}
+	return nil
+}
+
+func LoadCheckpoint(filename string) (cp Checkpoint, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return cp, fmt.Errorf("failed to read checkpoint %s: %v", filename, err)
+	}
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return cp, fmt.Errorf("failed to decode checkpoint %s: %v", filename, err)
+	}
+	return cp, nil
+}
+
+// Restore replays outputs of the checkpoint into the output cache and
+// recreates the pending batch action accumulator
+func (cp Checkpoint) Restore(outCache outCacheT, log logging.StandardLogger) (BatchAction, []ActionIO, error) {
+	for _, output := range cp.Outputs {
+		if output.Scope != "" {
+			return nil, nil, fmt.Errorf("wrong checkpoint: output %s of step %d has scope %s", output.Name, output.Step, output.Scope)
+		}
+		if err := outCache.add(output, nil); err != nil {
+			return nil, nil, fmt.Errorf("wrong checkpoint: %v", err)
+		}
+	}
+	if cp.PendingAction == "" {
+		return nil, nil, nil
+	}
+	batchAction, isBatchAction := actions[cp.PendingAction].(BatchAction)
+	if !isBatchAction {
+		return nil, nil, fmt.Errorf("wrong checkpoint: %s is not a batch action", cp.PendingAction)
+	}
+	actionAccum := make([]ActionIO, len(cp.Pending))
+	for i, p := range cp.Pending {
+		actionAccum[i] = ActionIO{
+			Step:   p.Step,
+			Params: p.Params,
+			Output: outputF(outCache, log, nil, p.Step),
+		}
+	}
+	return batchAction, actionAccum, nil
+}
+
+// SkipCommands reads commands of the steps already performed, comments are not counted
+func SkipCommands(inDecoder *json.Decoder, steps int) error {
+	for step := 0; step < steps; {
+		var commandOrComment CommandOrComment
+		if err := inDecoder.Decode(&commandOrComment); err != nil {
+			return fmt.Errorf("error decoding command for step %d: %v", step, err)
+		}
+		if commandOrComment.command != nil {
+			step++
+		}
+	}
+	return nil
+}
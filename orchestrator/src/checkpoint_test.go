@@ -0,0 +1,51 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointRoundtrip(t *testing.T) {
+	log := logging.Logger("test")
+	outCache := EmptyOutputCache()
+	require.NoError(t, outputF(outCache, log, nil, 0)("key", "EKE1", true, true))
+	require.NoError(t, outputF(outCache, log, nil, 0)("key", "EKE2", true, true))
+	require.NoError(t, outputF(outCache, log, nil, 1)("lastSlot", 42, false, false))
+	accum := []ActionIO{{Step: 2, Params: json.RawMessage(`{"num":3}`)}}
+
+	filename := filepath.Join(t.TempDir(), "checkpoint.json")
+	require.NoError(t, WriteCheckpoint(filename, MakeCheckpoint(3, outCache, FundAction{}, accum)))
+	cp, err := LoadCheckpoint(filename)
+	require.NoError(t, err)
+	require.Equal(t, 3, cp.Step)
+
+	restored := EmptyOutputCache()
+	prevAction, restoredAccum, err := cp.Restore(restored, log)
+	require.NoError(t, err)
+	require.Equal(t, outCache, restored)
+	require.Equal(t, FundAction{}.Name(), prevAction.Name())
+	require.Len(t, restoredAccum, 1)
+	require.Equal(t, 2, restoredAccum[0].Step)
+	require.JSONEq(t, `{"num":3}`, string(restoredAccum[0].Params))
+}
+
+func TestSkipCommands(t *testing.T) {
+	script := strings.Join([]string{
+		`"comment"`,
+		`{"action":"wait","params":{"sec":1}}`,
+		`"another comment"`,
+		`{"action":"wait","params":{"sec":2}}`,
+		`{"action":"wait","params":{"sec":3}}`,
+	}, "\n")
+	decoder := json.NewDecoder(strings.NewReader(script))
+	require.NoError(t, SkipCommands(decoder, 2))
+	var next CommandOrComment
+	require.NoError(t, decoder.Decode(&next))
+	require.NotNil(t, next.command)
+	require.Equal(t, "3", string(next.command.Params["sec"]))
+}
@@ -0,0 +1,267 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Condition compares a value (normally a reference to an output) with another value.
+// Ordering operations compare numbers, arrays and objects are compared by their length.
+// Operations empty and nonEmpty don't use the second value.
+type Condition struct {
+	Value json.RawMessage `json:"value"`
+	Op    string          `json:"op"`
+	Than  json.RawMessage `json:"than,omitempty"`
+}
+
+func (c Condition) validate() error {
+	switch c.Op {
+	case "eq", "ne", "lt", "le", "gt", "ge", "empty", "nonEmpty":
+		return nil
+	}
+	return fmt.Errorf("unknown condition operation %q", c.Op)
+}
+
+// Evaluate resolves values of the condition and compares them
+func (c Condition) Evaluate(resolve func(json.RawMessage) (json.RawMessage, error)) (bool, error) {
+	if err := c.validate(); err != nil {
+		return false, err
+	}
+	value, err := resolveConditionValue(resolve, c.Value)
+	if err != nil {
+		return false, err
+	}
+	switch c.Op {
+	case "empty":
+		return isEmptyValue(value), nil
+	case "nonEmpty":
+		return !isEmptyValue(value), nil
+	}
+	than, err := resolveConditionValue(resolve, c.Than)
+	if err != nil {
+		return false, err
+	}
+	switch c.Op {
+	case "eq":
+		return valuesEqual(value, than), nil
+	case "ne":
+		return !valuesEqual(value, than), nil
+	}
+	a, err := conditionNumber(value)
+	if err != nil {
+		return false, err
+	}
+	b, err := conditionNumber(than)
+	if err != nil {
+		return false, err
+	}
+	switch c.Op {
+	case "lt":
+		return a < b, nil
+	case "le":
+		return a <= b, nil
+	case "gt":
+		return a > b, nil
+	default:
+		return a >= b, nil
+	}
+}
+
+func resolveConditionValue(resolve func(json.RawMessage) (json.RawMessage, error), raw json.RawMessage) (any, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	resolved, err := resolve(raw)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(resolved, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode condition value: %v", err)
+	}
+	return value, nil
+}
+
+func isEmptyValue(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+func conditionNumber(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case []any:
+		return float64(len(v)), nil
+	case map[string]any:
+		return float64(len(v)), nil
+	}
+	return 0, fmt.Errorf("value %v can't be compared as a number", value)
+}
+
+func valuesEqual(a, b any) bool {
+	_, aNum := a.(float64)
+	_, bNum := b.(float64)
+	if aNum != bNum {
+		// Allow comparing length of an array with a number
+		aLen, errA := conditionNumber(a)
+		bLen, errB := conditionNumber(b)
+		return errA == nil && errB == nil && aLen == bLen
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+type RepeatParams struct {
+	// Maximum number of iterations
+	Count int `json:"count,omitempty"`
+	// No iteration is started at or after the slot
+	UntilSlot int `json:"untilSlot,omitempty"`
+	// No iteration is started after the given number of minutes since the step started
+	Minutes int `json:"min,omitempty"`
+	// Condition checked after each iteration, references are resolved as params
+	// of a step following the last step of the iteration
+	Until *Condition `json:"until,omitempty"`
+	Body  []Command  `json:"body"`
+}
+
+// RepeatAction performs its body multiple times, each iteration
+// has a separate scope of outputs
+type RepeatAction struct{}
+
+func (RepeatAction) Name() string { return "repeat" }
+
+func (RepeatAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: RepeatParams{}, Outputs: []OutputSpec{{Name: "iterations", Kind: NumberKind}}}
+}
+
+func (RepeatAction) Nested(params RawParams) ([][]Command, error) {
+	var body []Command
+	if err := json.Unmarshal(params["body"], &body); err != nil {
+		return nil, fmt.Errorf("failed to decode body: %v", err)
+	}
+	return [][]Command{body}, nil
+}
+
+func (RepeatAction) DeferredParams() []string { return []string{"until"} }
+
+// Condition of repeat is resolved as params of a step following the body
+func (RepeatAction) bodyScopedParams() []string { return []string{"until"} }
+
+func (RepeatAction) RunNested(runner NestedRunner, rawParams json.RawMessage, output OutputF) error {
+	var params RepeatParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	if params.Count <= 0 && params.UntilSlot <= 0 && params.Minutes <= 0 && params.Until == nil {
+		return errors.New("one of count, untilSlot, min or until is required")
+	}
+	if params.Until != nil {
+		if err := params.Until.validate(); err != nil {
+			return err
+		}
+	}
+	config := runner.Config
+	var deadline time.Time
+	if params.Minutes > 0 {
+		deadline = time.Now().Add(time.Minute * time.Duration(params.Minutes))
+	}
+	if params.UntilSlot > 0 {
+		slotStart := config.GenesisTimestamp.Add(time.Millisecond * time.Duration(config.SlotDurationMs) * time.Duration(params.UntilSlot))
+		if deadline.IsZero() || slotStart.Before(deadline) {
+			deadline = slotStart
+		}
+	}
+	i := 0
+	for ; params.Count <= 0 || i < params.Count; i++ {
+		if err := config.Ctx.Err(); err != nil {
+			return err
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			config.Log.Infof("Deadline of repeat reached after %d iterations", i)
+			break
+		}
+		suffix := strconv.Itoa(i)
+		if err := runner.Run(suffix, params.Body); err != nil {
+			return fmt.Errorf("iteration %d: %w", i, err)
+		}
+		if params.Until != nil {
+			done, err := params.Until.Evaluate(func(raw json.RawMessage) (json.RawMessage, error) {
+				return runner.ResolveIn(suffix, len(params.Body), raw)
+			})
+			if err != nil {
+				return fmt.Errorf("iteration %d: failed to evaluate condition: %w", i, err)
+			}
+			if done {
+				i++
+				break
+			}
+		}
+	}
+	return output("iterations", i, false, false)
+}
+
+var _ CompositeAction = RepeatAction{}
+
+type IfParams struct {
+	// Condition references are resolved as params of the if step
+	Condition Condition `json:"condition"`
+	Then      []Command `json:"then"`
+	Else      []Command `json:"else,omitempty"`
+}
+
+// IfAction performs either then or else commands depending on the condition
+type IfAction struct{}
+
+func (IfAction) Name() string { return "if" }
+
+func (IfAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: IfParams{}, Outputs: []OutputSpec{{Name: "condition", Kind: BoolKind}}}
+}
+
+func (IfAction) Nested(params RawParams) ([][]Command, error) {
+	var then, else_ []Command
+	if err := json.Unmarshal(params["then"], &then); err != nil {
+		return nil, fmt.Errorf("failed to decode then: %v", err)
+	}
+	if raw, has := params["else"]; has {
+		if err := json.Unmarshal(raw, &else_); err != nil {
+			return nil, fmt.Errorf("failed to decode else: %v", err)
+		}
+	}
+	return [][]Command{then, else_}, nil
+}
+
+func (IfAction) DeferredParams() []string { return []string{"condition"} }
+
+func (IfAction) RunNested(runner NestedRunner, rawParams json.RawMessage, output OutputF) error {
+	var params IfParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	result, err := params.Condition.Evaluate(runner.Resolve)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate condition: %w", err)
+	}
+	if err := output("condition", result, false, false); err != nil {
+		return err
+	}
+	if result {
+		return runner.Run("then", params.Then)
+	}
+	return runner.Run("else", params.Else)
+}
+
+var _ CompositeAction = IfAction{}
@@ -0,0 +1,73 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	resolve := func(raw json.RawMessage) (json.RawMessage, error) { return raw, nil }
+	cases := []struct {
+		cond   string
+		result bool
+	}{
+		{`{"value":["a","b"],"op":"lt","than":3}`, true},
+		{`{"value":["a","b"],"op":"eq","than":2}`, true},
+		{`{"value":false,"op":"eq","than":false}`, true},
+		{`{"value":"x","op":"ne","than":"x"}`, false},
+		{`{"value":4.5,"op":"ge","than":5}`, false},
+		{`{"value":[],"op":"empty"}`, true},
+		{`{"value":{"a":1},"op":"nonEmpty"}`, true},
+	}
+	for _, c := range cases {
+		var cond Condition
+		require.NoError(t, json.Unmarshal([]byte(c.cond), &cond))
+		result, err := cond.Evaluate(resolve)
+		require.NoError(t, err, c.cond)
+		require.Equal(t, c.result, result, c.cond)
+	}
+	_, err := Condition{Value: json.RawMessage(`"x"`), Op: "lt", Than: json.RawMessage(`1`)}.Evaluate(resolve)
+	require.Error(t, err)
+}
+
+func TestRunRepeatAndIf(t *testing.T) {
+	script := strings.Join([]string{
+		`{"action":"join","params":{"group1":["a","b"]}}`,
+		`{"action":"repeat","params":{"count":3,"body":[` +
+			`{"action":"join","params":{"group1":{"type":"output","step":-1,"name":"group"}}}]}}`,
+		`{"action":"repeat","params":{"count":3,"until":{"value":{"type":"output","step":-1,"name":"group"},"op":"ge","than":2},"body":[` +
+			`{"action":"join","params":{"group1":{"type":"output","step":-2,"name":"group"}}}]}}`,
+		`{"action":"if","params":{"condition":{"value":{"type":"output","step":0,"name":"group"},"op":"lt","than":2},` +
+			`"then":[{"action":"join","params":{"group1":["c"]}}],"else":[{"action":"join","params":{"group1":["d"]}}]}}`,
+	}, "\n")
+	log := logging.Logger("test")
+	config := Config{Ctx: context.Background(), Log: log}
+	outCache := EmptyOutputCache()
+	var prevAction BatchAction
+	var actionAccum []ActionIO
+	err := RunActions(json.NewDecoder(strings.NewReader(script)), config, outCache, log, 0,
+		func() error { return nil }, &actionAccum, ResolutionConfig{OutputCache: outCache}, &prevAction, nil)
+	require.NoError(t, err)
+
+	lookup := func(scope string, step int, name string) []string {
+		entry, has := outCache.lookup(scope, step, name)
+		require.True(t, has, "%s %d %s", scope, step, name)
+		res := make([]string, len(entry.Values))
+		for i, v := range entry.Values {
+			res[i] = string(v)
+		}
+		return res
+	}
+	require.Equal(t, []string{"3"}, lookup("", 1, "iterations"))
+	require.Equal(t, []string{`"a"`, `"b"`}, lookup("1.2", 0, "group"))
+	require.Equal(t, []string{"1"}, lookup("", 2, "iterations"))
+	require.Equal(t, []string{"false"}, lookup("", 3, "condition"))
+	require.Equal(t, []string{`"d"`}, lookup("3.else", 0, "group"))
+	_, has := outCache.lookup("3.then", 0, "group")
+	require.False(t, has)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/ed25519"
 	"encoding/json"
+	"math/rand"
 	"time"
 
 	"github.com/Khan/genqlient/graphql"
@@ -42,25 +43,42 @@ type NodeEntry struct {
 }
 
 type Config struct {
-	Ctx                context.Context
-	AwsContext         *AwsContext
-	OnlineURL          string
-	Sk                 ed25519.PrivateKey
-	Log                logging.StandardLogger
-	MinaExec           string
-	NodeData           map[NodeAddress]NodeEntry
-	SlotDurationMs     int
-	GenesisTimestamp   time.Time
-	ControlExec        string
-	StopDaemonDelaySec int
-	FundDaemonPorts    []string
-	UrlOverrides       []string
-	PrintRequests      bool
+	Ctx         context.Context
+	Submissions SubmissionSource
+	// Only submissions made within the window are used for discovery
+	SubmissionsLookback time.Duration
+	Sk                  ed25519.PrivateKey
+	Log                 logging.StandardLogger
+	MinaExec            string
+	Nodes               *NodeRegistry
+	SlotDurationMs      int
+	GenesisTimestamp    time.Time
+	ControlExec         string
+	StopDaemonDelaySec  int
+	FundDaemonPorts     []string
+	UrlOverrides        []string
+	PrintRequests       bool
+	// Receipts of scheduled transactions to be stopped if the experiment is aborted
+	Receipts *ReceiptTracker
+	// Gating configurations applied to nodes and the topology journal
+	Gating       *GatingTracker
+	Retry        RetryConfig
+	InternalLogs *InternalLogsCollector
+	Scheduling   SchedulingConfig
+	// Probe of node status used by wait-ready, wait-ready fails when not set
+	Readiness ReadinessProbe
+	// Faults injected into nodes to be reverted
+	Faults *FaultTracker
+	// Executors of fault kinds, control exec is used for kinds not listed
+	FaultExecutors map[FaultKind]FaultExecutor
+	// Source of randomness of actions, seeded by the seed of the orchestrator config
+	Rand *rand.Rand
 }
 
 type OutputF = func(name string, value any, multiple bool, sensitive bool) error
 
 type ActionIO struct {
+	Step   int
 	Params json.RawMessage
 	Output OutputF
 }
@@ -70,8 +88,54 @@ type Action interface {
 	Name() string
 }
 
+// CompositeAction is an action that performs lists of nested commands
+type CompositeAction interface {
+	Name() string
+	// Nested returns lists of commands that might be performed, params may contain unresolved references
+	Nested(params RawParams) ([][]Command, error)
+	// DeferredParams returns names of params passed to RunNested unresolved,
+	// the action resolves them itself using the runner
+	DeferredParams() []string
+	RunNested(runner NestedRunner, params json.RawMessage, output OutputF) error
+}
+
 type BatchAction interface {
 	Action
 	RunMany(config Config, actionIOs []ActionIO) error
 	Validate(params json.RawMessage) error
 }
+
+// ValueKind is a JSON kind of a value used in static checks of scripts
+type ValueKind string
+
+const (
+	AnyKind    ValueKind = ""
+	StringKind ValueKind = "string"
+	NumberKind ValueKind = "number"
+	BoolKind   ValueKind = "bool"
+	ArrayKind  ValueKind = "array"
+	ObjectKind ValueKind = "object"
+)
+
+type OutputSpec struct {
+	Name string
+	// Kind of a single value, multi outputs are resolved to arrays of values of this kind
+	Kind  ValueKind
+	Multi bool
+	// Indexed outputs are named by the name followed by a number (e.g. group1, group2)
+	Indexed bool
+}
+
+type ActionSchema struct {
+	// Value of the type params are unmarshalled to
+	Params any
+	// Outputs the action may produce
+	Outputs []OutputSpec
+}
+
+// DeclaredAction is an action that declares its schema for static script checks.
+// Params passed to Schema may contain unresolved references.
+type DeclaredAction interface {
+	Action
+	Schema(params RawParams) ActionSchema
+}
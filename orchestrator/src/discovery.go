@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -31,11 +32,26 @@ type DiscoveryParams struct {
 	OnlyBlockProducers bool `json:"onlyBPs,omitempty"`
 	NoBlockProducers   bool `json:"noBPs,omitempty"`
 	Exactly            bool `json:"exactly,omitempty"`
+	// Output a node record for every discovered node in addition to its address
+	Records bool `json:"records,omitempty"`
+}
+
+// NodeRecord describes a discovered node
+type NodeRecord struct {
+	Address NodeAddress `json:"address"`
+	// Base58check-encoded public key of the submitter
+	Submitter       string `json:"submitter"`
+	PeerId          string `json:"peerId"`
+	Libp2pPort      uint16 `json:"libp2pPort"`
+	IsBlockProducer bool   `json:"isBlockProducer"`
+	// Remote address of the submission
+	RemoteAddr string `json:"remoteAddr"`
 }
 
 type nodeAddrEntry struct {
 	addr  NodeAddress
 	entry NodeEntry
+	meta  MiniMetaToBeSaved
 	isNew bool
 }
 
@@ -44,53 +60,34 @@ type nodeAddrEntriesAndCount struct {
 	count   int
 }
 
-// retryGetURL attempts to retrieve the content of a URL up to maxAttempts times
-// with a delay between each retry, then decode received JSON into an array of MiniMetaToBeSaved
-func retryGetURL(ctx context.Context, log logging.StandardLogger, url string, maxAttempts int, delay time.Duration) (contents []MiniMetaToBeSaved, err error) {
-	// Attempt to get the URL content up to maxAttempts times
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
+// retryGetURL attempts to retrieve the content of a URL according to the retry
+// policy, then decode received JSON into an array of MiniMetaToBeSaved
+func retryGetURL(ctx context.Context, log logging.StandardLogger, url string, policy RetryPolicy) (contents []MiniMetaToBeSaved, err error) {
+	err = retry(ctx, log, policy, "get "+url, func(int) error {
 		resp, err := http.Get(url)
-		if err == nil {
-			var body []byte
-			// Read the response body
-			body, err = io.ReadAll(resp.Body)
-			_ = resp.Body.Close() // Close the response body
-			if err == nil {
-				err = json.Unmarshal(body, &contents)
-			}
-			if err == nil {
-				break // Exit the loop if successful
-			}
+		if err != nil {
+			return err
 		}
-
-		log.Warnf("Attempt %d failed: %v\n", attempt, err)
-		if attempt < maxAttempts {
-			log.Warnf("Retrying in %v...\n", delay)
-			select {
-			case <-ctx.Done(): // If the context is cancelled,
-				return nil, ctx.Err()
-			case <-time.After(delay):
-			}
+		// Read the response body
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close() // Close the response body
+		if err != nil {
+			return err
 		}
-	}
+		if resp.StatusCode != http.StatusOK {
+			return &httpStatusError{code: resp.StatusCode, err: fmt.Errorf("unexpected status %s", resp.Status)}
+		}
+		return json.Unmarshal(body, &contents)
+	})
 	return
 }
 
 func (config *Config) iterateSubmissions(handler func(MiniMetaToBeSaved)) error {
-	if config.AwsContext != nil {
-		before := time.Now().Add(-15 * time.Minute)
-		startAfter := prefixByTime(before)
-		return config.AwsContext.iterateSubmissions(config.Ctx, config.Log, startAfter, handler)
-	} else {
-		submissions, err := retryGetURL(config.Ctx, config.Log, config.OnlineURL, 5, time.Minute)
-		if err != nil {
-			return err
-		}
-		for _, meta := range submissions {
-			handler(meta)
-		}
-		return nil
+	lookback := config.SubmissionsLookback
+	if lookback == 0 {
+		lookback = defaultSubmissionsLookback
 	}
+	return config.Submissions.IterateSubmissions(*config, time.Now().Add(-lookback), handler)
 }
 
 func (config *Config) nodeAddress(remoteAddr string, controlPort uint16) NodeAddress {
@@ -104,7 +101,7 @@ func (config *Config) nodeAddress(remoteAddr string, controlPort uint16) NodeAdd
 	return NodeAddress(remoteAddr + ":" + strconv.Itoa(int(controlPort)))
 }
 
-func discoverParticipantsDo(config Config, params DiscoveryParams, output func(NodeAddress)) error {
+func discoverParticipantsDo(config Config, params DiscoveryParams, output func(NodeRecord)) error {
 	log := config.Log
 	// This function has the following concurrency architecture:
 	// 1. There is a goroutine that reads discovered nodes and outputs them, at the end
@@ -123,10 +120,16 @@ func discoverParticipantsDo(config Config, params DiscoveryParams, output func(N
 		// immediately after they're discovered
 		entries := make([]nodeAddrEntry, 0)
 		cnt := 0
+		bpCnt, nonBpCnt := 0, 0
 		for p := range connected {
 			if p.isNew {
 				entries = append(entries, p)
 			}
+			if p.entry.IsBlockProducer {
+				bpCnt++
+			} else {
+				nonBpCnt++
+			}
 			if p.entry.IsBlockProducer && params.NoBlockProducers {
 				continue
 			}
@@ -134,19 +137,28 @@ func discoverParticipantsDo(config Config, params DiscoveryParams, output func(N
 				continue
 			}
 			if params.Limit <= 0 || cnt < params.Limit {
-				output(p.addr)
+				output(NodeRecord{
+					Address:         p.addr,
+					Submitter:       p.meta.Submitter,
+					PeerId:          p.entry.PeerId,
+					Libp2pPort:      p.entry.Libp2pPort,
+					IsBlockProducer: p.entry.IsBlockProducer,
+					RemoteAddr:      p.meta.RemoteAddr,
+				})
 			}
 			cnt++
 		}
+		discoveredNodes.WithLabelValues("bp").Set(float64(bpCnt))
+		discoveredNodes.WithLabelValues("non_bp").Set(float64(nonBpCnt))
 		connectedResultChan <- nodeAddrEntriesAndCount{entries: entries, count: cnt}
 	}()
-	tryToConnect := func(pk string, addr NodeAddress) {
+	tryToConnect := func(meta MiniMetaToBeSaved, addr NodeAddress) {
 		defer wg.Done()
 		entry, err := NewGqlClient(config, addr)
 		if err == nil {
-			connected <- nodeAddrEntry{addr: addr, entry: *entry, isNew: true}
+			connected <- nodeAddrEntry{addr: addr, entry: *entry, meta: meta, isNew: true}
 		} else {
-			log.Warnf("Error on auth for %s (%s): %v", addr, pk, err)
+			log.Warnf("Error on auth for %s (%s): %v", addr, meta.Submitter, err)
 		}
 	}
 	connecting := make(map[NodeAddress]struct{})
@@ -155,11 +167,11 @@ func discoverParticipantsDo(config Config, params DiscoveryParams, output func(N
 		addr := config.nodeAddress(meta.RemoteAddr, meta.GraphqlControlPort)
 		if _, has := connecting[addr]; !has {
 			connecting[addr] = struct{}{}
-			if entry, has := config.NodeData[addr]; has {
-				connected <- nodeAddrEntry{addr: addr, entry: entry, isNew: false}
+			if entry, has := config.Nodes.Lookup(addr); has {
+				connected <- nodeAddrEntry{addr: addr, entry: entry, meta: meta, isNew: false}
 			} else {
 				wg.Add(1)
-				go tryToConnect(meta.Submitter, addr)
+				go tryToConnect(meta, addr)
 			}
 		}
 	})
@@ -167,7 +179,7 @@ func discoverParticipantsDo(config Config, params DiscoveryParams, output func(N
 	close(connected)
 	connectedResult := <-connectedResultChan
 	for _, p := range connectedResult.entries {
-		config.NodeData[p.addr] = p.entry
+		config.Nodes.Set(p.addr, p.entry)
 	}
 	if connectedResult.count < params.Limit && params.Exactly {
 		return errors.New("failed to discover the exact number of nodes")
@@ -178,24 +190,10 @@ func discoverParticipantsDo(config Config, params DiscoveryParams, output func(N
 	return nil
 }
 
-func DiscoverParticipants(config Config, params DiscoveryParams, output func(NodeAddress)) (err error) {
-	for retryPause := 10; retryPause <= 40; retryPause = retryPause * 2 {
-		select {
-		case <-config.Ctx.Done():
-			return
-		default:
-		}
-
-		err = discoverParticipantsDo(config, params, output)
-		if err == nil {
-			return
-		}
-		if retryPause <= 20 {
-			config.Log.Warnf("Failed to discover participants, retrying in %d minutes: %s", retryPause, err)
-			time.Sleep(time.Duration(retryPause) * time.Minute)
-		}
-	}
-	return
+func DiscoverParticipants(config Config, params DiscoveryParams, output func(NodeRecord)) error {
+	return retry(config.Ctx, config.Log, config.Retry.discovery(), "discover participants", func(int) error {
+		return discoverParticipantsDo(config, params, output)
+	})
 }
 
 type DiscoveryAction struct{}
@@ -205,11 +203,22 @@ func (DiscoveryAction) Run(config Config, rawParams json.RawMessage, output Outp
 	if err := json.Unmarshal(rawParams, &params); err != nil {
 		return err
 	}
-	return DiscoverParticipants(config, params, func(addr NodeAddress) {
-		output("participant", addr, true, false)
+	return DiscoverParticipants(config, params, func(node NodeRecord) {
+		output("participant", node.Address, true, false)
+		if params.Records {
+			output("node", node, true, false)
+		}
 	})
 }
 
 func (DiscoveryAction) Name() string { return "discovery" }
 
-var _ Action = DiscoveryAction{}
+func (DiscoveryAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: DiscoveryParams{}, Outputs: []OutputSpec{
+		{Name: "participant", Kind: StringKind, Multi: true},
+		// Only produced with records parameter set
+		{Name: "node", Kind: ObjectKind, Multi: true},
+	}}
+}
+
+var _ DeclaredAction = DiscoveryAction{}
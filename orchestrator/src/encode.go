@@ -2,6 +2,7 @@ package itn_orchestrator
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"strings"
 )
@@ -10,20 +11,38 @@ func fund(p FundParams) GeneratedCommand {
 	return GeneratedCommand{Action: FundAction{}.Name(), Params: p}
 }
 
-func Encode(p *GenParams, writeCommand func(GeneratedCommand), writeComment func(string)) {
-
-	writeComment("Generated with: " + strings.Join(os.Args, " "))
-	if p.ZkappSoftLimit > -2 {
-		writeCommand(Discovery(DiscoveryParams{}))
-		writeComment(fmt.Sprintf("Setting zkapp soft limit to %d", p.ZkappSoftLimit))
-		writeCommand(ZkappSoftLimit(-1, "participant", p.ZkappSoftLimit))
+// assignFundKeys assigns every fund command to the least loaded window of perCmd
+// consecutive funding keys (out of n), so that rounds with different requirements
+// are spread evenly over funding keys. Returns starting indices of windows
+// and amounts funded from every key.
+func assignFundKeys(fundCmds []FundParams, n, perCmd int) ([]int, []uint64) {
+	amounts := make([]uint64, n)
+	starts := make([]int, len(fundCmds))
+	for i, f := range fundCmds {
+		itemsPerFundKey := f.Num/perCmd + 1
+		perKey := f.Amount / uint64(f.Num) * uint64(itemsPerFundKey)
+		best, bestLoad := 0, uint64(math.MaxUint64)
+		for start := 0; start < n; start++ {
+			load := uint64(0)
+			for j := start; j < start+perCmd; j++ {
+				load = max(load, amounts[j%n])
+			}
+			if load < bestLoad {
+				best, bestLoad = start, load
+			}
+		}
+		starts[i] = best
+		for j := best; j < best+perCmd; j++ {
+			amounts[j%n] += perKey
+		}
 	}
-	cmds := []GeneratedCommand{}
+	return starts, amounts
+}
+
+// fundCommands returns fund commands of the rounds
+func fundCommands(rounds []GeneratedRound) []FundParams {
 	fundCmds := []FundParams{}
-	writeComment("Funding keys for the experiment")
-	for r := 0; r < p.Rounds; r++ {
-		round := p.Generate(r)
-		cmds = append(cmds, round.Commands...)
+	for _, round := range rounds {
 		if round.PaymentFundCommand != nil {
 			fundCmds = append(fundCmds, *round.PaymentFundCommand)
 		}
@@ -31,47 +50,79 @@ func Encode(p *GenParams, writeCommand func(GeneratedCommand), writeComment func
 			fundCmds = append(fundCmds, *round.ZkappFundCommand)
 		}
 	}
+	return fundCmds
+}
+
+// planFunding assigns funding private keys to fund commands of rounds.
+// Returns command generating funding keys (nil if private keys are used directly)
+// and fund commands with private keys set.
+func planFunding(p *GenParams, fundCmds []FundParams) (*FundParams, []FundParams) {
 	privkeys := p.Privkeys
+	nPrivkeys := len(privkeys)
+	if p.GenerateFundKeys > 0 {
+		nPrivkeys = p.GenerateFundKeys
+	}
+	starts, privkeyAmounts := assignFundKeys(fundCmds, nPrivkeys, p.PrivkeysPerFundCmd)
+	var genFundKeys *FundParams
 	if p.GenerateFundKeys > 0 {
 		fundKeysDir := fmt.Sprintf("%s/%s", p.FundKeyPrefix, p.ExperimentName)
 		privkeys = make([]string, p.GenerateFundKeys)
-		privkeyAmounts := make([]uint64, p.GenerateFundKeys)
 		for i := range privkeys {
 			privkeys[i] = fmt.Sprintf("%s/key-0-%d", fundKeysDir, i)
 		}
-		for i, f := range fundCmds {
-			i_ := (i * p.PrivkeysPerFundCmd) % p.GenerateFundKeys
-			itemsPerFundKey := f.Num/p.PrivkeysPerFundCmd + 1
-			perGeneratedKey := f.Amount / uint64(f.Num) * uint64(itemsPerFundKey)
-			for j := i_; j < (i_ + p.PrivkeysPerFundCmd); j++ {
-				j_ := j % p.GenerateFundKeys
-				privkeyAmounts[j_] += perGeneratedKey
-			}
-		}
 		perKeyAmount := privkeyAmounts[0]
 		for _, a := range privkeyAmounts[1:] {
 			if perKeyAmount < a {
 				perKeyAmount = a
 			}
 		}
-		// Generate funding keys
-		writeCommand(fund(FundParams{
+		genFundKeys = &FundParams{
 			PasswordEnv: p.PasswordEnv,
 			Privkeys:    p.Privkeys,
 			Prefix:      fundKeysDir + "/key",
 			Amount:      perKeyAmount*uint64(p.GenerateFundKeys)*3/2 + 2e9,
 			Fee:         p.FundFee,
 			Num:         p.GenerateFundKeys,
-		}))
-		writeCommand(GenWait(1))
+		}
 	}
 	privkeysExt := append(privkeys, privkeys...)
+	res := make([]FundParams, len(fundCmds))
 	for i, cmd := range fundCmds {
-		i_ := (i * p.PrivkeysPerFundCmd) % len(privkeys)
-		cmd.Privkeys = privkeysExt[i_:(i_ + p.PrivkeysPerFundCmd)]
+		cmd.Privkeys = privkeysExt[starts[i]:(starts[i] + p.PrivkeysPerFundCmd)]
+		res[i] = cmd
+	}
+	return genFundKeys, res
+}
+
+// Encode generates the script and returns the plan it was rendered from,
+// random seed is chosen and set to params if none is set
+func Encode(p *GenParams, writeCommand func(GeneratedCommand), writeComment func(string)) *ExperimentPlan {
+	plan := p.Plan()
+	plan.Encode(writeCommand, writeComment)
+	return plan
+}
+
+// Encode renders the script of the plan
+func (plan *ExperimentPlan) Encode(writeCommand func(GeneratedCommand), writeComment func(string)) {
+	writeComment("Generated with: " + strings.Join(os.Args, " "))
+	writeComment(fmt.Sprintf("Seed: %d", plan.Seed))
+	if plan.ZkappSoftLimit > -2 {
+		writeCommand(Discovery(DiscoveryParams{}))
+		writeComment(fmt.Sprintf("Setting zkapp soft limit to %d", plan.ZkappSoftLimit))
+		writeCommand(ZkappSoftLimit(-1, "participant", plan.ZkappSoftLimit))
+	}
+	writeComment("Funding keys for the experiment")
+	if plan.FundKeysCommand != nil {
+		// Generate funding keys
+		writeCommand(fund(*plan.FundKeysCommand))
+		writeCommand(GenWait(1))
+	}
+	for _, cmd := range plan.FundCommands {
 		writeCommand(fund(cmd))
 	}
-	for _, cmd := range cmds {
-		writeCommand(cmd)
+	for _, round := range plan.Rounds {
+		for _, cmd := range round.Commands {
+			writeCommand(cmd)
+		}
 	}
 }
@@ -0,0 +1,116 @@
+package itn_orchestrator
+
+import (
+	"math"
+)
+
+type StopEstimate struct {
+	StopEvent
+	// Number of nodes affected by the event, if the number of nodes is known
+	Nodes int `json:"nodes,omitempty"`
+}
+
+type RoundEstimate struct {
+	Round int `json:"round"`
+	// Start of the round, minutes after start of the experiment
+	StartMin    int `json:"startMin"`
+	DurationMin int `json:"durationMin"`
+	PauseMin    int `json:"pauseMin"`
+	// Numbers of transactions sent within the round
+	Payments int `json:"payments"`
+	Zkapps   int `json:"zkapps"`
+	// Average rates of transactions over the round, txs/min
+	PaymentsRate   float64        `json:"paymentsRate"`
+	ZkappRate      float64        `json:"zkappRate"`
+	PaymentKeys    int            `json:"paymentKeys"`
+	ZkappKeys      int            `json:"zkappKeys"`
+	PaymentFunding uint64         `json:"paymentFunding"`
+	ZkappFunding   uint64         `json:"zkappFunding"`
+	Load           []LoadWindow   `json:"load"`
+	Stops          []StopEstimate `json:"stops"`
+}
+
+type ExperimentEstimate struct {
+	Seed   int64           `json:"seed"`
+	Rounds []RoundEstimate `json:"rounds"`
+	// Wall-clock duration of the experiment excluding funding, minutes
+	DurationMin int `json:"durationMin"`
+	// Number of funding keys generated for the experiment (zero if private keys are used directly)
+	FundKeys int `json:"fundKeys"`
+	// Total amount (including fees) to be spent from the private keys provided
+	TotalFunding uint64 `json:"totalFunding"`
+	Payments     int    `json:"payments"`
+	Zkapps       int    `json:"zkapps"`
+	StopEvents   int    `json:"stopEvents"`
+	// Total number of node disruptions, if the number of nodes is known
+	DisruptedNodes int `json:"disruptedNodes,omitempty"`
+}
+
+func fundingCost(f FundParams) uint64 {
+	return f.Amount + f.Fee*uint64(f.Num)
+}
+
+// Estimate generates the experiment and summarizes its cost and schedule,
+// random seed is chosen and set to params if none is set.
+// When nodes is positive, numbers of nodes affected by stop events are
+// estimated for a network of that many nodes (block producers if only
+// block producers are stopped), assuming senders are drawn from the same nodes.
+func Estimate(p *GenParams, nodes int) ExperimentEstimate {
+	return p.Plan().Estimate(nodes)
+}
+
+// Estimate summarizes cost and schedule of the planned experiment, see Estimate
+func (plan *ExperimentPlan) Estimate(nodes int) ExperimentEstimate {
+	res := ExperimentEstimate{Seed: plan.Seed, Rounds: make([]RoundEstimate, len(plan.Rounds))}
+	for r, round := range plan.Rounds {
+		est := RoundEstimate{
+			Round:       round.Round,
+			StartMin:    round.StartMin,
+			DurationMin: round.DurationMin,
+			PauseMin:    round.PauseMin + round.LargePauseMin,
+			Load:        round.Load,
+			Stops:       make([]StopEstimate, len(round.Stops)),
+		}
+		for _, w := range round.Load {
+			est.Payments += w.Payments()
+			est.Zkapps += w.Zkapps()
+			est.PaymentsRate += w.PaymentTps * 60 * float64(w.DurationMin)
+			est.ZkappRate += w.ZkappTps * 60 * float64(w.DurationMin)
+		}
+		if round.DurationMin > 0 {
+			est.PaymentsRate /= float64(round.DurationMin)
+			est.ZkappRate /= float64(round.DurationMin)
+		}
+		if f := round.PaymentFundCommand; f != nil {
+			est.PaymentKeys = f.Num
+			est.PaymentFunding = f.Amount
+		}
+		if f := round.ZkappFundCommand; f != nil {
+			est.ZkappKeys = f.Num
+			est.ZkappFunding = f.Amount
+		}
+		// Nodes not sending transactions are disrupted
+		candidates := nodes - int(math.Round(round.SenderRatio*float64(nodes)))
+		for i, event := range round.Stops {
+			est.Stops[i].StopEvent = event
+			for _, d := range event.Disruptions {
+				est.Stops[i].Nodes += int(math.Round(d.Ratio * float64(candidates)))
+			}
+			res.DisruptedNodes += est.Stops[i].Nodes
+		}
+		res.Payments += est.Payments
+		res.Zkapps += est.Zkapps
+		res.StopEvents += len(round.Stops)
+		res.DurationMin = max(res.DurationMin, round.StartMin+round.DurationMin)
+		res.Rounds[r] = est
+	}
+	if plan.FundKeysCommand != nil {
+		res.FundKeys = plan.FundKeysCommand.Num
+		res.TotalFunding = fundingCost(*plan.FundKeysCommand)
+	} else {
+		for _, f := range plan.FundCommands {
+			res.TotalFunding += fundingCost(f)
+		}
+	}
+	return res
+}
@@ -0,0 +1,92 @@
+package itn_orchestrator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimate(t *testing.T) {
+	p := someParams()
+	p.Seed = 7
+	p.Rounds = 10
+	p.StopsPerRound = 2
+	p.FaultRatios = map[FaultKind]float64{LatencyFault: 0.2}
+	p.FaultDurationMin = 5
+	est := Estimate(&p, 100)
+	require.Equal(t, int64(7), est.Seed)
+	require.Len(t, est.Rounds, 10)
+	require.Equal(t, 20, est.StopEvents)
+	require.Equal(t, 8*60+240, est.Rounds[8].StartMin)
+	require.Equal(t, 10+240, est.Rounds[7].PauseMin)
+	require.Zero(t, est.Rounds[9].PauseMin)
+	require.Equal(t, est.Rounds[9].StartMin+50, est.DurationMin)
+	payments, zkapps, disrupted := 0, 0, 0
+	for r, round := range est.Rounds {
+		if r%2 == 1 {
+			// Max-cost rounds send only zkapps
+			require.Zero(t, round.Payments)
+			require.Zero(t, round.PaymentKeys)
+		} else {
+			require.Positive(t, round.Payments)
+			require.Positive(t, round.PaymentKeys)
+		}
+		require.Positive(t, round.Zkapps)
+		require.Positive(t, round.ZkappFunding)
+		require.InDelta(t, float64(round.Zkapps)/50, round.ZkappRate, 0.1)
+		payments += round.Payments
+		zkapps += round.Zkapps
+		for _, stop := range round.Stops {
+			require.Less(t, stop.AtSec, 50*60)
+			kinds := []DisruptionKind{}
+			nodes := 0
+			for _, d := range stop.Disruptions {
+				kinds = append(kinds, d.Kind)
+				// 20 nodes out of 100 are not senders
+				nodes += int(math.Round(d.Ratio * 20))
+			}
+			require.Equal(t, []DisruptionKind{StopCleanDisruption, StopDisruption, DisruptionKind(LatencyFault)}, kinds)
+			require.Equal(t, nodes, stop.Nodes)
+			disrupted += nodes
+		}
+	}
+	require.Equal(t, payments, est.Payments)
+	require.Equal(t, zkapps, est.Zkapps)
+	require.Equal(t, disrupted, est.DisruptedNodes)
+
+	// Estimate is consistent with the script generated with the same seed
+	q := someParams()
+	q.Seed = 7
+	q.Rounds = 10
+	q.StopsPerRound = 2
+	q.FaultRatios = map[FaultKind]float64{LatencyFault: 0.2}
+	q.FaultDurationMin = 5
+	var fundCmds []FundParams
+	scheduled := 0
+	Encode(&q, func(cmd GeneratedCommand) {
+		switch params := cmd.Params.(type) {
+		case FundParams:
+			fundCmds = append(fundCmds, params)
+		case PaymentRefParams:
+			scheduled += int(params.Tps * float64(params.DurationMin) * 60)
+		case ZkappRefParams:
+			scheduled += int(params.Tps * float64(params.DurationMin) * 60)
+		}
+	}, func(string) {})
+	require.Equal(t, est.Payments+est.Zkapps, scheduled)
+	require.Equal(t, 20, est.FundKeys)
+	require.Equal(t, fundCmds[0].Amount+fundCmds[0].Fee*20, est.TotalFunding)
+	require.Equal(t, est, Estimate(&q, 100))
+
+	// Without generated funding keys, fund commands are paid from private keys directly
+	q.GenerateFundKeys = 0
+	est = Estimate(&q, 0)
+	require.Zero(t, est.FundKeys)
+	require.Zero(t, est.DisruptedNodes)
+	total := uint64(0)
+	for _, f := range fundCmds[1:] {
+		total += f.Amount + f.Fee*uint64(f.Num)
+	}
+	require.Equal(t, total, est.TotalFunding)
+}
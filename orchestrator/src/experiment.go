@@ -0,0 +1,410 @@
+package itn_orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"itn_json_types"
+
+	"gopkg.in/yaml.v3"
+)
+
+const ExperimentFileVersion = 1
+
+// RoundSettings are settings of a round that can be overridden by templates, phases and per round
+type RoundSettings struct {
+	BaseTps             *float64       `json:"baseTps,omitempty"`
+	StressTps           *float64       `json:"stressTps,omitempty"`
+	MinTps              *float64       `json:"minTps,omitempty"`
+	SenderRatio         *float64       `json:"senderRatio,omitempty"`
+	ZkappRatio          *float64       `json:"zkappRatio,omitempty"`
+	NewAccountRatio     *float64       `json:"newAccountRatio,omitempty"`
+	MaxCost             *bool          `json:"maxCost,omitempty"`
+	MaxCostMixed        *float64       `json:"maxCostMixed,omitempty"`
+	Gap                 *int           `json:"gap,omitempty"`
+	NoLoad              *bool          `json:"noLoad,omitempty"`
+	Stops               *int           `json:"stops,omitempty"`
+	MinStopRatio        *float64       `json:"minStopRatio,omitempty"`
+	MaxStopRatio        *float64       `json:"maxStopRatio,omitempty"`
+	StopCleanRatio      *float64       `json:"stopCleanRatio,omitempty"`
+	StopOnlyBps         *bool          `json:"stopOnlyBps,omitempty"`
+	WaitReadyTimeoutMin *int           `json:"waitReadyTimeoutMin,omitempty"`
+	LoadProfile         *LoadProfile   `json:"loadProfile,omitempty"`
+	Faults              *FaultSchedule `json:"faults,omitempty"`
+}
+
+// RoundOverride overrides settings of rounds in range [FromRound, ToRound)
+type RoundOverride struct {
+	FromRound, ToRound int
+	RoundSettings
+}
+
+func (s *RoundSettings) apply(p *GenParams) {
+	SetOrDefault(s.BaseTps, &p.BaseTps, p.BaseTps)
+	SetOrDefault(s.StressTps, &p.StressTps, p.StressTps)
+	SetOrDefault(s.MinTps, &p.MinTps, p.MinTps)
+	SetOrDefault(s.SenderRatio, &p.SenderRatio, p.SenderRatio)
+	SetOrDefault(s.ZkappRatio, &p.ZkappRatio, p.ZkappRatio)
+	SetOrDefault(s.NewAccountRatio, &p.NewAccountRatio, p.NewAccountRatio)
+	SetOrDefault(s.MaxCost, &p.MaxCost, p.MaxCost)
+	SetOrDefault(s.MaxCostMixed, &p.MixMaxCostTpsRatio, p.MixMaxCostTpsRatio)
+	SetOrDefault(s.Gap, &p.Gap, p.Gap)
+	SetOrDefault(s.NoLoad, &p.NoLoad, p.NoLoad)
+	SetOrDefault(s.Stops, &p.StopsPerRound, p.StopsPerRound)
+	SetOrDefault(s.MinStopRatio, &p.MinStopRatio, p.MinStopRatio)
+	SetOrDefault(s.MaxStopRatio, &p.MaxStopRatio, p.MaxStopRatio)
+	SetOrDefault(s.StopCleanRatio, &p.StopCleanRatio, p.StopCleanRatio)
+	SetOrDefault(s.StopOnlyBps, &p.StopOnlyBps, p.StopOnlyBps)
+	SetOrDefault(s.WaitReadyTimeoutMin, &p.WaitReadyTimeoutMin, p.WaitReadyTimeoutMin)
+	SetOrDefault(s.LoadProfile, &p.LoadProfile, p.LoadProfile)
+	if s.Faults != nil {
+		p.FaultRatios = s.Faults.Ratios
+		p.FaultDurationMin = s.Faults.DurationMin
+	}
+}
+
+// roundParams returns params of the round with its template and overrides applied
+func (p *GenParams) roundParams(round int) *GenParams {
+	res := *p
+	res.RoundOverrides = nil
+	res.RoundTemplates = nil
+	if len(p.RoundTemplates) > 0 {
+		p.RoundTemplates[round%len(p.RoundTemplates)].apply(&res)
+	}
+	for _, o := range p.RoundOverrides {
+		if round >= o.FromRound && round < o.ToRound {
+			o.apply(&res)
+		}
+	}
+	return &res
+}
+
+// RoundTemplate is a named set of round settings. Settings of a predefined
+// template with the same name (if any) are applied before the template's settings.
+type RoundTemplate struct {
+	Name string `json:"name"`
+	RoundSettings
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+var PredefinedRoundTemplates = map[string]RoundSettings{
+	"payments-warmup": {ZkappRatio: ptr(0.0), Stops: ptr(0)},
+	"zkapp-heavy":     {ZkappRatio: ptr(0.9)},
+	"max-cost-burst":  {MaxCost: ptr(true), MaxCostMixed: ptr(0.0), ZkappRatio: ptr(1.0), NewAccountRatio: ptr(0.0)},
+	"churn-only":      {NoLoad: ptr(true)},
+	"new-accounts":    {ZkappRatio: ptr(1.0), NewAccountRatio: ptr(1.0)},
+}
+
+func (t *RoundTemplate) apply(p *GenParams) {
+	if predefined, has := PredefinedRoundTemplates[t.Name]; has {
+		predefined.apply(p)
+	}
+	t.RoundSettings.apply(p)
+}
+
+type FaultSchedule struct {
+	// Ratios of disruptions performed by injecting faults of the kinds, instead of stops
+	Ratios      map[FaultKind]float64 `json:"ratios"`
+	DurationMin int                   `json:"durationMin"`
+}
+
+type ExperimentSchedule struct {
+	Rounds           int `json:"rounds"`
+	RoundDurationMin int `json:"roundDurationMin"`
+	PauseMin         int `json:"pauseMin"`
+	LargePauseMin    int `json:"largePauseMin"`
+	LargePauseEvery  int `json:"largePauseEvery"`
+}
+
+type ExperimentLoad struct {
+	BaseTps         float64     `json:"baseTps"`
+	StressTps       float64     `json:"stressTps"`
+	MinTps          float64     `json:"minTps"`
+	SenderRatio     float64     `json:"senderRatio"`
+	ZkappRatio      float64     `json:"zkappRatio"`
+	NewAccountRatio float64     `json:"newAccountRatio"`
+	SendFromNonBps  bool        `json:"sendFromNonBps"`
+	MaxCost         bool        `json:"maxCost"`
+	MaxCostMixed    float64     `json:"maxCostMixed"`
+	Gap             int         `json:"gap"`
+	ZkappSoftLimit  int         `json:"zkappSoftLimit"`
+	Profile         LoadProfile `json:"profile"`
+	Steps           int         `json:"steps"`
+	PeriodMin       int         `json:"periodMin"`
+	SpikeMin        int         `json:"spikeMin"`
+}
+
+type ExperimentTransactions struct {
+	PaymentReceiver  itn_json_types.MinaPublicKey `json:"paymentReceiver"`
+	PaymentAmount    uint64                       `json:"paymentAmount"`
+	MinPaymentFee    uint64                       `json:"minPaymentFee"`
+	MaxPaymentFee    uint64                       `json:"maxPaymentFee"`
+	MinZkappFee      uint64                       `json:"minZkappFee"`
+	MaxZkappFee      uint64                       `json:"maxZkappFee"`
+	DeploymentFee    uint64                       `json:"deploymentFee"`
+	MinBalanceChange uint64                       `json:"minBalanceChange"`
+	MaxBalanceChange uint64                       `json:"maxBalanceChange"`
+}
+
+type ExperimentStops struct {
+	PerRound            int     `json:"perRound"`
+	MinRatio            float64 `json:"minRatio"`
+	MaxRatio            float64 `json:"maxRatio"`
+	CleanRatio          float64 `json:"cleanRatio"`
+	OnlyBps             bool    `json:"onlyBps"`
+	UseRestartScript    bool    `json:"useRestartScript"`
+	WaitReadyTimeoutMin int     `json:"waitReadyTimeoutMin"`
+}
+
+type ExperimentPartitions struct {
+	Count       int `json:"count"`
+	DurationMin int `json:"durationMin"`
+}
+
+type ExperimentRotation struct {
+	Keys        []string `json:"keys"`
+	Servers     []string `json:"servers"`
+	Ratio       float64  `json:"ratio"`
+	Permutation bool     `json:"permutation"`
+}
+
+type ExperimentFunding struct {
+	// Private key files to fund the experiment from
+	Privkeys        []string `json:"privkeys"`
+	KeysDir         string   `json:"keysDir"`
+	PasswordEnv     string   `json:"passwordEnv"`
+	PrivkeysPerFund int      `json:"privkeysPerFund"`
+	GenerateKeys    int      `json:"generateKeys"`
+	Fee             uint64   `json:"fee"`
+}
+
+// ExperimentPhase is a number of consecutive rounds sharing settings
+type ExperimentPhase struct {
+	Name   string `json:"name,omitempty"`
+	Rounds int    `json:"rounds"`
+	RoundSettings
+}
+
+type ExperimentRoundOverride struct {
+	Round int `json:"round"`
+	RoundSettings
+}
+
+// ExperimentFile is a declarative definition of an experiment.
+// Rounds cycle through templates (if any) and are split into phases (if any).
+// Settings of a template override settings of the experiment, settings of a phase
+// override settings of a template and per-round overrides override settings of a phase.
+type ExperimentFile struct {
+	Version      int                       `json:"version"`
+	Name         string                    `json:"name"`
+	Seed         int64                     `json:"seed,omitempty"`
+	Schedule     ExperimentSchedule        `json:"schedule"`
+	Load         ExperimentLoad            `json:"load"`
+	Transactions ExperimentTransactions    `json:"transactions"`
+	Stops        ExperimentStops           `json:"stops"`
+	Partitions   ExperimentPartitions      `json:"partitions"`
+	Faults       FaultSchedule             `json:"faults"`
+	Rotation     ExperimentRotation        `json:"rotation"`
+	Funding      ExperimentFunding         `json:"funding"`
+	Templates    []RoundTemplate           `json:"templates,omitempty"`
+	Phases       []ExperimentPhase         `json:"phases,omitempty"`
+	Overrides    []ExperimentRoundOverride `json:"overrides,omitempty"`
+}
+
+// NewExperimentFile describes params as an experiment file, round overrides and templates are omitted
+func NewExperimentFile(p GenParams) ExperimentFile {
+	return ExperimentFile{
+		Version: ExperimentFileVersion,
+		Name:    p.ExperimentName,
+		Seed:    p.Seed,
+		Schedule: ExperimentSchedule{
+			Rounds:           p.Rounds,
+			RoundDurationMin: p.RoundDurationMin,
+			PauseMin:         p.PauseMin,
+			LargePauseMin:    p.LargePauseMin,
+			LargePauseEvery:  p.LargePauseEveryNRounds,
+		},
+		Load: ExperimentLoad{
+			BaseTps:         p.BaseTps,
+			StressTps:       p.StressTps,
+			MinTps:          p.MinTps,
+			SenderRatio:     p.SenderRatio,
+			ZkappRatio:      p.ZkappRatio,
+			NewAccountRatio: p.NewAccountRatio,
+			SendFromNonBps:  p.SendFromNonBpsOnly,
+			MaxCost:         p.MaxCost,
+			MaxCostMixed:    p.MixMaxCostTpsRatio,
+			Gap:             p.Gap,
+			ZkappSoftLimit:  p.ZkappSoftLimit,
+			Profile:         p.LoadProfile,
+			Steps:           p.LoadSteps,
+			PeriodMin:       p.LoadPeriodMin,
+			SpikeMin:        p.LoadSpikeMin,
+		},
+		Transactions: ExperimentTransactions{
+			PaymentReceiver:  p.PaymentReceiver,
+			PaymentAmount:    p.PaymentAmount,
+			MinPaymentFee:    p.MinPaymentFee,
+			MaxPaymentFee:    p.MaxPaymentFee,
+			MinZkappFee:      p.MinZkappFee,
+			MaxZkappFee:      p.MaxZkappFee,
+			DeploymentFee:    p.DeploymentFee,
+			MinBalanceChange: p.MinBalanceChange,
+			MaxBalanceChange: p.MaxBalanceChange,
+		},
+		Stops: ExperimentStops{
+			PerRound:            p.StopsPerRound,
+			MinRatio:            p.MinStopRatio,
+			MaxRatio:            p.MaxStopRatio,
+			CleanRatio:          p.StopCleanRatio,
+			OnlyBps:             p.StopOnlyBps,
+			UseRestartScript:    p.UseRestartScript,
+			WaitReadyTimeoutMin: p.WaitReadyTimeoutMin,
+		},
+		Partitions: ExperimentPartitions{Count: p.Partitions, DurationMin: p.PartitionMin},
+		Faults:     FaultSchedule{Ratios: p.FaultRatios, DurationMin: p.FaultDurationMin},
+		Rotation: ExperimentRotation{
+			Keys:        p.RotationKeys,
+			Servers:     p.RotationServers,
+			Ratio:       p.RotationRatio,
+			Permutation: p.RotationPermutation,
+		},
+		Funding: ExperimentFunding{
+			Privkeys:        p.Privkeys,
+			KeysDir:         p.FundKeyPrefix,
+			PasswordEnv:     p.PasswordEnv,
+			PrivkeysPerFund: p.PrivkeysPerFundCmd,
+			GenerateKeys:    p.GenerateFundKeys,
+			Fee:             p.FundFee,
+		},
+	}
+}
+
+// GenParams converts the experiment file to generator params
+func (f *ExperimentFile) GenParams() (GenParams, error) {
+	if f.Version != ExperimentFileVersion {
+		return GenParams{}, fmt.Errorf("unsupported experiment file version %d, expected %d", f.Version, ExperimentFileVersion)
+	}
+	p := GenParams{
+		ExperimentName:         f.Name,
+		Seed:                   f.Seed,
+		Rounds:                 f.Schedule.Rounds,
+		RoundDurationMin:       f.Schedule.RoundDurationMin,
+		PauseMin:               f.Schedule.PauseMin,
+		LargePauseMin:          f.Schedule.LargePauseMin,
+		LargePauseEveryNRounds: f.Schedule.LargePauseEvery,
+		BaseTps:                f.Load.BaseTps,
+		StressTps:              f.Load.StressTps,
+		MinTps:                 f.Load.MinTps,
+		SenderRatio:            f.Load.SenderRatio,
+		ZkappRatio:             f.Load.ZkappRatio,
+		NewAccountRatio:        f.Load.NewAccountRatio,
+		SendFromNonBpsOnly:     f.Load.SendFromNonBps,
+		MaxCost:                f.Load.MaxCost,
+		MixMaxCostTpsRatio:     f.Load.MaxCostMixed,
+		Gap:                    f.Load.Gap,
+		ZkappSoftLimit:         f.Load.ZkappSoftLimit,
+		LoadProfile:            f.Load.Profile,
+		LoadSteps:              f.Load.Steps,
+		LoadPeriodMin:          f.Load.PeriodMin,
+		LoadSpikeMin:           f.Load.SpikeMin,
+		PaymentReceiver:        f.Transactions.PaymentReceiver,
+		PaymentAmount:          f.Transactions.PaymentAmount,
+		MinPaymentFee:          f.Transactions.MinPaymentFee,
+		MaxPaymentFee:          f.Transactions.MaxPaymentFee,
+		MinZkappFee:            f.Transactions.MinZkappFee,
+		MaxZkappFee:            f.Transactions.MaxZkappFee,
+		DeploymentFee:          f.Transactions.DeploymentFee,
+		MinBalanceChange:       f.Transactions.MinBalanceChange,
+		MaxBalanceChange:       f.Transactions.MaxBalanceChange,
+		StopsPerRound:          f.Stops.PerRound,
+		MinStopRatio:           f.Stops.MinRatio,
+		MaxStopRatio:           f.Stops.MaxRatio,
+		StopCleanRatio:         f.Stops.CleanRatio,
+		StopOnlyBps:            f.Stops.OnlyBps,
+		UseRestartScript:       f.Stops.UseRestartScript,
+		WaitReadyTimeoutMin:    f.Stops.WaitReadyTimeoutMin,
+		Partitions:             f.Partitions.Count,
+		PartitionMin:           f.Partitions.DurationMin,
+		FaultRatios:            f.Faults.Ratios,
+		FaultDurationMin:       f.Faults.DurationMin,
+		RotationKeys:           f.Rotation.Keys,
+		RotationServers:        f.Rotation.Servers,
+		RotationRatio:          f.Rotation.Ratio,
+		RotationPermutation:    f.Rotation.Permutation,
+		Privkeys:               f.Funding.Privkeys,
+		FundKeyPrefix:          f.Funding.KeysDir,
+		PasswordEnv:            f.Funding.PasswordEnv,
+		PrivkeysPerFundCmd:     f.Funding.PrivkeysPerFund,
+		GenerateFundKeys:       f.Funding.GenerateKeys,
+		FundFee:                f.Funding.Fee,
+		RoundTemplates:         f.Templates,
+	}
+	if len(f.Phases) > 0 {
+		// Number of rounds is defined by phases
+		p.Rounds = 0
+		for _, phase := range f.Phases {
+			if phase.Rounds <= 0 {
+				return GenParams{}, fmt.Errorf("phase %s should have a positive number of rounds", phase.Name)
+			}
+			p.RoundOverrides = append(p.RoundOverrides, RoundOverride{
+				FromRound:     p.Rounds,
+				ToRound:       p.Rounds + phase.Rounds,
+				RoundSettings: phase.RoundSettings,
+			})
+			p.Rounds += phase.Rounds
+		}
+	}
+	for _, o := range f.Overrides {
+		if o.Round < 0 || o.Round >= p.Rounds {
+			return GenParams{}, fmt.Errorf("override of round %d is out of range of %d rounds", o.Round, p.Rounds)
+		}
+		p.RoundOverrides = append(p.RoundOverrides, RoundOverride{
+			FromRound:     o.Round,
+			ToRound:       o.Round + 1,
+			RoundSettings: o.RoundSettings,
+		})
+	}
+	return p, nil
+}
+
+// ParseExperimentFile parses the experiment file in YAML or JSON format,
+// settings missing from the file are taken from default generator params
+func ParseExperimentFile(data []byte) (ExperimentFile, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return ExperimentFile{}, fmt.Errorf("failed to parse experiment file: %v", err)
+	}
+	// Field names are defined by JSON tags
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return ExperimentFile{}, fmt.Errorf("failed to parse experiment file: %v", err)
+	}
+	res := NewExperimentFile(DefaultGenParams())
+	// Version should be provided explicitly
+	res.Version = 0
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&res); err != nil {
+		return ExperimentFile{}, fmt.Errorf("failed to parse experiment file: %v", err)
+	}
+	return res, nil
+}
+
+// LoadExperimentFile reads the experiment file and converts it to generator params
+func LoadExperimentFile(filename string) (GenParams, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return GenParams{}, err
+	}
+	f, err := ParseExperimentFile(data)
+	if err != nil {
+		return GenParams{}, err
+	}
+	return f.GenParams()
+}
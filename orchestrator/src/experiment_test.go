@@ -0,0 +1,186 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testExperimentYaml = `
+version: 1
+name: ramp-up
+seed: 7
+schedule:
+  roundDurationMin: 30
+  pauseMin: 5
+load:
+  baseTps: 0.5
+  stressTps: 1
+  zkappRatio: 0.5
+transactions:
+  paymentReceiver: B62qpPita1s7Dbnr7MVb3UK8fdssZixL1a4536aeMYxbTJEtRGGyS8U
+stops:
+  perRound: 2
+  maxRatio: 0.3
+faults:
+  ratios: {latency: 0.5}
+  durationMin: 5
+funding:
+  generateKeys: 0
+  privkeysPerFund: 1
+phases:
+  - name: warmup
+    rounds: 2
+    stops: 0
+    zkappRatio: 0
+  - name: stress
+    rounds: 3
+    baseTps: 2
+    stressTps: 3
+overrides:
+  - round: 4
+    zkappRatio: 1
+    faults:
+      ratios: {pause: 1}
+      durationMin: 2
+`
+
+func testPrivkeyFile(t *testing.T) string {
+	file := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(file, nil, 0600))
+	return file
+}
+
+func TestExperimentFile(t *testing.T) {
+	f, err := ParseExperimentFile([]byte(testExperimentYaml))
+	require.NoError(t, err)
+	p, err := f.GenParams()
+	require.NoError(t, err)
+	require.Equal(t, "ramp-up", p.ExperimentName)
+	require.Equal(t, int64(7), p.Seed)
+	require.Equal(t, 5, p.Rounds)
+	// Settings missing from the file are defaults
+	require.Equal(t, DefaultGenParams().Gap, p.Gap)
+	require.Equal(t, 0.3, p.MaxStopRatio)
+	p.Privkeys = []string{testPrivkeyFile(t)}
+	require.Empty(t, ValidateAndCollectErrors(&p))
+
+	warmup, stress, last := p.roundParams(1), p.roundParams(2), p.roundParams(4)
+	require.Equal(t, 0, warmup.StopsPerRound)
+	require.Equal(t, 0.0, warmup.ZkappRatio)
+	require.Equal(t, 0.5, warmup.BaseTps)
+	require.Equal(t, 2.0, stress.BaseTps)
+	require.Equal(t, 2, stress.StopsPerRound)
+	require.Equal(t, 2.0, last.BaseTps)
+	require.Equal(t, 1.0, last.ZkappRatio)
+	require.Equal(t, map[FaultKind]float64{PauseFault: 1}, last.FaultRatios)
+
+	actionsOf := func(round GeneratedRound) map[string]int {
+		res := map[string]int{}
+		for _, cmd := range round.Commands {
+			res[cmd.Action]++
+		}
+		return res
+	}
+	first := actionsOf(p.Generate(0))
+	require.Zero(t, first["zkapp-txs"])
+	require.Equal(t, 1, first["payments"])
+	require.Zero(t, first["inject-fault"])
+	fifth := actionsOf(p.Generate(4))
+	require.Zero(t, fifth["payments"])
+	require.Equal(t, 2, fifth["inject-fault"])
+	requireValidGeneratedScript(t, p)
+
+	// Same document is accepted in JSON
+	var doc any
+	require.NoError(t, json.Unmarshal([]byte(`{"version":1,"name":"x","load":{"baseTps":0.1},"overrides":[{"round":1,"stops":0}]}`), &doc))
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	f, err = ParseExperimentFile(data)
+	require.NoError(t, err)
+	p, err = f.GenParams()
+	require.NoError(t, err)
+	require.Equal(t, 0.1, p.BaseTps)
+	require.Equal(t, []RoundOverride{{FromRound: 1, ToRound: 2, RoundSettings: RoundSettings{Stops: new(int)}}}, p.RoundOverrides)
+}
+
+func TestExperimentFileErrors(t *testing.T) {
+	for _, doc := range []string{
+		`{"name":"no-version"}`,
+		`{"version":2,"name":"x"}`,
+		`{"version":1,"load":{"bastTps":1}}`,
+		`{"version":1,"overrides":[{"round":4}]}`,
+		`{"version":1,"phases":[{"rounds":0}]}`,
+	} {
+		f, err := ParseExperimentFile([]byte(doc))
+		if err == nil {
+			_, err = f.GenParams()
+		}
+		require.Error(t, err, doc)
+	}
+
+	// Overridden settings are validated
+	f, err := ParseExperimentFile([]byte(`{"version":1,"transactions":{"paymentReceiver":"B62qpPita1s7Dbnr7MVb3UK8fdssZixL1a4536aeMYxbTJEtRGGyS8U"},"overrides":[{"round":1,"zkappRatio":2}]}`))
+	require.NoError(t, err)
+	p, err := f.GenParams()
+	require.NoError(t, err)
+	p.Privkeys = []string{testPrivkeyFile(t)}
+	require.Equal(t, []string{"wrong round templates or overrides: templates should be predefined or define settings, overrides should be within rounds of the experiment and overridden settings should be valid"}, ValidateAndCollectErrors(&p))
+}
+
+func TestExperimentFileOfParams(t *testing.T) {
+	f := NewExperimentFile(DefaultGenParams())
+	p, err := f.GenParams()
+	require.NoError(t, err)
+	require.Equal(t, DefaultGenParams(), p)
+}
+
+func TestRoundTemplates(t *testing.T) {
+	p := someParams()
+	p.Rounds = 3
+	p.StopsPerRound = 2
+	p.MixMaxCostTpsRatio = 0
+	p.ZkappSoftLimit = -2
+	p.Privkeys = []string{testPrivkeyFile(t)}
+	p.RoundTemplates = []RoundTemplate{
+		{Name: "payments-warmup"},
+		{Name: "churn-only", RoundSettings: RoundSettings{Stops: ptr(3)}},
+		{Name: "max-cost-burst"},
+	}
+	require.Empty(t, ValidateAndCollectErrors(&p))
+	requireValidGeneratedScript(t, p)
+
+	actionsOf := func(round GeneratedRound) map[string]int {
+		res := map[string]int{}
+		for _, cmd := range round.Commands {
+			res[cmd.Action]++
+			if params, ok := cmd.Params.(ZkappRefParams); ok {
+				require.True(t, params.MaxCost)
+			}
+		}
+		return res
+	}
+	warmup := p.Generate(0)
+	require.Equal(t, map[string]int{"discovery": 1, "sample": 1, "load-keys": 1, "payments": 1, "wait": 2}, actionsOf(warmup))
+	require.Nil(t, warmup.ZkappFundCommand)
+	churn := p.Generate(1)
+	require.Equal(t, map[string]int{"discovery": 4, "sample": 3, "stop-daemon": 6, "wait": 5}, actionsOf(churn))
+	require.Nil(t, churn.PaymentFundCommand)
+	require.Nil(t, churn.ZkappFundCommand)
+	burst := actionsOf(p.Generate(2))
+	require.Equal(t, 1, burst["zkapp-txs"])
+	require.Zero(t, burst["payments"])
+
+	p.RoundTemplates = append(p.RoundTemplates, RoundTemplate{Name: "chrun-only"})
+	require.Equal(t, []string{"wrong round templates or overrides: templates should be predefined or define settings, overrides should be within rounds of the experiment and overridden settings should be valid"}, ValidateAndCollectErrors(&p))
+}
+
+func TestAssignFundKeys(t *testing.T) {
+	cmds := []FundParams{{Amount: 900, Num: 9}, {Amount: 90, Num: 9}, {Amount: 90, Num: 9}, {Amount: 90, Num: 9}}
+	starts, amounts := assignFundKeys(cmds, 2, 1)
+	require.Equal(t, []int{0, 1, 1, 1}, starts)
+	require.Equal(t, []uint64{1000, 300}, amounts)
+}
@@ -0,0 +1,317 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type FaultKind string
+
+const (
+	LatencyFault    FaultKind = "latency"
+	PacketLossFault FaultKind = "packet-loss"
+	CpuFault        FaultKind = "cpu"
+	MemoryFault     FaultKind = "memory"
+	ClockSkewFault  FaultKind = "clock-skew"
+	DiskFillFault   FaultKind = "disk-fill"
+	// SIGSTOP-style pause of the daemon
+	PauseFault FaultKind = "pause"
+)
+
+var FaultKinds = []FaultKind{LatencyFault, PacketLossFault, CpuFault, MemoryFault, ClockSkewFault, DiskFillFault, PauseFault}
+
+func IsFaultKind(kind FaultKind) bool {
+	for _, k := range FaultKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Time given to revert a fault
+const faultRevertTimeout = 2 * time.Minute
+
+// FaultExecutor injects faults into nodes and reverts them
+type FaultExecutor interface {
+	// Params are kind-specific, they're passed to the executor as is
+	Inject(config Config, addr NodeAddress, kind FaultKind, params json.RawMessage) error
+	Revert(config Config, addr NodeAddress, kind FaultKind) error
+}
+
+// ExecFaultExecutor runs the executable (the control executable by default) as
+// `<exec> <args...> inject-fault <kind> <ip> <params>` and `<exec> <args...> revert-fault <kind> <ip>`
+type ExecFaultExecutor struct {
+	Exec string
+	Args []string
+}
+
+func (e ExecFaultExecutor) run(config Config, args ...string) error {
+	if e.Exec == "" {
+		return errors.New("no fault exec provided")
+	}
+	cmd := exec.CommandContext(config.Ctx, e.Exec, append(append([]string{}, e.Args...), args...)...)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stderr
+	return cmd.Run()
+}
+
+func (e ExecFaultExecutor) Inject(config Config, addr NodeAddress, kind FaultKind, params json.RawMessage) error {
+	ip := string(addr[:strings.IndexRune(string(addr), ':')])
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+	return e.run(config, "inject-fault", string(kind), ip, string(params))
+}
+
+func (e ExecFaultExecutor) Revert(config Config, addr NodeAddress, kind FaultKind) error {
+	ip := string(addr[:strings.IndexRune(string(addr), ':')])
+	return e.run(config, "revert-fault", string(kind), ip)
+}
+
+var _ FaultExecutor = ExecFaultExecutor{}
+
+// FaultExecutorConfig declares an executable injecting faults of the kind
+type FaultExecutorConfig struct {
+	Kind FaultKind `json:"kind"`
+	Exec string    `json:"exec"`
+	Args []string  `json:"args,omitempty"`
+}
+
+func faultExecutors(configs []FaultExecutorConfig) map[FaultKind]FaultExecutor {
+	res := map[FaultKind]FaultExecutor{}
+	for _, c := range configs {
+		res[c.Kind] = ExecFaultExecutor{Exec: c.Exec, Args: c.Args}
+	}
+	return res
+}
+
+func (config Config) faultExecutor(kind FaultKind) FaultExecutor {
+	if e, has := config.FaultExecutors[kind]; has {
+		return e
+	}
+	return ExecFaultExecutor{Exec: config.ControlExec}
+}
+
+type activeFault struct {
+	addr     NodeAddress
+	kind     FaultKind
+	executor FaultExecutor
+	timer    *time.Timer
+}
+
+// FaultTracker keeps faults injected into nodes and reverts them after their duration.
+// Methods of a nil tracker behave as of an empty one.
+type FaultTracker struct {
+	mu     sync.Mutex
+	nextId int
+	active map[int]*activeFault
+	// Reverts in progress
+	wg sync.WaitGroup
+}
+
+func NewFaultTracker() *FaultTracker {
+	return &FaultTracker{active: map[int]*activeFault{}}
+}
+
+func revertFault(config Config, f *activeFault) error {
+	ctx, cancelF := context.WithTimeout(context.Background(), faultRevertTimeout)
+	defer cancelF()
+	config.Ctx = ctx
+	if err := f.executor.Revert(config, f.addr, f.kind); err != nil {
+		return fmt.Errorf("failed to revert %s fault on %s: %v", f.kind, f.addr, err)
+	}
+	config.Log.Infof("reverted %s fault on %s", f.kind, f.addr)
+	return nil
+}
+
+// take removes faults satisfying the predicate from the active ones
+func (t *FaultTracker) take(pred func(*activeFault) bool) []*activeFault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]int, 0, len(t.active))
+	for id, f := range t.active {
+		if pred(f) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	res := make([]*activeFault, len(ids))
+	for i, id := range ids {
+		res[i] = t.active[id]
+		res[i].timer.Stop()
+		delete(t.active, id)
+	}
+	return res
+}
+
+// Add records a fault injected into the node, the fault is reverted after the duration.
+// Revert happens in background and doesn't use the context of the config.
+func (t *FaultTracker) Add(config Config, addr NodeAddress, kind FaultKind, executor FaultExecutor, duration time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := t.nextId
+	t.nextId++
+	f := &activeFault{addr: addr, kind: kind, executor: executor}
+	f.timer = time.AfterFunc(duration, func() {
+		t.mu.Lock()
+		if _, has := t.active[id]; !has {
+			// Already reverted
+			t.mu.Unlock()
+			return
+		}
+		delete(t.active, id)
+		t.wg.Add(1)
+		t.mu.Unlock()
+		defer t.wg.Done()
+		if err := revertFault(config, f); err != nil {
+			config.Log.Warn(err)
+		}
+	})
+	t.active[id] = f
+}
+
+// Revert reverts active faults of the given nodes (of all nodes if none given)
+func (t *FaultTracker) Revert(config Config, nodes []NodeAddress) error {
+	if t == nil {
+		return nil
+	}
+	nodeSet := map[NodeAddress]struct{}{}
+	for _, addr := range nodes {
+		nodeSet[addr] = struct{}{}
+	}
+	faults := t.take(func(f *activeFault) bool {
+		_, has := nodeSet[f.addr]
+		return len(nodes) == 0 || has
+	})
+	errs := []error{}
+	for _, f := range faults {
+		if err := revertFault(config, f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Active returns number of faults not reverted yet
+func (t *FaultTracker) Active() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.active)
+}
+
+// RevertOutstandingFaults reverts all faults that weren't reverted yet and waits for
+// reverts in progress. It's meant to be called after the experiment is over.
+func RevertOutstandingFaults(config Config) error {
+	if config.Faults == nil {
+		return nil
+	}
+	if n := config.Faults.Active(); n > 0 {
+		config.Log.Infof("Reverting %d outstanding faults", n)
+	}
+	err := config.Faults.Revert(config, nil)
+	config.Faults.wg.Wait()
+	return err
+}
+
+type InjectFaultParams struct {
+	Nodes []NodeAddress `json:"nodes"`
+	Kind  FaultKind     `json:"kind"`
+	// Kind-specific parameters passed to the executor, e.g. {"delayMs":200} for latency
+	Params json.RawMessage `json:"params,omitempty"`
+	// Delay before the fault is injected
+	DelaySec int `json:"delaySec,omitempty"`
+	// Fault is reverted automatically after the duration
+	DurationSec int `json:"durationSec"`
+}
+
+// InjectFault injects the fault into every node and schedules its revert,
+// failure to inject into some of nodes doesn't prevent injection into others
+func InjectFault(config Config, params InjectFaultParams, output func(NodeAddress)) error {
+	if !IsFaultKind(params.Kind) {
+		return fmt.Errorf("unknown fault kind %s", params.Kind)
+	}
+	if params.DurationSec <= 0 {
+		return errors.New("duration should be positive")
+	}
+	if config.Faults == nil {
+		return errors.New("fault tracking isn't configured")
+	}
+	if params.DelaySec > 0 {
+		select {
+		case <-config.Ctx.Done():
+			return config.Ctx.Err()
+		case <-time.After(time.Duration(params.DelaySec) * time.Second):
+		}
+	}
+	executor := config.faultExecutor(params.Kind)
+	duration := time.Duration(params.DurationSec) * time.Second
+	errs := []error{}
+	for _, addr := range params.Nodes {
+		if err := executor.Inject(config, addr, params.Kind, params.Params); err != nil {
+			errs = append(errs, fmt.Errorf("failed to inject %s fault into %s: %v", params.Kind, addr, err))
+			continue
+		}
+		config.Faults.Add(config, addr, params.Kind, executor, duration)
+		config.Log.Infof("injected %s fault into %s for %s", params.Kind, addr, duration)
+		output(addr)
+	}
+	return errors.Join(errs...)
+}
+
+type InjectFaultAction struct{}
+
+func (InjectFaultAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	var params InjectFaultParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	return InjectFault(config, params, func(addr NodeAddress) {
+		output("node", addr, true, false)
+	})
+}
+
+func (InjectFaultAction) Name() string { return "inject-fault" }
+
+func (InjectFaultAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: InjectFaultParams{}, Outputs: []OutputSpec{{Name: "node", Kind: StringKind, Multi: true}}}
+}
+
+var _ DeclaredAction = InjectFaultAction{}
+
+type RevertFaultsParams struct {
+	// Nodes to revert faults of, all nodes by default
+	Nodes []NodeAddress `json:"nodes,omitempty"`
+}
+
+type RevertFaultsAction struct{}
+
+func (RevertFaultsAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	var params RevertFaultsParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	return config.Faults.Revert(config, params.Nodes)
+}
+
+func (RevertFaultsAction) Name() string { return "revert-faults" }
+
+func (RevertFaultsAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: RevertFaultsParams{}, Outputs: nil}
+}
+
+var _ DeclaredAction = RevertFaultsAction{}
@@ -0,0 +1,95 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type testFaultExecutor struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (e *testFaultExecutor) Inject(_ Config, addr NodeAddress, kind FaultKind, params json.RawMessage) error {
+	if addr == "bad:1" {
+		return errors.New("unreachable")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, fmt.Sprintf("inject %s %s %s", kind, addr, string(params)))
+	return nil
+}
+
+func (e *testFaultExecutor) Revert(config Config, addr NodeAddress, kind FaultKind) error {
+	if config.Ctx.Err() != nil {
+		return config.Ctx.Err()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, fmt.Sprintf("revert %s %s", kind, addr))
+	return nil
+}
+
+func (e *testFaultExecutor) Events() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string{}, e.events...)
+}
+
+func TestInjectFault(t *testing.T) {
+	executor := &testFaultExecutor{}
+	ctx, cancelF := context.WithCancel(context.Background())
+	config := Config{
+		Ctx:            ctx,
+		Log:            logging.Logger("test"),
+		Faults:         NewFaultTracker(),
+		FaultExecutors: map[FaultKind]FaultExecutor{LatencyFault: executor, PauseFault: executor},
+	}
+	var injected []NodeAddress
+	err := InjectFault(config, InjectFaultParams{
+		Nodes:       []NodeAddress{"a:1", "bad:1", "b:1"},
+		Kind:        LatencyFault,
+		Params:      json.RawMessage(`{"delayMs":200}`),
+		DurationSec: 1,
+	}, func(addr NodeAddress) { injected = append(injected, addr) })
+	require.Error(t, err, "failure on a node is reported")
+	require.Equal(t, []NodeAddress{"a:1", "b:1"}, injected)
+	require.NoError(t, InjectFault(config, InjectFaultParams{Nodes: []NodeAddress{"a:1", "b:1"}, Kind: PauseFault, DurationSec: 600}, func(NodeAddress) {}))
+	require.Equal(t, 4, config.Faults.Active())
+
+	// Faults are reverted after their duration even if the experiment is canceled
+	cancelF()
+	require.Eventually(t, func() bool { return config.Faults.Active() == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, config.Faults.Revert(config, []NodeAddress{"b:1"}))
+	require.Equal(t, 1, config.Faults.Active())
+	require.NoError(t, RevertOutstandingFaults(config))
+	require.Zero(t, config.Faults.Active())
+	require.ElementsMatch(t, []string{
+		`inject latency a:1 {"delayMs":200}`, `inject latency b:1 {"delayMs":200}`,
+		"inject pause a:1 ", "inject pause b:1 ",
+		"revert latency a:1", "revert latency b:1", "revert pause b:1", "revert pause a:1",
+	}, executor.Events())
+
+	err = InjectFault(config, InjectFaultParams{Nodes: []NodeAddress{"a:1"}, Kind: "flood", DurationSec: 1}, func(NodeAddress) {})
+	require.Error(t, err)
+}
+
+func TestParseFaultRatios(t *testing.T) {
+	ratios, err := ParseFaultRatios("latency=0.2,pause=0.1")
+	require.NoError(t, err)
+	require.Equal(t, map[FaultKind]float64{LatencyFault: 0.2, PauseFault: 0.1}, ratios)
+	_, err = ParseFaultRatios("latency")
+	require.Error(t, err)
+	params := someParams()
+	params.FaultRatios = map[FaultKind]float64{LatencyFault: 0.7, PauseFault: 0.4}
+	params.FaultDurationMin = 5
+	require.Contains(t, ValidateAndCollectErrors(&params), "wrong faults: kinds should be known, ratios should be non-negative and sum up to at most 1, fault duration should be positive")
+}
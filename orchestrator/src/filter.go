@@ -0,0 +1,94 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FilterParams selects nodes satisfying all of the predicates set
+type FilterParams struct {
+	Nodes []NodeRecord `json:"nodes"`
+	// Regular expression address of a node should match
+	AddressRegex       string `json:"addressRegex,omitempty"`
+	OnlyBlockProducers bool   `json:"onlyBPs,omitempty"`
+	NoBlockProducers   bool   `json:"noBPs,omitempty"`
+	// Base58check-encoded public keys of submitters to select
+	Submitters []string `json:"submitters,omitempty"`
+	PeerIds    []string `json:"peerIds,omitempty"`
+	// Hosts to exclude, compared to both the host of node's address and the remote address
+	ExceptHosts []string `json:"exceptHosts,omitempty"`
+}
+
+func toSet(values []string) map[string]struct{} {
+	res := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		res[v] = struct{}{}
+	}
+	return res
+}
+
+func addressHost(addr NodeAddress) string {
+	host := string(addr)
+	if ix := strings.LastIndexByte(host, ':'); ix >= 0 {
+		host = host[:ix]
+	}
+	return host
+}
+
+func FilterNodes(params FilterParams, output func(NodeAddress)) error {
+	var re *regexp.Regexp
+	if params.AddressRegex != "" {
+		var err error
+		re, err = regexp.Compile(params.AddressRegex)
+		if err != nil {
+			return fmt.Errorf("invalid address regex: %v", err)
+		}
+	}
+	submitters := toSet(params.Submitters)
+	peerIds := toSet(params.PeerIds)
+	exceptHosts := toSet(params.ExceptHosts)
+	for _, node := range params.Nodes {
+		if re != nil && !re.MatchString(string(node.Address)) {
+			continue
+		}
+		if (params.OnlyBlockProducers && !node.IsBlockProducer) || (params.NoBlockProducers && node.IsBlockProducer) {
+			continue
+		}
+		if _, has := submitters[node.Submitter]; len(submitters) > 0 && !has {
+			continue
+		}
+		if _, has := peerIds[node.PeerId]; len(peerIds) > 0 && !has {
+			continue
+		}
+		if _, has := exceptHosts[addressHost(node.Address)]; has {
+			continue
+		}
+		if _, has := exceptHosts[node.RemoteAddr]; has {
+			continue
+		}
+		output(node.Address)
+	}
+	return nil
+}
+
+type FilterAction struct{}
+
+func (FilterAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	var params FilterParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	return FilterNodes(params, func(addr NodeAddress) {
+		output("group", addr, true, false)
+	})
+}
+
+func (FilterAction) Name() string { return "filter" }
+
+func (FilterAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: FilterParams{}, Outputs: []OutputSpec{{Name: "group", Kind: StringKind, Multi: true}}}
+}
+
+var _ DeclaredAction = FilterAction{}
@@ -0,0 +1,46 @@
+package itn_orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"itn_orchestrator/mocknode"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoveryRecordsAndFilter(t *testing.T) {
+	config, nodes := startMockNodes(t,
+		mocknode.Options{Libp2pPort: 10501, PeerId: "peer0"},
+		mocknode.Options{Libp2pPort: 10502, PeerId: "peer1", IsBlockProducer: true},
+		mocknode.Options{Libp2pPort: 10503, PeerId: "peer2", IsBlockProducer: true},
+	)
+	addrs := make([]NodeAddress, len(nodes))
+	for i, n := range nodes {
+		addrs[i] = NodeAddress(n.Address())
+	}
+	outCache := EmptyOutputCache()
+	runMockScript(t, config, outCache, 0,
+		`{"action":"discovery","params":{"records":true}}`,
+		`{"action":"filter","params":{"nodes":{"type":"output","step":0,"name":"node"},"onlyBPs":true}}`,
+		`{"action":"filter","params":{"nodes":{"type":"output","step":0,"name":"node"},"submitters":["submitter0","submitter2"],"noBPs":true}}`,
+		`{"action":"filter","params":{"nodes":{"type":"output","step":0,"name":"node"},"peerIds":["peer1","peer2"],"addressRegex":"`+
+			strings.TrimPrefix(string(addrs[2]), nodes[2].Host())+`$"}}`,
+		`{"action":"filter","params":{"nodes":{"type":"output","step":0,"name":"node"},"exceptHosts":["127.0.0.1"]}}`,
+	)
+	records := outputValues[NodeRecord](t, outCache, 0, "node")
+	require.ElementsMatch(t, []NodeRecord{
+		{Address: addrs[0], Submitter: "submitter0", PeerId: "peer0", Libp2pPort: 10501, RemoteAddr: nodes[0].Host()},
+		{Address: addrs[1], Submitter: "submitter1", PeerId: "peer1", Libp2pPort: 10502, IsBlockProducer: true, RemoteAddr: nodes[1].Host()},
+		{Address: addrs[2], Submitter: "submitter2", PeerId: "peer2", Libp2pPort: 10503, IsBlockProducer: true, RemoteAddr: nodes[2].Host()},
+	}, records)
+	require.ElementsMatch(t, addrs, outputValues[NodeAddress](t, outCache, 0, "participant"))
+	require.ElementsMatch(t, addrs[1:], outputValues[NodeAddress](t, outCache, 1, "group"))
+	require.Equal(t, addrs[:1], outputValues[NodeAddress](t, outCache, 2, "group"))
+	require.Equal(t, addrs[2:], outputValues[NodeAddress](t, outCache, 3, "group"))
+	_, has := outCache.lookup("", 4, "group")
+	require.False(t, has)
+
+	err := FilterNodes(FilterParams{Nodes: records, AddressRegex: "("}, func(NodeAddress) {})
+	require.Error(t, err)
+}
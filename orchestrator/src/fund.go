@@ -68,7 +68,13 @@ func fundImpl(config Config, ctx context.Context, daemonPort string, params Fund
 			cmd.Stderr = os.Stderr
 			cmd.Stdout = os.Stderr
 			cmd.Env = []string{"MINA_PRIVKEY_PASS=" + password, "ITN_FEATURES=1"}
-			spawnAction(cmd.Run)
+			spawnAction(func() error {
+				err := cmd.Run()
+				if err != nil {
+					observeMinaCliFailure(args)
+				}
+				return err
+			})
 		}
 	})
 }
@@ -79,7 +85,7 @@ func fundRunImpl(config Config, ctx context.Context, daemonPortIx int, params Fu
 	if params.PasswordEnv != "" {
 		password, _ = os.LookupEnv(params.PasswordEnv)
 	}
-	return retryOnMultipleServers(config.FundDaemonPorts, ctx, daemonPortIx, "fund", config.Log, func(daemonPort string) error {
+	return retryOnMultipleServers(config.FundDaemonPorts, ctx, daemonPortIx, "fund", config.Log, config.Retry.minaCli(), func(daemonPort string) error {
 		return fundImpl(config, ctx, daemonPort, params, amountPerKey, password)
 	})
 }
@@ -94,6 +100,10 @@ func (FundAction) Run(config Config, rawParams json.RawMessage, output OutputF)
 
 func (FundAction) Name() string { return "fund-keys" }
 
+func (FundAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: FundParams{}, Outputs: nil}
+}
+
 func memorize(cache map[string]struct{}, keys []string) bool {
 	for _, k := range keys {
 		_, has := cache[k]
@@ -111,10 +121,15 @@ func (FundAction) RunMany(config Config, actionIOs []ActionIO) error {
 		return nil
 	}
 	fundParams := make([]FundParams, len(actionIOs))
+	stepConfigs := make([]Config, len(actionIOs))
 	for i, aIO := range actionIOs {
 		if err := json.Unmarshal(aIO.Params, &fundParams[i]); err != nil {
 			return err
 		}
+		var err error
+		if stepConfigs[i], err = withStepRetry(config, aIO.Params); err != nil {
+			return err
+		}
 	}
 	i := 0
 	for i < len(actionIOs) {
@@ -127,9 +142,10 @@ func (FundAction) RunMany(config Config, actionIOs []ActionIO) error {
 			for ; i < len(actionIOs); i++ {
 				fp := fundParams[i]
 				out := actionIOs[i].Output
+				stepConfig := stepConfigs[i]
 				if memorize(usedKeys, fp.Privkeys) {
 					spawnAction(func() error {
-						return fundRunImpl(config, ctx, daemonPortIx, fp, out)
+						return fundRunImpl(stepConfig, ctx, daemonPortIx, fp, out)
 					})
 				} else {
 					break
@@ -162,3 +178,4 @@ func pathExists(path string) bool {
 }
 
 var _ BatchAction = FundAction{}
+var _ DeclaredAction = FundAction{}
@@ -3,7 +3,6 @@ package itn_orchestrator
 import (
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"strings"
 )
 
@@ -11,23 +10,31 @@ type IsolateParams struct {
 	Nodes []NodeAddress `json:"nodes"`
 }
 
+func networkPeer(config Config, address NodeAddress) (NetworkPeer, error) {
+	host := string(address[:strings.IndexRune(string(address), ':')])
+	nd, has := config.Nodes.Get(address)
+	if !has {
+		var err error
+		nd, err = GetNodeEntry(config, address)
+		if err != nil {
+			return NetworkPeer{}, fmt.Errorf("failed to authenticate peer %s: %v", address, err)
+		}
+	}
+	return NetworkPeer{
+		Libp2pPort: int(nd.Libp2pPort),
+		PeerId:     nd.PeerId,
+		Host:       host,
+	}, nil
+}
+
 func Isolate(config Config, params IsolateParams) error {
 	peers := make([]NetworkPeer, len(params.Nodes))
 	for i, address := range params.Nodes {
-		host := string(address[:strings.IndexRune(string(address), ':')])
-		nd, has := config.NodeData[address]
-		if !has {
-			_, _, err := GetGqlClient(config, address)
-			if err != nil {
-				return fmt.Errorf("failed to authenticate peer %s: %v", address, err)
-			}
-			nd = config.NodeData[address]
-		}
-		peers[i] = NetworkPeer{
-			Libp2pPort: int(nd.Libp2pPort),
-			PeerId:     nd.PeerId,
-			Host:       host,
+		peer, err := networkPeer(config, address)
+		if err != nil {
+			return err
 		}
+		peers[i] = peer
 	}
 	for i, address := range params.Nodes {
 		addedPeers := make([]NetworkPeer, len(peers)-1)
@@ -59,7 +66,11 @@ func (IsolateAction) Run(config Config, rawParams json.RawMessage, output Output
 
 func (IsolateAction) Name() string { return "isolate" }
 
-var _ Action = IsolateAction{}
+func (IsolateAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: IsolateParams{}, Outputs: nil}
+}
+
+var _ DeclaredAction = IsolateAction{}
 
 type ResetGatingParams struct {
 	Nodes          []NodeAddress `json:"nodes"`
@@ -67,8 +78,9 @@ type ResetGatingParams struct {
 }
 
 func ResetGating(config Config, params ResetGatingParams) error {
-	peers := make([]NetworkPeer, 0, len(config.NodeData))
-	for address, nd := range config.NodeData {
+	entries := config.Nodes.Entries()
+	peers := make([]NetworkPeer, 0, len(entries))
+	for address, nd := range entries {
 		host := string(address[:strings.IndexRune(string(address), ':')])
 		peers = append(peers, NetworkPeer{
 			Libp2pPort: int(nd.Libp2pPort),
@@ -97,7 +109,7 @@ func ResetGating(config Config, params ResetGatingParams) error {
 			ixs := make([]int, params.AddRandomPeers)
 			for i := 0; i < params.AddRandomPeers; i++ {
 			outerLoop:
-				ix := rand.Intn(len(peers))
+				ix := config.rand().Intn(len(peers))
 				if peers[ix].Host == string(address[:strings.IndexRune(string(address), ':')]) {
 					goto outerLoop
 				}
@@ -138,4 +150,8 @@ func (ResetGatingAction) Run(config Config, rawParams json.RawMessage, output Ou
 
 func (ResetGatingAction) Name() string { return "reset-gating" }
 
-var _ Action = ResetGatingAction{}
+func (ResetGatingAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: ResetGatingParams{}, Outputs: nil}
+}
+
+var _ DeclaredAction = ResetGatingAction{}
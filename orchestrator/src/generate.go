@@ -32,6 +32,30 @@ type GenParams struct {
 	PaymentAmount, MinZkappFee, MaxZkappFee, FundFee                     uint64
 	MinPaymentFee, MaxPaymentFee                                         uint64
 	ZkappSoftLimit                                                       int
+	WaitReadyTimeoutMin                                                  int
+	Partitions, PartitionMin                                             int
+	// Ratios of disruptions performed by injecting faults of the kinds, instead of stops
+	FaultRatios      map[FaultKind]float64
+	FaultDurationMin int
+	// Shape of load within a round, constant gaussian-sampled tps by default
+	LoadProfile LoadProfile
+	// Number of steps a round is split into (ramp, step and sine profiles)
+	LoadSteps int
+	// Period of spikes and of sine load, minutes
+	LoadPeriodMin int
+	// Duration of a spike, minutes
+	LoadSpikeMin int
+	// Don't send transactions, only stop nodes and inject faults
+	NoLoad bool
+	// Rounds cycle through templates (if any), overrides are applied on top of templates
+	RoundTemplates []RoundTemplate
+	// Overrides of settings of specific rounds, applied in order
+	RoundOverrides []RoundOverride
+	// Seed of random choices of the generator, a random seed is chosen when zero
+	Seed int64
+	rng  *rand.Rand
+	// Minutes previous rounds took beyond their duration, waiting for nodes to get ready
+	overrunMin int
 }
 
 func (p *GenParams) ToJSON() (datatypes.JSON, error) {
@@ -86,6 +110,10 @@ func DefaultGenParams() GenParams {
 		MinPaymentFee:          1e8,
 		MaxPaymentFee:          2e8,
 		ZkappSoftLimit:         -2,
+		LoadProfile:            GaussianLoad,
+		LoadSteps:              5,
+		LoadPeriodMin:          10,
+		LoadSpikeMin:           2,
 	}
 }
 
@@ -99,10 +127,30 @@ func (cmd *GeneratedCommand) Comment() string {
 	return cmd.comment
 }
 
+// GeneratedRound is a round of the experiment plan, commands of the round
+// are rendered into the script and the rest describes the round's timeline
 type GeneratedRound struct {
-	Commands           []GeneratedCommand
-	PaymentFundCommand *FundParams
-	ZkappFundCommand   *FundParams
+	Commands           []GeneratedCommand `json:"-"`
+	Round              int                `json:"round"`
+	PaymentFundCommand *FundParams        `json:"paymentFund,omitempty"`
+	ZkappFundCommand   *FundParams        `json:"zkappFund,omitempty"`
+	PaymentKeysDir     string             `json:"paymentKeysDir,omitempty"`
+	ZkappKeysDir       string             `json:"zkappKeysDir,omitempty"`
+	// Start of the round, minutes after start of the experiment
+	StartMin    int `json:"startMin"`
+	DurationMin int `json:"durationMin"`
+	// Pauses after the round, minutes
+	PauseMin      int `json:"pauseMin"`
+	LargePauseMin int `json:"largePauseMin,omitempty"`
+	// Ratio of participants sending transactions, zero if no load is sent
+	SenderRatio float64 `json:"senderRatio"`
+	// Rotation of balances performed at the start of the round
+	Rotation *RotateParams `json:"rotation,omitempty"`
+	// Partitioning of the network performed at the start of the round
+	Partitions   int          `json:"partitions,omitempty"`
+	PartitionMin int          `json:"partitionMin,omitempty"`
+	Load         []LoadWindow `json:"load"`
+	Stops        []StopEvent  `json:"stops"`
 }
 
 func withComment(comment string, cmd GeneratedCommand) GeneratedCommand {
@@ -243,27 +291,35 @@ type RestartRefParams struct {
  * ~95% of numbers returned should fall between -2 and 2
  * ie within two standard deviations
  */
-func gaussRandom() float64 {
-	u := 2*rand.Float64() - 1
-	v := 2*rand.Float64() - 1
+func gaussRandom(rng *rand.Rand) float64 {
+	u := 2*rng.Float64() - 1
+	v := 2*rng.Float64() - 1
 	r := u*u + v*v
 	// if outside interval [0,1] start over
 	if r == 0 || r >= 1 {
-		return gaussRandom()
+		return gaussRandom(rng)
 	}
 
 	c := math.Sqrt(-2 * math.Log(r) / r)
 	return u * c
 }
 
-func SampleTps(baseTps, stressTps float64) float64 {
+func SampleTps(rng *rand.Rand, baseTps, stressTps float64) float64 {
 	tpsStddev := (stressTps - baseTps) / 2
-	return tpsStddev*math.Abs(gaussRandom()) + baseTps
+	return tpsStddev*math.Abs(gaussRandom(rng)) + baseTps
 }
 
-func SampleStopRatio(minRatio, maxRatio float64) float64 {
+func SampleStopRatio(rng *rand.Rand, minRatio, maxRatio float64) float64 {
 	stddev := (maxRatio - minRatio) / 3
-	return stddev*math.Abs(gaussRandom()) + minRatio
+	return stddev*math.Abs(gaussRandom(rng)) + minRatio
+}
+
+// Rand returns the generator of random numbers seeded with the seed of params
+func (p *GenParams) Rand() *rand.Rand {
+	if p.rng == nil {
+		p.rng = rand.New(rand.NewSource(p.Seed))
+	}
+	return p.rng
 }
 
 func genStopDaemon(useRestartScript bool, nodesRef int, nodesName string, clean bool) GeneratedCommand {
@@ -279,6 +335,94 @@ func genStopDaemon(useRestartScript bool, nodesRef int, nodesName string, clean
 	}}
 }
 
+type WaitReadyRefParams struct {
+	Nodes      ComplexValue `json:"nodes"`
+	DelaySec   int          `json:"delaySec,omitempty"`
+	TimeoutSec int          `json:"timeoutSec"`
+}
+
+// Delay before stopped nodes are polled for readiness,
+// to let stop-daemon take effect
+const waitReadyDelaySec = 60
+
+func waitReady(nodesRef int, nodesName string, timeoutMin int) GeneratedCommand {
+	nodes := LocalComplexValue(nodesRef, nodesName)
+	nodes.OnEmpty = emptyArrayRawMessage
+	return GeneratedCommand{Action: WaitReadyAction{}.Name(), Params: WaitReadyRefParams{
+		Nodes:      nodes,
+		DelaySec:   waitReadyDelaySec,
+		TimeoutSec: timeoutMin * 60,
+	}}
+}
+
+type PartitionRefParams struct {
+	Nodes  ComplexValue `json:"nodes"`
+	Ratios []float64    `json:"ratios"`
+}
+
+func partition(nodesRef int, nodesName string, partitions int) GeneratedCommand {
+	ratios := make([]float64, partitions)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return GeneratedCommand{Action: PartitionAction{}.Name(), Params: PartitionRefParams{
+		Nodes:  LocalComplexValue(nodesRef, nodesName),
+		Ratios: ratios,
+	}}
+}
+
+type HealRefParams struct {
+	Nodes ComplexValue `json:"nodes"`
+}
+
+func heal(nodesRef int, nodesName string) GeneratedCommand {
+	return GeneratedCommand{Action: HealAction{}.Name(), Params: HealRefParams{
+		Nodes: LocalComplexValue(nodesRef, nodesName),
+	}}
+}
+
+type InjectFaultRefParams struct {
+	Nodes       ComplexValue `json:"nodes"`
+	Kind        FaultKind    `json:"kind"`
+	DurationSec int          `json:"durationSec"`
+}
+
+func injectFault(nodesRef int, nodesName string, kind FaultKind, durationMin int) GeneratedCommand {
+	return GeneratedCommand{Action: InjectFaultAction{}.Name(), Params: InjectFaultRefParams{
+		Nodes:       LocalComplexValue(nodesRef, nodesName),
+		Kind:        kind,
+		DurationSec: durationMin * 60,
+	}}
+}
+
+// disruption is a stop or a fault injection performed on a sampled group of nodes
+type disruption struct {
+	kind  DisruptionKind
+	ratio float64
+	stop  bool
+	cmd   func(ref int, name string) GeneratedCommand
+}
+
+// ParseFaultRatios parses comma-separated list of <kind>=<ratio> pairs
+func ParseFaultRatios(s string) (map[FaultKind]float64, error) {
+	res := map[FaultKind]float64{}
+	if s == "" {
+		return res, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kind, ratioStr, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("expected <kind>=<ratio>, got %s", pair)
+		}
+		ratio, err := strconv.ParseFloat(ratioStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ratio of %s: %v", kind, err)
+		}
+		res[FaultKind(kind)] = ratio
+	}
+	return res, nil
+}
+
 type JoinRefParams struct {
 	Group1 ComplexValue `json:"group1"`
 	Group2 ComplexValue `json:"group2"`
@@ -313,23 +457,33 @@ func except(groupRef int, groupName string, exceptRef int, exceptName string) Ge
 	}}
 }
 func (p *GenParams) Generate(round int) GeneratedRound {
+	if len(p.RoundOverrides) > 0 || len(p.RoundTemplates) > 0 {
+		// Params of the round share the random generator
+		p.Rand()
+		roundParams := p.roundParams(round)
+		res := roundParams.Generate(round)
+		p.overrunMin = roundParams.overrunMin
+		return res
+	}
 	zkappsKeysDir := fmt.Sprintf("%s/%s/round-%d/zkapps", p.FundKeyPrefix, p.ExperimentName, round)
 	paymentsKeysDir := fmt.Sprintf("%s/%s/round-%d/payments", p.FundKeyPrefix, p.ExperimentName, round)
-	tps := SampleTps(p.BaseTps, p.StressTps)
+	rng := p.Rand()
+	steps := p.loadSteps(rng)
+	tpsMultiplier := 1.0
 	maxCost := p.MaxCost
 	zkappRatio := p.ZkappRatio
 	if p.MixMaxCostTpsRatio > 1e-3 && (round&1) == 1 {
 		maxCost = true
 		zkappRatio = 1
-		tps *= p.MixMaxCostTpsRatio
+		tpsMultiplier = p.MixMaxCostTpsRatio
 	}
 	experimentName := fmt.Sprintf("%s-%d", p.ExperimentName, round)
 	onlyZkapps := math.Abs(1-zkappRatio) < 1e-3
 	onlyPayments := zkappRatio < 1e-3
-	zkappTps := tps * zkappRatio
+	sendsZkapps := !p.NoLoad && !onlyPayments
+	sendsPayments := !p.NoLoad && !onlyZkapps
 	zkappParams := ZkappSubParams{
 		ExperimentName:   experimentName,
-		Tps:              zkappTps,
 		MinTps:           p.MinTps,
 		DurationMin:      p.RoundDurationMin,
 		Gap:              p.Gap,
@@ -351,7 +505,6 @@ func (p *GenParams) Generate(round int) GeneratedRound {
 	}
 	paymentParams := PaymentSubParams{
 		ExperimentName: experimentName,
-		Tps:            tps - zkappTps,
 		MinTps:         p.MinTps,
 		DurationMin:    p.RoundDurationMin,
 		MinFee:         p.MinPaymentFee,
@@ -360,25 +513,27 @@ func (p *GenParams) Generate(round int) GeneratedRound {
 		Receiver:       p.PaymentReceiver,
 	}
 	cmds := []GeneratedCommand{}
-	roundStartMin := round*(p.RoundDurationMin+p.PauseMin) + round/p.LargePauseEveryNRounds*p.LargePauseMin
+	roundStartMin := round*(p.RoundDurationMin+p.PauseMin) + round/p.LargePauseEveryNRounds*p.LargePauseMin + p.overrunMin
+	var rotation *RotateParams
 	if len(p.RotationKeys) > 0 {
 		var mapping []int
 		nKeys := len(p.RotationKeys)
 		if p.RotationPermutation {
-			mapping = rand.Perm(nKeys)
+			mapping = rng.Perm(nKeys)
 		} else {
 			mapping = make([]int, nKeys)
 			for i := range mapping {
-				mapping[i] = rand.Intn(len(p.RotationKeys))
+				mapping[i] = rng.Intn(len(p.RotationKeys))
 			}
 		}
-		cmds = append(cmds, rotate(RotateParams{
+		rotation = &RotateParams{
 			Pubkeys:     p.RotationKeys,
 			RestServers: p.RotationServers,
 			Mapping:     mapping,
 			Ratio:       p.RotationRatio,
 			PasswordEnv: p.PasswordEnv,
-		}))
+		}
+		cmds = append(cmds, rotate(*rotation))
 	}
 	roundStartMsg := fmt.Sprintf("Starting round %d, %s after start", round, formatDur(roundStartMin, 0))
 	cmds = append(cmds, withComment(roundStartMsg, Discovery(DiscoveryParams{
@@ -396,84 +551,226 @@ func (p *GenParams) Generate(round int) GeneratedRound {
 		cmds = append(cmds, withComment(msg, ZkappSoftLimit(-1, "participant", p.ZkappSoftLimit)))
 		participantsRef--
 	}
-	if 1-p.SenderRatio > 1e-6 {
+	if !p.NoLoad && 1-p.SenderRatio > 1e-6 {
 		cmds = append(cmds, sample(participantsRef, participantsName, []float64{p.SenderRatio}))
 		participantsName = "group1"
 		participantsRef = -1
 	}
-	if onlyPayments {
-		cmds = append(cmds, loadKeys(KeyloaderParams{Dir: paymentsKeysDir}))
-		cmds = append(cmds, payments(-1, participantsRef-1, participantsName, paymentParams))
-	} else if onlyZkapps {
-		cmds = append(cmds, loadKeys(KeyloaderParams{Dir: zkappsKeysDir}))
-		cmds = append(cmds, zkapps(-1, participantsRef-1, participantsName, zkappParams))
-	} else {
+	// Commands are referenced by their index, as steps of a round
+	// refer to the same participants and keys
+	participantsCmdId := len(cmds) + participantsRef
+	var zkappKeysCmdId, paymentKeysCmdId int
+	if sendsZkapps {
 		cmds = append(cmds, loadKeys(KeyloaderParams{Dir: zkappsKeysDir}))
+		zkappKeysCmdId = len(cmds) - 1
+	}
+	if sendsPayments {
 		cmds = append(cmds, loadKeys(KeyloaderParams{Dir: paymentsKeysDir}))
-		cmds = append(cmds, zkapps(-2, participantsRef-2, participantsName, zkappParams))
-		cmds = append(cmds, payments(-2, participantsRef-3, participantsName, paymentParams))
-		cmds = append(cmds, join(-1, "participant", -2, "participant"))
+		paymentKeysCmdId = len(cmds) - 1
 	}
-	sendersCmdId := len(cmds)
-	stopWaits := make([]int, p.StopsPerRound)
+	var sendersCmdId int
+	var loadWindows []LoadWindow
+	// Seconds since start of the round
+	elapsed := 0
+	scheduleStep := func(stepIx int, step loadStep) {
+		if len(steps) > 1 {
+			// Memos of transactions are unique across steps
+			zkappParams.ExperimentName = fmt.Sprintf("%s-%d", experimentName, stepIx)
+			paymentParams.ExperimentName = zkappParams.ExperimentName
+		}
+		tps := step.Tps * tpsMultiplier
+		zkappParams.Tps = tps * zkappRatio
+		zkappParams.DurationMin = step.DurationMin
+		paymentParams.Tps = tps - zkappParams.Tps
+		paymentParams.DurationMin = step.DurationMin
+		window := LoadWindow{StartSec: elapsed, DurationMin: step.DurationMin, MaxCost: maxCost}
+		if sendsZkapps {
+			cmds = append(cmds, zkapps(zkappKeysCmdId-len(cmds), participantsCmdId-len(cmds), participantsName, zkappParams))
+			window.ZkappTps = zkappParams.Tps
+		}
+		if sendsPayments {
+			cmds = append(cmds, payments(paymentKeysCmdId-len(cmds), participantsCmdId-len(cmds), participantsName, paymentParams))
+			window.PaymentTps = paymentParams.Tps
+		}
+		if sendsZkapps || sendsPayments {
+			loadWindows = append(loadWindows, window)
+		}
+		if sendsZkapps && sendsPayments {
+			cmds = append(cmds, join(-1, "participant", -2, "participant"))
+		}
+		sendersCmdId = len(cmds)
+	}
+	partitionSec := 0
+	if p.Partitions > 1 {
+		partitionSec = p.PartitionMin * 60
+	}
+	stopTimes := make([]int, p.StopsPerRound)
 	for i := 0; i < p.StopsPerRound; i++ {
-		stopWaits[i] = rand.Intn(60 * p.RoundDurationMin)
+		stopTimes[i] = partitionSec + rng.Intn(60*p.RoundDurationMin-partitionSec)
 	}
-	sort.Ints(stopWaits)
-	for i := p.StopsPerRound - 1; i > 0; i-- {
-		stopWaits[i] -= stopWaits[i-1]
+	sort.Ints(stopTimes)
+	stopRatio := SampleStopRatio(rng, p.MinStopRatio, p.MaxStopRatio)
+	var stopEvents []StopEvent
+	waitUntil := func(sec int) {
+		if sec <= elapsed {
+			// Wait for nodes to get ready took longer
+			return
+		}
+		comment := fmt.Sprintf("Running round %d, %s after start, waiting for %s", round, formatDur(roundStartMin, elapsed), formatDur(0, sec-elapsed))
+		cmds = append(cmds, withComment(comment, GenWait(sec-elapsed)))
+		elapsed = sec
 	}
-	stopRatio := SampleStopRatio(p.MinStopRatio, p.MaxStopRatio)
-	elapsed := 0
-	for _, waitSec := range stopWaits {
-		cmds = append(cmds, withComment(fmt.Sprintf("Running round %d, %s after start, waiting for %s", round, formatDur(roundStartMin, elapsed), formatDur(0, waitSec)), GenWait(waitSec)))
+	stop := func() {
 		cmds = append(cmds, Discovery(DiscoveryParams{
 			OnlyBlockProducers: p.StopOnlyBps,
 		}))
-		exceptRefName := "group"
-		if onlyPayments || onlyZkapps {
-			exceptRefName = "participant"
+		groupName := "participant"
+		if !p.NoLoad {
+			exceptRefName := "group"
+			if onlyPayments || onlyZkapps {
+				exceptRefName = "participant"
+			}
+			cmds = append(cmds, except(-1, "participant", sendersCmdId-len(cmds)-1, exceptRefName))
+			groupName = "group"
 		}
-		cmds = append(cmds, except(-1, "participant", sendersCmdId-len(cmds)-1, exceptRefName))
-		stopCleanRatio := p.StopCleanRatio * stopRatio
-		stopNoCleanRatio := (1 - p.StopCleanRatio) * stopRatio
+		faultsRatio := 0.0
+		for _, r := range p.FaultRatios {
+			faultsRatio += r
+		}
+		stopCleanRatio := p.StopCleanRatio * (1 - faultsRatio) * stopRatio
+		stopNoCleanRatio := (1 - p.StopCleanRatio) * (1 - faultsRatio) * stopRatio
 		nodesOrBps := "nodes"
 		if p.StopOnlyBps {
 			nodesOrBps = "block producers"
 		}
-		if stopCleanRatio > 1e-6 && stopNoCleanRatio > 1e-6 {
-			cmds = append(cmds, sample(-1, "group", []float64{stopCleanRatio, stopNoCleanRatio}))
-			comment1 := fmt.Sprintf("Stopping %.1f%% %s with cleaning", stopCleanRatio*100, nodesOrBps)
-			cmds = append(cmds, withComment(comment1, genStopDaemon(p.UseRestartScript, -1, "group1", true)))
-			comment2 := fmt.Sprintf("Stopping %.1f%% %s without cleaning", stopNoCleanRatio*100, nodesOrBps)
-			cmds = append(cmds, withComment(comment2, genStopDaemon(p.UseRestartScript, -2, "group2", false)))
-		} else if stopCleanRatio > 1e-6 {
+		disruptions := []disruption{}
+		if stopCleanRatio > 1e-6 {
 			comment := fmt.Sprintf("Stopping %.1f%% %s with cleaning", stopCleanRatio*100, nodesOrBps)
-			cmds = append(cmds, sample(-1, "group", []float64{stopCleanRatio}))
-			cmds = append(cmds, withComment(comment, genStopDaemon(p.UseRestartScript, -1, "group1", true)))
-		} else if stopNoCleanRatio > 1e-6 {
+			disruptions = append(disruptions, disruption{kind: StopCleanDisruption, ratio: stopCleanRatio, stop: true, cmd: func(ref int, name string) GeneratedCommand {
+				return withComment(comment, genStopDaemon(p.UseRestartScript, ref, name, true))
+			}})
+		}
+		if stopNoCleanRatio > 1e-6 {
 			comment := fmt.Sprintf("Stopping %.1f%% %s without cleaning", stopNoCleanRatio*100, nodesOrBps)
-			cmds = append(cmds, sample(-1, "group", []float64{stopNoCleanRatio}))
-			cmds = append(cmds, withComment(comment, genStopDaemon(p.UseRestartScript, -1, "group1", false)))
+			disruptions = append(disruptions, disruption{kind: StopDisruption, ratio: stopNoCleanRatio, stop: true, cmd: func(ref int, name string) GeneratedCommand {
+				return withComment(comment, genStopDaemon(p.UseRestartScript, ref, name, false))
+			}})
+		}
+		for _, kind := range FaultKinds {
+			ratio := p.FaultRatios[kind] * stopRatio
+			if ratio <= 1e-6 {
+				continue
+			}
+			comment := fmt.Sprintf("Injecting %s fault into %.1f%% %s for %d minutes", kind, ratio*100, nodesOrBps, p.FaultDurationMin)
+			disruptions = append(disruptions, disruption{kind: DisruptionKind(kind), ratio: ratio, cmd: func(ref int, name string) GeneratedCommand {
+				return withComment(comment, injectFault(ref, name, kind, p.FaultDurationMin))
+			}})
+		}
+		if len(disruptions) == 0 {
+			return
+		}
+		ratios := make([]float64, len(disruptions))
+		event := StopEvent{AtSec: elapsed}
+		for i, d := range disruptions {
+			ratios[i] = d.ratio
+			event.Disruptions = append(event.Disruptions, Disruption{Kind: d.kind, Ratio: d.ratio})
+		}
+		stopEvents = append(stopEvents, event)
+		cmds = append(cmds, sample(-1, groupName, ratios))
+		stopped := []string{}
+		for i, d := range disruptions {
+			name := fmt.Sprintf("group%d", i+1)
+			cmds = append(cmds, d.cmd(-1-i, name))
+			if d.stop {
+				stopped = append(stopped, name)
+			}
+		}
+		sampleRef := -1 - len(disruptions)
+		if p.WaitReadyTimeoutMin > 0 && len(stopped) == 1 {
+			cmds = append(cmds, withComment("Waiting for stopped nodes to get ready", waitReady(sampleRef, stopped[0], p.WaitReadyTimeoutMin)))
+		} else if p.WaitReadyTimeoutMin > 0 && len(stopped) == 2 {
+			cmds = append(cmds, join(sampleRef, stopped[0], sampleRef, stopped[1]))
+			cmds = append(cmds, withComment("Waiting for stopped nodes to get ready", waitReady(-1, "group", p.WaitReadyTimeoutMin)))
+		}
+		if p.WaitReadyTimeoutMin > 0 && len(stopped) > 0 {
+			// Rest of the round is scheduled as if waiting took the whole timeout
+			elapsed += waitReadyDelaySec + p.WaitReadyTimeoutMin*60
 		}
-		elapsed += waitSec
 	}
+	// Stops are performed in between of load steps, at their sampled times
+	nextStop := 0
+	stopBefore := func(sec int) {
+		for ; nextStop < len(stopTimes) && stopTimes[nextStop] < sec; nextStop++ {
+			waitUntil(stopTimes[nextStop])
+			stop()
+		}
+	}
+	for i, step := range steps {
+		stopBefore(step.StartMin * 60)
+		waitUntil(step.StartMin * 60)
+		scheduleStep(i, step)
+		if i == 0 && p.Partitions > 1 {
+			comment := fmt.Sprintf("Partitioning network into %d partitions for %d minutes", p.Partitions, p.PartitionMin)
+			cmds = append(cmds, withComment(comment, Discovery(DiscoveryParams{})))
+			cmds = append(cmds, partition(-1, "participant", p.Partitions))
+			cmds = append(cmds, waitMin(p.PartitionMin))
+			cmds = append(cmds, withComment("Healing network partitions", heal(-3, "participant")))
+			elapsed = partitionSec
+		}
+	}
+	stopBefore(p.RoundDurationMin * 60)
+	// Round is extended to a whole number of minutes by waits for nodes to get ready
+	durationMin := max(p.RoundDurationMin, (elapsed+59)/60)
+	p.overrunMin += durationMin - p.RoundDurationMin
+	pauseMin, largePauseMin := 0, 0
 	if round < p.Rounds-1 {
-		comment1 := fmt.Sprintf("Waiting for remainder of round %d, %s after start", round, formatDur(roundStartMin, elapsed))
-		cmds = append(cmds, withComment(comment1, GenWait(p.RoundDurationMin*60-elapsed)))
+		if durationMin*60 > elapsed {
+			comment1 := fmt.Sprintf("Waiting for remainder of round %d, %s after start", round, formatDur(roundStartMin, elapsed))
+			cmds = append(cmds, withComment(comment1, GenWait(durationMin*60-elapsed)))
+		}
 		if p.PauseMin > 0 {
-			comment2 := fmt.Sprintf("Pause after round %d, %s after start", round, formatDur(roundStartMin+p.RoundDurationMin, 0))
+			comment2 := fmt.Sprintf("Pause after round %d, %s after start", round, formatDur(roundStartMin+durationMin, 0))
 			cmds = append(cmds, withComment(comment2, waitMin(p.PauseMin)))
+			pauseMin = p.PauseMin
 		}
 		if p.LargePauseMin > 0 && (round+1)%p.LargePauseEveryNRounds == 0 {
-			comment3 := fmt.Sprintf("Large pause after round %d, %s after start", round, formatDur(roundStartMin+p.RoundDurationMin+p.PauseMin, 0))
+			comment3 := fmt.Sprintf("Large pause after round %d, %s after start", round, formatDur(roundStartMin+durationMin+p.PauseMin, 0))
 			cmds = append(cmds, withComment(comment3, waitMin(p.LargePauseMin)))
+			largePauseMin = p.LargePauseMin
 		}
 	}
-	res := GeneratedRound{Commands: cmds}
-	if !onlyPayments {
-		_, _, _, initBalance := ZkappBalanceRequirements(zkappTps, zkappParams)
-		zkappKeysNum, zkappAmount := ZkappKeygenRequirements(initBalance, zkappParams)
+	res := GeneratedRound{
+		Commands:      cmds,
+		Round:         round,
+		StartMin:      roundStartMin,
+		DurationMin:   durationMin,
+		PauseMin:      pauseMin,
+		LargePauseMin: largePauseMin,
+		Rotation:      rotation,
+		Load:          loadWindows,
+		Stops:         stopEvents,
+	}
+	if p.Partitions > 1 {
+		res.Partitions = p.Partitions
+		res.PartitionMin = p.PartitionMin
+	}
+	if !p.NoLoad {
+		res.SenderRatio = p.SenderRatio
+	}
+	// Funds are budgeted for the peak tps sustained over the whole round
+	peakTps := 0.0
+	for _, step := range steps {
+		peakTps = max(peakTps, step.Tps*tpsMultiplier)
+	}
+	zkappParams.Tps = peakTps * zkappRatio
+	zkappParams.DurationMin = p.RoundDurationMin
+	paymentParams.Tps = peakTps - zkappParams.Tps
+	paymentParams.DurationMin = p.RoundDurationMin
+	if sendsZkapps {
+		_, _, _, initBalance := ZkappBalanceRequirements(zkappParams.Tps, zkappParams)
+		// Each step deploys its own zkapps
+		zkappKeysNum, zkappAmount := ZkappKeygenRequirements(initBalance, zkappParams, len(steps))
+		res.ZkappKeysDir = zkappsKeysDir
 		res.ZkappFundCommand = &FundParams{
 			PasswordEnv: p.PasswordEnv,
 			Prefix:      zkappsKeysDir + "/key",
@@ -482,8 +779,9 @@ func (p *GenParams) Generate(round int) GeneratedRound {
 			Num:         zkappKeysNum,
 		}
 	}
-	if !onlyZkapps {
+	if sendsPayments {
 		paymentKeysNum, paymentAmount := PaymentKeygenRequirements(p.Gap, paymentParams)
+		res.PaymentKeysDir = paymentsKeysDir
 		res.PaymentFundCommand = &FundParams{
 			PasswordEnv: p.PasswordEnv,
 			Prefix:      paymentsKeysDir + "/key",
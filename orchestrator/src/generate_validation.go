@@ -57,6 +57,13 @@ func ValidationSteps(p *GenParams) []ValidationStep {
 			},
 			ExitCode: 2,
 		},
+		{
+			ErrorMsg: "round duration should be positive",
+			Check: func(p *GenParams) bool {
+				return p.RoundDurationMin <= 0
+			},
+			ExitCode: 9,
+		},
 		{
 			ErrorMsg: "increase round duration: roundDurationMin*60 should be more than gap*4",
 			Check: func(p *GenParams) bool {
@@ -64,6 +71,72 @@ func ValidationSteps(p *GenParams) []ValidationStep {
 			},
 			ExitCode: 9,
 		},
+		{
+			ErrorMsg: "wait ready timeout can't be negative",
+			Check: func(p *GenParams) bool {
+				return p.WaitReadyTimeoutMin < 0
+			},
+			ExitCode: 2,
+		},
+		{
+			ErrorMsg: "wrong partitioning: number of partitions should be 0 or at least 2 and partitioning should be shorter than a round",
+			Check: func(p *GenParams) bool {
+				return p.Partitions < 0 || p.Partitions == 1 || (p.Partitions > 1 && (p.PartitionMin <= 0 || p.PartitionMin >= p.RoundDurationMin))
+			},
+			ExitCode: 2,
+		},
+		{
+			ErrorMsg: "wrong faults: kinds should be known, ratios should be non-negative and sum up to at most 1, fault duration should be positive",
+			Check: func(p *GenParams) bool {
+				sum := 0.0
+				for kind, r := range p.FaultRatios {
+					if !IsFaultKind(kind) || r < 0 {
+						return true
+					}
+					sum += r
+				}
+				return sum > 1 || (sum > 0 && p.FaultDurationMin <= 0)
+			},
+			ExitCode: 2,
+		},
+		{
+			ErrorMsg: "wrong load profile: profile should be known, number of steps should be positive and at most round duration, spikes should be shorter than their period, partitioning should fit into the first step",
+			Check: func(p *GenParams) bool {
+				return !validLoadProfile(p)
+			},
+			ExitCode: 2,
+		},
+		{
+			ErrorMsg: "wrong round templates or overrides: templates should be predefined or define settings, overrides should be within rounds of the experiment and overridden settings should be valid",
+			Check: func(p *GenParams) bool {
+				for _, t := range p.RoundTemplates {
+					if _, has := PredefinedRoundTemplates[t.Name]; !has && t.RoundSettings == (RoundSettings{}) {
+						return true
+					}
+				}
+				for _, o := range p.RoundOverrides {
+					if o.FromRound < 0 || o.ToRound > p.Rounds || o.FromRound >= o.ToRound {
+						return true
+					}
+				}
+				if len(p.RoundOverrides) == 0 && len(p.RoundTemplates) == 0 {
+					return false
+				}
+				steps := ValidationSteps(p)
+				for r := 0; r < p.Rounds; r++ {
+					rp := p.roundParams(r)
+					for i, step := range ValidationSteps(rp) {
+						// Only errors introduced by overrides are reported (params of a round
+						// have no overrides, so this step isn't checked recursively)
+						if step.Check(rp) && !steps[i].Check(p) {
+							return true
+						}
+					}
+				}
+				return false
+			},
+			ExitCode: 2,
+		},
 		{
 			ErrorMsg: "wrong new account ratio",
 			Check: func(p *GenParams) bool {
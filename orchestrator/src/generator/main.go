@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	lib "itn_orchestrator"
+)
+
+const mixMaxCostTpsRatioHelp = "when provided, specifies ratio of tps (proportional to total tps) for max cost transactions to be used every other round, zkapps ratio for these rounds is set to 100%"
+
+func main() {
+	var rotateKeys, rotateServers, faultRatios, loadProfile, roundTemplates string
+	var mode, experimentFile string
+	var nodes int
+	var timelinePrefix, timelineStart, orchestratorConfig string
+	var p lib.GenParams
+	var defaults = lib.DefaultGenParams()
+
+	flag.Float64Var(&p.BaseTps, "base-tps", defaults.BaseTps, "Base tps rate for the whole network")
+	flag.Float64Var(&p.StressTps, "stress-tps", defaults.StressTps, "stress tps rate for the whole network")
+	flag.Float64Var(&p.MinTps, "min-tps", defaults.MinTps, "minimal tps per node")
+	flag.Float64Var(&p.MinStopRatio, "stop-min-ratio", defaults.MinStopRatio, "float in range [0..1], minimum ratio of nodes to stop at an interval")
+	flag.Float64Var(&p.MaxStopRatio, "stop-max-ratio", defaults.MaxStopRatio, "float in range [0..1], maximum ratio of nodes to stop at an interval")
+	flag.Float64Var(&p.SenderRatio, "sender-ratio", defaults.SenderRatio, "float in range [0..1], max proportion of nodes selected for transaction sending")
+	flag.Float64Var(&p.ZkappRatio, "zkapp-ratio", defaults.ZkappRatio, "float in range [0..1], ratio of zkapp transactions of all transactions generated")
+	flag.Float64Var(&p.StopCleanRatio, "stop-clean-ratio", defaults.StopCleanRatio, "float in range [0..1], ratio of stops with cleaning of all stops")
+	flag.Float64Var(&p.NewAccountRatio, "new-account-ratio", defaults.NewAccountRatio, "float in range [0..1], ratio of new accounts, in relation to expected number of zkapp txs, ignored for max-cost txs")
+	flag.BoolVar(&p.SendFromNonBpsOnly, "send-from-non-bps", defaults.SendFromNonBpsOnly, "send only from non block producers")
+	flag.BoolVar(&p.StopOnlyBps, "stop-only-bps", defaults.StopOnlyBps, "stop only block producers")
+	flag.BoolVar(&p.UseRestartScript, "use-restart-script", defaults.UseRestartScript, "use restart script instead of stop-daemon command")
+	flag.BoolVar(&p.MaxCost, "max-cost", defaults.MaxCost, "send max-cost zkapp commands")
+	flag.IntVar(&p.RoundDurationMin, "round-duration", defaults.RoundDurationMin, "duration of a round, minutes")
+	flag.IntVar(&p.PauseMin, "pause", defaults.PauseMin, "duration of a pause between rounds, minutes")
+	flag.IntVar(&p.Rounds, "rounds", defaults.Rounds, "number of rounds to run experiment")
+	flag.IntVar(&p.StopsPerRound, "round-stops", defaults.StopsPerRound, "number of stops to perform within round")
+	flag.IntVar(&p.Gap, "gap", defaults.Gap, "gap between related transactions, seconds")
+	flag.IntVar(&p.WaitReadyTimeoutMin, "wait-ready-timeout", defaults.WaitReadyTimeoutMin, "timeout of waiting for stopped nodes to get ready after each stop, minutes (0 for no waiting)")
+	flag.IntVar(&p.Partitions, "partitions", defaults.Partitions, "number of partitions to split network into at the start of each round (0 for no partitioning)")
+	flag.IntVar(&p.PartitionMin, "partition-duration", defaults.PartitionMin, "duration of network partitioning, minutes")
+	flag.StringVar(&faultRatios, "faults", "", "comma-separated list of <kind>=<ratio>, ratios of disruptions performed by injecting faults of the kind instead of stops (kinds: latency, packet-loss, cpu, memory, clock-skew, disk-fill, pause)")
+	flag.IntVar(&p.FaultDurationMin, "fault-duration", defaults.FaultDurationMin, "duration of injected faults, minutes")
+	flag.StringVar(&loadProfile, "load-profile", string(defaults.LoadProfile), "shape of load within a round: gaussian (constant tps sampled between base and stress tps), ramp, step, spike or sine")
+	flag.IntVar(&p.LoadSteps, "load-steps", defaults.LoadSteps, "number of steps a round is split into for ramp, step and sine load profiles")
+	flag.IntVar(&p.LoadPeriodMin, "load-period", defaults.LoadPeriodMin, "period of spike and sine load profiles, minutes")
+	flag.IntVar(&p.LoadSpikeMin, "spike-duration", defaults.LoadSpikeMin, "duration of a spike of spike load profile, minutes")
+	flag.StringVar(&roundTemplates, "round-templates", "", "comma-separated list of predefined round templates for rounds to cycle through (payments-warmup, zkapp-heavy, max-cost-burst, churn-only, new-accounts)")
+	flag.IntVar(&p.ZkappSoftLimit, "zkapp-soft-limit", defaults.ZkappSoftLimit, "soft limit for number of zkapps to be taken to a block (-2 for no-op, -1 for reset, >=0 for setting a value)")
+	flag.StringVar(&mode, "mode", "default", "mode of generation: default (script), estimate (summary of cost and schedule of the experiment), stop-ratio-distribution or tps-distribution")
+	flag.IntVar(&nodes, "nodes", 0, "number of nodes (block producers if only block producers are stopped) in the network, used by estimate mode to compute numbers of nodes affected by stops")
+	flag.StringVar(&experimentFile, "experiment", "", "experiment file (YAML or JSON) to take params from, flags provided explicitly override params of the file")
+	flag.StringVar(&p.FundKeyPrefix, "fund-keys-dir", defaults.FundKeyPrefix, "Dir for generated fund key prefixes")
+	flag.StringVar(&p.PasswordEnv, "password-env", defaults.PasswordEnv, "Name of environment variable to read privkey password from")
+	flag.StringVar((*string)(&p.PaymentReceiver), "payment-receiver", "", "Mina PK receiving payments")
+	flag.StringVar(&p.ExperimentName, "experiment-name", defaults.ExperimentName, "Name of experiment")
+	flag.IntVar(&p.PrivkeysPerFundCmd, "privkeys-per-fund", defaults.PrivkeysPerFundCmd, "Number of private keys to use per fund command")
+	flag.IntVar(&p.GenerateFundKeys, "generate-privkeys", defaults.GenerateFundKeys, "Number of funding keys to generate from the private key")
+	flag.StringVar(&rotateKeys, "rotate-keys", "", "Comma-separated list of public keys to rotate")
+	flag.StringVar(&rotateServers, "rotate-servers", "", "Comma-separated list of servers for rotation")
+	flag.Float64Var(&p.RotationRatio, "rotate-ratio", defaults.RotationRatio, "Ratio of balance to rotate")
+	flag.BoolVar(&p.RotationPermutation, "rotate-permutation", defaults.RotationPermutation, "Whether to generate only permutation mappings for rotation")
+	flag.IntVar(&p.LargePauseMin, "large-pause", defaults.LargePauseMin, "duration of the large pause, minutes")
+	flag.IntVar(&p.LargePauseEveryNRounds, "large-pause-every", defaults.LargePauseEveryNRounds, "number of rounds in between large pauses")
+	flag.Float64Var(&p.MixMaxCostTpsRatio, "max-cost-mixed", defaults.MixMaxCostTpsRatio, mixMaxCostTpsRatioHelp)
+	flag.Uint64Var(&p.MaxBalanceChange, "max-balance-change", defaults.MaxBalanceChange, "Max balance change for zkapp account update")
+	flag.Uint64Var(&p.MinBalanceChange, "min-balance-change", defaults.MinBalanceChange, "Min balance change for zkapp account update")
+	flag.Uint64Var(&p.DeploymentFee, "deployment-fee", defaults.DeploymentFee, "Zkapp deployment fee")
+	flag.Uint64Var(&p.FundFee, "fund-fee", defaults.FundFee, "Funding tx fee")
+	flag.Uint64Var(&p.MinPaymentFee, "min-payment-fee", defaults.MinPaymentFee, "Min payment fee")
+	flag.Uint64Var(&p.MaxPaymentFee, "max-payment-fee", defaults.MaxPaymentFee, "Max payment fee")
+	flag.Uint64Var(&p.MinZkappFee, "min-zkapp-fee", defaults.MinZkappFee, "Min zkapp tx fee")
+	flag.Uint64Var(&p.MaxZkappFee, "max-zkapp-fee", defaults.MaxZkappFee, "Max zkapp tx fee")
+	flag.Uint64Var(&p.PaymentAmount, "payment-amount", defaults.PaymentAmount, "Payment amount")
+	flag.Int64Var(&p.Seed, "seed", defaults.Seed, "seed of random choices made by the generator, recorded in the script header (0 for a random seed)")
+	flag.StringVar(&timelinePrefix, "timeline", "", "when provided, timeline of the experiment is written to <prefix>.trace.json (Chrome trace-event format) and <prefix>.html (Gantt chart)")
+	flag.StringVar(&timelineStart, "timeline-start", "", "planned start of the experiment for the timeline, RFC3339 (current time by default)")
+	flag.StringVar(&orchestratorConfig, "orchestrator-config", "", "orchestrator config to take slot duration and genesis timestamp for the timeline from")
+	flag.Parse()
+	explicit := map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+	isSet := func(name string) bool {
+		_, has := explicit[name]
+		return has || experimentFile == ""
+	}
+	var err error
+	if experimentFile != "" {
+		if p, err = lib.LoadExperimentFile(experimentFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load experiment file: %v\n", err)
+			os.Exit(2)
+		}
+		// Flags provided explicitly override the experiment file
+		for name, value := range explicit {
+			flag.Set(name, value)
+		}
+	}
+	if flag.NArg() > 0 || experimentFile == "" {
+		p.Privkeys = flag.Args()
+	}
+	if isSet("load-profile") {
+		p.LoadProfile = lib.LoadProfile(loadProfile)
+	}
+
+	if roundTemplates != "" {
+		p.RoundTemplates = nil
+		for _, name := range strings.Split(roundTemplates, ",") {
+			p.RoundTemplates = append(p.RoundTemplates, lib.RoundTemplate{Name: name})
+		}
+	}
+	if rotateKeys != "" {
+		p.RotationKeys = strings.Split(rotateKeys, ",")
+	}
+	if rotateServers != "" {
+		p.RotationServers = strings.Split(rotateServers, ",")
+	}
+	if isSet("faults") {
+		if p.FaultRatios, err = lib.ParseFaultRatios(faultRatios); err != nil {
+			fmt.Fprintf(os.Stderr, "wrong faults: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	lib.ValidateAndExitEarly(&p)
+
+	if p.Seed == 0 {
+		p.Seed = lib.NewSeed()
+	}
+	switch mode {
+	case "stop-ratio-distribution":
+		for i := 0; i < 10000; i++ {
+			v := lib.SampleStopRatio(p.Rand(), p.MinStopRatio, p.MaxStopRatio)
+			fmt.Printf("Sampled stop ratio: %f\n", v)
+			fmt.Println(v)
+		}
+		return
+	case "tps-distribution":
+		for i := 0; i < 10000; i++ {
+			v := lib.SampleTps(p.Rand(), p.BaseTps, p.StressTps)
+			fmt.Println(v)
+		}
+		return
+	case "estimate":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(lib.Estimate(&p, nodes)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing estimate: %v\n", err)
+			os.Exit(3)
+		}
+		return
+	case "default":
+	default:
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	writeComment := func(comment string) {
+		if err := encoder.Encode(comment); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing comment: %v\n", err)
+			os.Exit(3)
+		}
+	}
+
+	writeCommand := func(cmd lib.GeneratedCommand) {
+		comment := cmd.Comment()
+		if comment != "" {
+			writeComment(comment)
+		}
+		if err := encoder.Encode(cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing command: %v\n", err)
+			os.Exit(3)
+		}
+	}
+	plan := lib.Encode(&p, writeCommand, writeComment)
+	if timelinePrefix != "" {
+		clock, err := timelineClock(timelineStart, orchestratorConfig)
+		if err == nil {
+			err = writeTimeline(timelinePrefix, p.ExperimentName, plan.Timeline(), clock)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing timeline: %v\n", err)
+			os.Exit(3)
+		}
+	}
+}
+
+func timelineClock(start, orchestratorConfig string) (lib.TimelineClock, error) {
+	clock := lib.TimelineClock{Start: time.Now()}
+	if start != "" {
+		var err error
+		if clock.Start, err = time.Parse(time.RFC3339, start); err != nil {
+			return clock, fmt.Errorf("wrong timeline start: %v", err)
+		}
+	}
+	if orchestratorConfig != "" {
+		data, err := os.ReadFile(orchestratorConfig)
+		if err != nil {
+			return clock, err
+		}
+		var config lib.OrchestratorConfig
+		if err := json.Unmarshal(data, &config); err != nil {
+			return clock, fmt.Errorf("failed to decode orchestrator config: %v", err)
+		}
+		clock.SlotDurationMs = config.SlotDurationMs
+		clock.GenesisTimestamp = time.Time(config.GenesisTimestamp)
+	}
+	return clock, nil
+}
+
+func writeTimeline(prefix, title string, events []lib.TimelineEvent, clock lib.TimelineClock) error {
+	traceFile, err := os.Create(prefix + ".trace.json")
+	if err != nil {
+		return err
+	}
+	defer traceFile.Close()
+	if err := lib.WriteTraceEvents(traceFile, events, clock); err != nil {
+		return err
+	}
+	htmlFile, err := os.Create(prefix + ".html")
+	if err != nil {
+		return err
+	}
+	defer htmlFile.Close()
+	return lib.WriteTimelineHTML(htmlFile, title, events, clock)
+}
@@ -4,11 +4,15 @@ import (
 	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Khan/genqlient/graphql"
 )
@@ -25,7 +29,10 @@ type Authenticator struct {
 }
 
 func (doer *DoerWithStatus) Do(req *http.Request) (*http.Response, error) {
+	operation := gqlOperation(req)
+	start := time.Now()
 	resp, err := doer.Doer.Do(req)
+	observeGqlRequest(operation, start, resp, err)
 	if err == nil {
 		doer.LastStatusCode = resp.StatusCode
 	}
@@ -34,8 +41,10 @@ func (doer *DoerWithStatus) Do(req *http.Request) (*http.Response, error) {
 
 type SequentialAuthenticator struct {
 	authenticator *Authenticator
-	uuid          string
-	seqno         uint16
+	// Requests are sent one at a time for the node to receive them in order of sequence numbers
+	mu    sync.Mutex
+	uuid  string
+	seqno uint16
 }
 
 func NewAuthenticator(sk ed25519.PrivateKey, doer graphql.Doer) *Authenticator {
@@ -76,6 +85,8 @@ func (client *Authenticator) Do(req *http.Request) (*http.Response, error) {
 var _ graphql.Doer = (*Authenticator)(nil)
 
 func (client *SequentialAuthenticator) Do(req *http.Request) (*http.Response, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
 	body, err := readBody(req)
 	if err != nil {
 		return nil, err
@@ -126,35 +137,71 @@ func NewGqlClient(config Config, addr NodeAddress) (*NodeEntry, error) {
 	}, nil
 }
 
-func GetGqlClient(config Config, addr NodeAddress) (graphql.Client, *int, error) {
-	if entry, has := config.NodeData[addr]; has {
-		return entry.Client, entry.LastStatusCode, nil
+// GetNodeEntry returns the node's entry from the registry,
+// authenticating the node if it's missing or expired
+func GetNodeEntry(config Config, addr NodeAddress) (NodeEntry, error) {
+	if entry, has := config.Nodes.Lookup(addr); has {
+		return entry, nil
 	}
 	entry, err := NewGqlClient(config, addr)
+	if err != nil {
+		return NodeEntry{}, err
+	}
+	config.Nodes.Set(addr, *entry)
+	return *entry, nil
+}
+
+func GetGqlClient(config Config, addr NodeAddress) (graphql.Client, *int, error) {
+	entry, err := GetNodeEntry(config, addr)
 	if err != nil {
 		return nil, nil, err
 	}
-	config.NodeData[addr] = *entry
 	return entry.Client, entry.LastStatusCode, nil
 }
 
+// isNodeFailure tells whether the error of a request means the node is unavailable,
+// as opposed to errors returned by an available node
+func isNodeFailure(err error, lastCode int) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) || lastCode != http.StatusOK
+}
+
 func wrapGqlRequest(config Config, nodeAddress NodeAddress, perform func(client graphql.Client) (any, error)) (any, error) {
-	client, lastCode, err := GetGqlClient(config, nodeAddress)
-	if err != nil {
-		return "", fmt.Errorf("failed to create a client for %s: %v", nodeAddress, err)
-	}
-	resp, err := perform(client)
-	if err != nil && *lastCode == 412 {
-		config.Log.Infof("received sequencing error code (412), retrying request to %s, error: %v", nodeAddress, err)
-		delete(config.NodeData, nodeAddress)
-		var client graphql.Client
-		client, _, err = GetGqlClient(config, nodeAddress)
+	var resp any
+	err := retry(config.Ctx, config.Log, config.Retry.graphql(), "send request to "+string(nodeAddress), func(int) error {
+		client, lastCode, err := GetGqlClient(config, nodeAddress)
 		if err != nil {
-			return "", fmt.Errorf("failed to create a replacement client for %s: %v", nodeAddress, err)
+			config.Nodes.ReportFailure(nodeAddress, err)
+			return fmt.Errorf("failed to create a client for %s: %v", nodeAddress, err)
 		}
 		resp, err = perform(client)
+		if err != nil && *lastCode == 412 {
+			config.Log.Infof("received sequencing error code (412), retrying request to %s, error: %v", nodeAddress, err)
+			gqlReauths.Inc()
+			config.Nodes.Expire(nodeAddress)
+			client, lastCode, err = GetGqlClient(config, nodeAddress)
+			if err != nil {
+				config.Nodes.ReportFailure(nodeAddress, err)
+				return fmt.Errorf("failed to create a replacement client for %s: %v", nodeAddress, err)
+			}
+			resp, err = perform(client)
+		}
+		if err != nil && isNodeFailure(err, *lastCode) {
+			if config.Nodes.ReportFailure(nodeAddress, err) {
+				config.Log.Warnf("evicted %s from the registry after repeated failures", nodeAddress)
+			}
+		} else {
+			config.Nodes.ReportSuccess(nodeAddress)
+		}
+		if err != nil && *lastCode != http.StatusOK {
+			return &httpStatusError{code: *lastCode, err: err}
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
 	}
-	return resp, err
+	return resp, nil
 }
 
 func SchedulePaymentsGql(config Config, nodeAddress NodeAddress, input PaymentsDetails) (string, error) {
@@ -189,7 +236,8 @@ func ScheduleZkappCommands(config Config, nodeAddress NodeAddress, input ZkappCo
 
 func SlotsWonGql(config Config, nodeAddress NodeAddress) ([]int, bool, error) {
 	resp, err := wrapGqlRequest(config, nodeAddress, func(client graphql.Client) (any, error) {
-		if config.NodeData[nodeAddress].IsBlockProducer {
+		entry, _ := config.Nodes.Get(nodeAddress)
+		if entry.IsBlockProducer {
 			return slotsWon(config.Ctx, client)
 		}
 		return nil, nil
@@ -207,6 +255,9 @@ func UpdateGatingGql(config Config, nodeAddress NodeAddress, input GatingUpdate)
 	_, err := wrapGqlRequest(config, nodeAddress, func(client graphql.Client) (any, error) {
 		return updateGating(config.Ctx, client, input)
 	})
+	if jErr := config.Gating.Record(nodeAddress, input, err); jErr != nil {
+		config.Log.Warnf("failed to journal gating update for %s: %v", nodeAddress, jErr)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update gating for %s: %v", nodeAddress, err)
 	}
@@ -233,3 +284,23 @@ func SetZkappSoftLimitGql(config Config, nodeAddress NodeAddress, limit *int) (*
 	}
 	return resp.(*setZkappSoftLimitResponse).ZkAppCommandLimit, nil
 }
+
+func InternalLogsGql(config Config, nodeAddress NodeAddress, startLogId int) ([]InternalLog, error) {
+	resp, err := wrapGqlRequest(config, nodeAddress, func(client graphql.Client) (any, error) {
+		return internalLogs(config.Ctx, client, startLogId)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying internal logs from %s (start %d): %v", nodeAddress, startLogId, err)
+	}
+	return resp.(*internalLogsResponse).InternalLogs, nil
+}
+
+func FlushInternalLogsGql(config Config, nodeAddress NodeAddress, endLogId int) (string, error) {
+	resp, err := wrapGqlRequest(config, nodeAddress, func(client graphql.Client) (any, error) {
+		return flushInternalLogs(config.Ctx, client, endLogId)
+	})
+	if err != nil {
+		return "", fmt.Errorf("error flushing internal logs on %s (end %d): %v", nodeAddress, endLogId, err)
+	}
+	return resp.(*flushInternalLogsResponse).FlushInternalLogs, nil
+}
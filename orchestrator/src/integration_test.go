@@ -0,0 +1,258 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"itn_orchestrator/mocknode"
+
+	"github.com/btcsuite/btcutil/base58"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+func startMockNodes(t *testing.T, opts ...mocknode.Options) (Config, []*mocknode.Node) {
+	nodes := make([]*mocknode.Node, len(opts))
+	inventory := make([]MiniMetaToBeSaved, len(opts))
+	for i, o := range opts {
+		nodes[i] = mocknode.New(o)
+		t.Cleanup(nodes[i].Close)
+		inventory[i] = MiniMetaToBeSaved{
+			RemoteAddr:         nodes[i].Host(),
+			GraphqlControlPort: nodes[i].Port(),
+			Submitter:          fmt.Sprintf("submitter%d", i),
+		}
+	}
+	filename := filepath.Join(t.TempDir(), "inventory.json")
+	data, err := json.Marshal(inventory)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filename, data, 0644))
+	_, sk, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	config := Config{
+		Ctx:         context.Background(),
+		Log:         logging.Logger("test"),
+		Submissions: InventorySource{File: filename},
+		Sk:          sk,
+		Nodes:       NewNodeRegistry(NodeRegistryConfig{}),
+		Receipts:    NewReceiptTracker(),
+		Retry: RetryConfig{Graphql: &RetryPolicy{
+			MaxAttempts: 2, BaseDelayMs: 1, RetryOnStatus: []int{503},
+		}},
+	}
+	return config, nodes
+}
+
+func runMockScript(t *testing.T, config Config, outCache outCacheT, step int, cmds ...string) {
+	var prevAction BatchAction
+	var actionAccum []ActionIO
+	handlePrevAction := func() error {
+		err := RunBatch(config, prevAction, actionAccum)
+		prevAction, actionAccum = nil, nil
+		return err
+	}
+	err := RunActions(json.NewDecoder(strings.NewReader(strings.Join(cmds, "\n"))), config, outCache, config.Log, step,
+		handlePrevAction, &actionAccum, ResolutionConfig{OutputCache: outCache}, &prevAction, nil)
+	require.NoError(t, err)
+	if prevAction != nil {
+		require.NoError(t, handlePrevAction())
+	}
+}
+
+func TestMockNodesExperiment(t *testing.T) {
+	config, nodes := startMockNodes(t,
+		mocknode.Options{Libp2pPort: 10501, PeerId: "peer0"},
+		mocknode.Options{Libp2pPort: 10502, PeerId: "peer1", IsBlockProducer: true, SlotsWon: []int{3, 7}},
+	)
+	outCache := EmptyOutputCache()
+	runMockScript(t, config, outCache, 0,
+		`{"action":"discovery","params":{}}`,
+		`{"action":"discovery","params":{"onlyBPs":true}}`,
+		`{"action":"payments","params":{"experimentName":"exp","tps":2,"minTps":1,"durationMin":1,"maxFee":2,"minFee":1,"amount":3,`+
+			`"receiver":"B62qpPita1s7Dbnr7MVb3UK8fdssZixL1a4536aeMYxbTJEtRGGyS8U","feePayers":["EKE1","EKE2"],`+
+			`"nodes":{"type":"output","step":0,"name":"participant"}}}`,
+		`{"action":"zkapp-txs","params":{"experimentName":"exp","tps":1,"minTps":1,"durationMin":1,"maxFee":2,"minFee":1,"gap":10,`+
+			`"feePayers":["EKE3"],"nodes":{"type":"output","step":1,"name":"participant"}}}`,
+		`{"action":"slots-won","params":{"nodes":{"type":"output","step":0,"name":"participant"}}}`,
+	)
+	bp := NodeAddress(nodes[1].Address())
+	require.ElementsMatch(t, []NodeAddress{NodeAddress(nodes[0].Address()), bp}, outputValues[NodeAddress](t, outCache, 0, "participant"))
+	require.Equal(t, []NodeAddress{bp}, outputValues[NodeAddress](t, outCache, 1, "participant"))
+	require.Len(t, nodes[0].Scheduled(), 1)
+	require.Len(t, nodes[1].Scheduled(), 2)
+	require.Len(t, config.Receipts.Outstanding(), 3)
+	require.Equal(t, []SlotsWonOutput{{Address: bp, SlotsWon: []int{3, 7}}}, outputValues[SlotsWonOutput](t, outCache, 4, "slotsWon"))
+
+	// Restarted node forgets sequence numbers, requests are re-authenticated
+	nodes[0].ResetSequencing("restarted-uuid")
+	// A transient failure is retried according to the policy
+	nodes[1].Fail("updateGating", mocknode.Failure{Status: 503, Message: "unavailable", Times: 1})
+	runMockScript(t, config, outCache, 5,
+		`{"action":"stop","params":{"receipts":{"type":"output","step":2,"name":"receipt"}}}`,
+		`{"action":"isolate","params":{"nodes":{"type":"output","step":0,"name":"participant"}}}`,
+		`{"action":"reset-gating","params":{"nodes":{"type":"output","step":0,"name":"participant"}}}`,
+		`{"action":"set-zkapp-soft-limit","params":{"limit":5,"nodes":{"type":"output","step":1,"name":"participant"}}}`,
+		`{"action":"stop-daemon","params":{"nodes":{"type":"output","step":1,"name":"participant"}}}`,
+	)
+	require.Len(t, nodes[0].Stopped(), 1)
+	require.Len(t, nodes[1].Scheduled(), 1)
+	require.Len(t, config.Receipts.Outstanding(), 1)
+	require.Equal(t, []NodeAddress{bp}, outputValues[NodeAddress](t, outCache, 8, "participant"))
+	require.False(t, nodes[0].DaemonStopped())
+	require.True(t, nodes[1].DaemonStopped())
+
+	var gatingUpdates []mocknode.Request
+	for _, m := range nodes[1].Mutations() {
+		if m.Operation == "updateGating" {
+			gatingUpdates = append(gatingUpdates, m)
+		}
+	}
+	// One update by isolate, two by reset-gating
+	require.Len(t, gatingUpdates, 3)
+	var isolate GatingUpdate
+	require.NoError(t, json.Unmarshal(gatingUpdates[0].Variables, &struct {
+		Input *GatingUpdate `json:"input"`
+	}{&isolate}))
+	require.True(t, isolate.Isolate)
+	require.Equal(t, []NetworkPeer{{Host: nodes[0].Host(), Libp2pPort: 10501, PeerId: "peer0"}}, isolate.TrustedPeers)
+}
+
+// Concurrent scheduling creates clients of nodes not contacted before at the same time,
+// run with -race to check the node registry is safe for concurrent use
+func TestMockNodesConcurrentScheduling(t *testing.T) {
+	config, nodes := startMockNodes(t, mocknode.Options{}, mocknode.Options{}, mocknode.Options{}, mocknode.Options{})
+	config.Scheduling = SchedulingConfig{Concurrency: 4}
+	addrs := make([]NodeAddress, len(nodes))
+	for i, node := range nodes {
+		addrs[i] = NodeAddress(node.Address())
+	}
+	// Nodes are listed explicitly, so that no client is created before scheduling
+	nodesJson, err := json.Marshal(addrs)
+	require.NoError(t, err)
+	runMockScript(t, config, EmptyOutputCache(), 0,
+		`{"action":"payments","params":{"experimentName":"exp","tps":4,"minTps":1,"durationMin":1,"maxFee":2,"minFee":1,"amount":3,`+
+			`"receiver":"B62qpPita1s7Dbnr7MVb3UK8fdssZixL1a4536aeMYxbTJEtRGGyS8U","feePayers":["EKE1","EKE2","EKE3","EKE4"],`+
+			`"nodes":`+string(nodesJson)+`}}`,
+	)
+	for _, node := range nodes {
+		require.Len(t, node.Scheduled(), 1)
+	}
+	require.Len(t, config.Receipts.Outstanding(), 4)
+}
+
+// writeKeyfile writes a private key sealed with an empty password
+// and cheap argon2 limits, so that loading many keys is fast
+func writeKeyfile(t *testing.T, fname string) {
+	var sk [32]byte
+	var nonce [24]byte
+	salt := make([]byte, 16)
+	for _, b := range [][]byte{sk[:], nonce[:], salt} {
+		_, err := rand.Read(b)
+		require.NoError(t, err)
+	}
+	const mem, ops = 64 * 1024, 1
+	var key [32]byte
+	copy(key[:], argon2.Key(nil, salt, ops, mem/1024, 1, 32))
+	data, err := json.Marshal(map[string]any{
+		"box_primitive": "xsalsa20poly1305",
+		"pw_primitive":  "argon2i",
+		"nonce":         base58.CheckEncode(nonce[:], '\x02'),
+		"pwsalt":        base58.CheckEncode(salt, '\x02'),
+		"pwdiff":        []int{mem, ops},
+		"ciphertext":    base58.CheckEncode(secretbox.Seal(nil, sk[:], &nonce, &key), '\x02'),
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(fname, data, 0600))
+}
+
+// fakeMinaExec creates a script mimicking `mina advanced itn-create-accounts`:
+// it writes the requested number of key files with the key prefix
+func fakeMinaExec(t *testing.T) string {
+	dir := t.TempDir()
+	keyfile := filepath.Join(dir, "key")
+	writeKeyfile(t, keyfile)
+	exec := filepath.Join(dir, "mina")
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --key-prefix) prefix="$2" ;;
+    --num-accounts) num="$2" ;;
+  esac
+  shift
+done
+mkdir -p "$(dirname "$prefix")"
+i=0
+while [ $i -lt $num ]; do
+  cp ` + keyfile + ` "$prefix-$i"
+  i=$((i+1))
+done
+`
+	require.NoError(t, os.WriteFile(exec, []byte(script), 0755))
+	return exec
+}
+
+func TestMockNodesGeneratedExperiment(t *testing.T) {
+	config, nodes := startMockNodes(t,
+		mocknode.Options{Libp2pPort: 10501, PeerId: "peer0"},
+		mocknode.Options{Libp2pPort: 10502, PeerId: "peer1"},
+		mocknode.Options{Libp2pPort: 10503, PeerId: "peer2"},
+		mocknode.Options{Libp2pPort: 10504, PeerId: "peer3", IsBlockProducer: true},
+	)
+	config.MinaExec = fakeMinaExec(t)
+	p := someParams()
+	p.Seed = 1
+	p.Rounds = 2
+	p.RoundDurationMin = 2
+	p.PauseMin = 1
+	p.SenderRatio = 0.5
+	p.MinStopRatio = 0.5
+	p.MaxStopRatio = 0.5
+	p.GenerateFundKeys = 2
+	p.FundKeyPrefix = filepath.Join(t.TempDir(), "fund_keys")
+	var cmds []string
+	Encode(&p, func(cmd GeneratedCommand) {
+		// Waits of the generated script are shortened
+		if cmd.Action == (WaitAction{}).Name() {
+			cmd.Params = WaitParams{}
+		}
+		data, err := json.Marshal(cmd)
+		require.NoError(t, err)
+		cmds = append(cmds, string(data))
+	}, func(string) {})
+	runMockScript(t, config, EmptyOutputCache(), 0, cmds...)
+
+	mutations := map[string]int{}
+	for _, node := range nodes {
+		for _, m := range node.Mutations() {
+			mutations[m.Operation]++
+		}
+	}
+	// Each round two senders are sampled, first round sends payments and zkapps,
+	// second round sends max-cost zkapps only
+	require.Equal(t, 2, mutations["schedulePayments"])
+	require.Equal(t, 4, mutations["scheduleZkappCommands"])
+	require.Len(t, config.Receipts.Outstanding(), 6)
+	// Each round both non-senders are stopped, one of them with cleaning
+	require.Equal(t, 4, mutations["stopDaemon"])
+	// Zkapp soft limit is set on every node at start and in each round
+	require.Equal(t, 3*len(nodes), mutations["setZkappSoftLimit"])
+}
+
+func outputValues[T any](t *testing.T, outCache outCacheT, step int, name string) []T {
+	entry, has := outCache.lookup("", step, name)
+	require.True(t, has, "%d %s", step, name)
+	res := make([]T, len(entry.Values))
+	for i, v := range entry.Values {
+		require.NoError(t, json.Unmarshal(v, &res[i]))
+	}
+	return res
+}
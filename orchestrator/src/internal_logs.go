@@ -0,0 +1,185 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type InternalLog = internalLogsInternalLogsItnLog
+
+// Time given to the final collection of internal logs after the experiment is over
+const finalInternalLogsTimeout = 2 * time.Minute
+
+const defaultInternalLogsDir = "internal-logs"
+
+// InternalLogsCollector writes internal logs of nodes to per-node JSONL
+// files in its directory, records already written are never repeated
+type InternalLogsCollector struct {
+	dir      string
+	interval time.Duration
+	mu       sync.Mutex
+	// Least log ID not written yet, per node
+	nextIds map[NodeAddress]int
+}
+
+func NewInternalLogsCollector(dir string, interval time.Duration) *InternalLogsCollector {
+	if dir == "" {
+		dir = defaultInternalLogsDir
+	}
+	return &InternalLogsCollector{dir: dir, interval: interval, nextIds: map[NodeAddress]int{}}
+}
+
+// ExperimentInternalLogsDir returns the directory internal logs of the experiment
+// are written to, a subdirectory of dir (or of the default directory if dir is empty).
+// Names that aren't a single path element are rejected, so that logs stay within dir.
+func ExperimentInternalLogsDir(dir, experimentName string) (string, error) {
+	if experimentName == "" || experimentName == "." || experimentName == ".." ||
+		strings.ContainsAny(experimentName, `/\`) || filepath.Base(experimentName) != experimentName {
+		return "", fmt.Errorf("invalid experiment name for internal logs directory: %q", experimentName)
+	}
+	if dir == "" {
+		dir = defaultInternalLogsDir
+	}
+	return filepath.Join(dir, experimentName), nil
+}
+
+// Filename returns the file internal logs of the node are written to
+func (c *InternalLogsCollector) Filename(addr NodeAddress) string {
+	return filepath.Join(c.dir, strings.ReplaceAll(string(addr), ":", "_")+".jsonl")
+}
+
+// Collect retrieves internal logs of the node not collected before, appends them
+// to the node's file and flushes them from the node. Returns number of logs written.
+func (c *InternalLogsCollector) Collect(config Config, addr NodeAddress) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	start := c.nextIds[addr]
+	logs, err := InternalLogsGql(config, addr, start)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i].Id < logs[j].Id })
+	for len(logs) > 0 && logs[0].Id < start {
+		logs = logs[1:]
+	}
+	if len(logs) == 0 {
+		return 0, nil
+	}
+	if err := c.write(addr, logs); err != nil {
+		return 0, err
+	}
+	endId := logs[len(logs)-1].Id
+	c.nextIds[addr] = endId + 1
+	if _, err := FlushInternalLogsGql(config, addr, endId); err != nil {
+		return len(logs), err
+	}
+	return len(logs), nil
+}
+
+func (c *InternalLogsCollector) write(addr NodeAddress, logs []InternalLog) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create internal logs directory: %v", err)
+	}
+	filename := c.Filename(addr)
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open internal logs file %s: %v", filename, err)
+	}
+	encoder := json.NewEncoder(file)
+	for _, log := range logs {
+		if err := encoder.Encode(log); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write internal logs file %s: %v", filename, err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write internal logs file %s: %v", filename, err)
+	}
+	return nil
+}
+
+func (c *InternalLogsCollector) collectAll(config Config) {
+	for _, addr := range config.Nodes.Addresses() {
+		if n, err := c.Collect(config, addr); err != nil {
+			config.Log.Warnf("failed to collect internal logs from %s: %v", addr, err)
+		} else if n > 0 {
+			config.Log.Debugf("collected %d internal logs from %s", n, addr)
+		}
+	}
+}
+
+// Start launches background collection of internal logs from all known nodes,
+// if the collector has an interval configured. Returned function stops the
+// collection and performs the final one, it's meant to be called after the
+// experiment is over, hence the final collection doesn't use the context of the config.
+func (c *InternalLogsCollector) Start(config Config) (stop func()) {
+	if c == nil || c.interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				c.collectAll(config)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		ctx, cancelF := context.WithTimeout(context.Background(), finalInternalLogsTimeout)
+		defer cancelF()
+		config.Ctx = ctx
+		c.collectAll(config)
+	}
+}
+
+type CollectInternalLogsParams struct {
+	Nodes []NodeAddress `json:"nodes"`
+}
+
+type CollectInternalLogsAction struct{}
+
+func (CollectInternalLogsAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	var params CollectInternalLogsParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	if config.InternalLogs == nil {
+		return errors.New("internal logs collection isn't configured")
+	}
+	errs := []error{}
+	for _, addr := range params.Nodes {
+		n, err := config.InternalLogs.Collect(config, addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		config.Log.Infof("collected %d internal logs from %s", n, addr)
+		output("file", config.InternalLogs.Filename(addr), true, false)
+	}
+	return errors.Join(errs...)
+}
+
+func (CollectInternalLogsAction) Name() string { return "collect-internal-logs" }
+
+func (CollectInternalLogsAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: CollectInternalLogsParams{}, Outputs: []OutputSpec{{Name: "file", Kind: StringKind, Multi: true}}}
+}
+
+var _ DeclaredAction = CollectInternalLogsAction{}
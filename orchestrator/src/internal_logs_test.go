@@ -0,0 +1,78 @@
+package itn_orchestrator
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"itn_orchestrator/mocknode"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readInternalLogs(t *testing.T, filename string) []int {
+	file, err := os.Open(filename)
+	require.NoError(t, err)
+	defer file.Close()
+	ids := []int{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var log InternalLog
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &log))
+		ids = append(ids, log.Id)
+	}
+	require.NoError(t, scanner.Err())
+	return ids
+}
+
+func TestCollectInternalLogs(t *testing.T) {
+	config, nodes := startMockNodes(t, mocknode.Options{})
+	config.InternalLogs = NewInternalLogsCollector(t.TempDir(), 0)
+	node := nodes[0]
+	addr := NodeAddress(node.Address())
+	node.AddInternalLogs(
+		mocknode.InternalLog{Timestamp: "t0", Message: "a", Metadata: []mocknode.LogMetadatum{{Item: "k", Value: json.RawMessage(`{"x":1}`)}}},
+		mocknode.InternalLog{Timestamp: "t1", Message: "b"},
+		mocknode.InternalLog{Timestamp: "t2", Message: "c"},
+	)
+	outCache := EmptyOutputCache()
+	runMockScript(t, config, outCache, 0,
+		`{"action":"discovery","params":{}}`,
+		`{"action":"collect-internal-logs","params":{"nodes":{"type":"output","step":-1,"name":"participant"}}}`,
+	)
+	filename := config.InternalLogs.Filename(addr)
+	require.Equal(t, []string{filename}, outputValues[string](t, outCache, 1, "file"))
+	require.Equal(t, []int{0, 1, 2}, readInternalLogs(t, filename))
+	require.Empty(t, node.InternalLogs())
+
+	// Logs written before a failed flush aren't written again
+	node.AddInternalLogs(mocknode.InternalLog{Timestamp: "t3", Message: "d"})
+	node.Fail("flushInternalLogs", mocknode.Failure{Status: 500, Times: 1})
+	n, err := config.InternalLogs.Collect(config, addr)
+	require.Error(t, err)
+	require.Equal(t, 1, n)
+	require.Len(t, node.InternalLogs(), 1)
+
+	// Background collection performs the final collection when stopped
+	node.AddInternalLogs(mocknode.InternalLog{Timestamp: "t4", Message: "e"})
+	config.InternalLogs.interval = time.Hour
+	stop := config.InternalLogs.Start(config)
+	stop()
+	require.Equal(t, []int{0, 1, 2, 3, 4}, readInternalLogs(t, filename))
+	require.Empty(t, node.InternalLogs())
+}
+
+func TestExperimentInternalLogsDir(t *testing.T) {
+	dir, err := ExperimentInternalLogsDir("", "exp1")
+	require.NoError(t, err)
+	require.Equal(t, "internal-logs/exp1", dir)
+	dir, err = ExperimentInternalLogsDir("/var/logs", "exp2")
+	require.NoError(t, err)
+	require.Equal(t, "/var/logs/exp2", dir)
+	for _, name := range []string{"", ".", "..", "../../etc", "a/../../b", `..\x`, "/abs"} {
+		_, err := ExperimentInternalLogsDir("/var/logs", name)
+		require.Error(t, err, name)
+	}
+}
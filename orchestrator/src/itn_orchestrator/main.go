@@ -3,15 +3,18 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	logging "github.com/ipfs/go-log/v2"
 
 	lib "itn_orchestrator"
 )
 
-func run(configFilename string) error {
+func run(configFilename, checkpointFilename, resumeFilename string) error {
 	orchestratorConfig := lib.LoadAppConfig(configFilename)
 	logging.SetupLogging(logging.Config{
 		Format: logging.ColorizedOutput,
@@ -22,18 +25,63 @@ func run(configFilename string) error {
 	})
 	log := logging.Logger("itn orchestrator")
 	log.Infof("Launching logging: %v", logging.GetSubsystems())
-	config := lib.SetupConfig(context.Background(), orchestratorConfig, log)
+	if err := lib.RegisterPlugins(orchestratorConfig.Plugins); err != nil {
+		return err
+	}
+	if orchestratorConfig.MetricsAddress != "" {
+		lib.ServeMetrics(orchestratorConfig.MetricsAddress, log)
+	}
+	scriptSeed, script, err := lib.ReadScriptSeed(os.Stdin)
+	if err != nil {
+		return err
+	}
+	if orchestratorConfig.Seed == 0 {
+		// Random choices are reproduced along with the script
+		orchestratorConfig.Seed = scriptSeed
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	config := lib.SetupConfig(ctx, orchestratorConfig, log)
+	stopInternalLogs := config.InternalLogs.Start(config)
+	defer stopInternalLogs()
+	// Injected faults shouldn't outlive the experiment
+	defer func() {
+		if err := lib.RevertOutstandingFaults(config); err != nil {
+			log.Errorf("Failed to revert some of injected faults: %v", err)
+		}
+	}()
 	outCache := lib.EmptyOutputCache()
 	rconfig := lib.ResolutionConfig{
 		OutputCache: outCache,
 	}
-	inDecoder := json.NewDecoder(os.Stdin)
+	inDecoder := json.NewDecoder(script)
 	step := 0
 	var prevAction lib.BatchAction
 	var actionAccum []lib.ActionIO
+	if resumeFilename != "" {
+		cp, err := lib.LoadCheckpoint(resumeFilename)
+		if err != nil {
+			return err
+		}
+		prevAction, actionAccum, err = cp.Restore(outCache, log)
+		if err != nil {
+			return err
+		}
+		if err := lib.SkipCommands(inDecoder, cp.Step); err != nil {
+			return err
+		}
+		step = cp.Step
+		log.Infof("Resuming from step %d", step)
+	}
+	var checkpoint func(int) error
+	if checkpointFilename != "" {
+		checkpoint = func(step int) error {
+			return lib.WriteCheckpoint(checkpointFilename, lib.MakeCheckpoint(step, outCache, prevAction, actionAccum))
+		}
+	}
 	handlePrevAction := func() error {
 		log.Infof("Performing steps %s (%d-%d)", prevAction.Name(), step, len(actionAccum)-step)
-		err := prevAction.RunMany(config, actionAccum)
+		err := lib.RunBatch(config, prevAction, actionAccum)
 		if err != nil {
 			return &lib.OrchestratorError{
 				Message: fmt.Sprintf("Error running steps %d-%d: %v", step, len(actionAccum)-step, err),
@@ -45,27 +93,66 @@ func run(configFilename string) error {
 		return nil
 	}
 
-	lib.RunActions(inDecoder, config, outCache, log, step,
-		handlePrevAction, &actionAccum, rconfig, &prevAction)
-	if prevAction != nil {
-		if err := handlePrevAction(); err != nil {
-			return &lib.OrchestratorError{
+	err = lib.RunActions(inDecoder, config, outCache, log, step,
+		handlePrevAction, &actionAccum, rconfig, &prevAction, checkpoint)
+	if err == nil && prevAction != nil && ctx.Err() == nil {
+		if err = handlePrevAction(); err != nil {
+			err = &lib.OrchestratorError{
 				Message: fmt.Sprintf("Error running previous action: %v", err),
 				Code:    9,
 			}
 		}
 	}
-	return nil
+	if err != nil || ctx.Err() != nil {
+		// Scheduled transactions shouldn't outlive an aborted experiment
+		if stopErr := lib.StopOutstandingTransactions(config); stopErr != nil {
+			log.Errorf("Failed to stop some of scheduled transactions: %v", stopErr)
+		}
+	}
+	return err
+}
+
+// check statically checks the script read from stdin and reports all issues found,
+// plugin actions are known to the check only when the config is provided
+func check(configFilename string) int {
+	if configFilename != "" {
+		if err := lib.RegisterPlugins(lib.LoadAppConfig(configFilename).Plugins); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 11
+		}
+	}
+	issues, err := lib.CheckScript(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 5
+	}
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+	}
+	if len(issues) > 0 {
+		return 6
+	}
+	return 0
 }
 
 func main() {
-	if len(os.Args) < 2 {
+	var checkpointFilename, resumeFilename string
+	flag.StringVar(&checkpointFilename, "checkpoint", "", "File to write checkpoint to after every step (defaults to the -resume file)")
+	flag.StringVar(&resumeFilename, "resume", "", "Checkpoint file to resume the experiment from")
+	flag.Parse()
+	if flag.Arg(0) == "check" {
+		os.Exit(check(flag.Arg(1)))
+	}
+	if flag.NArg() < 1 {
 		os.Stderr.WriteString("No config provided")
 		os.Exit(1)
 		return
 	}
-	configFilename := os.Args[1]
-	if err := run(configFilename); err != nil {
+	if checkpointFilename == "" {
+		checkpointFilename = resumeFilename
+	}
+	configFilename := flag.Arg(0)
+	if err := run(configFilename, checkpointFilename, resumeFilename); err != nil {
 		os.Stderr.WriteString(fmt.Sprintf("Error: %v", err))
 		os.Exit(1)
 	}
@@ -129,6 +129,9 @@ func LoadPrivateKeyFiles(log logging.StandardLogger, params KeyloaderParams, out
 type KeyloaderAction struct{}
 
 func (KeyloaderAction) Name() string { return "load-keys" }
+func (KeyloaderAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: KeyloaderParams{}, Outputs: []OutputSpec{{Name: "key", Kind: StringKind, Multi: true}}}
+}
 func (KeyloaderAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
 	var params KeyloaderParams
 	if err := json.Unmarshal(rawParams, &params); err != nil {
@@ -139,4 +142,4 @@ func (KeyloaderAction) Run(config Config, rawParams json.RawMessage, output Outp
 	})
 }
 
-var _ Action = KeyloaderAction{}
+var _ DeclaredAction = KeyloaderAction{}
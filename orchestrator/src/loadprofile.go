@@ -0,0 +1,118 @@
+package itn_orchestrator
+
+import (
+	"math"
+	"math/rand"
+)
+
+type LoadProfile string
+
+const (
+	// Constant tps sampled for each round from gaussian distribution
+	// between base and stress tps
+	GaussianLoad LoadProfile = "gaussian"
+	// Linear ramp from base to stress tps over a round
+	RampLoad LoadProfile = "ramp"
+	// Staircase of equal steps from base to stress tps
+	StepLoad LoadProfile = "step"
+	// Base tps with periodic spikes to stress tps
+	SpikeLoad LoadProfile = "spike"
+	// Sinusoidal load between base and stress tps
+	SineLoad LoadProfile = "sine"
+)
+
+var LoadProfiles = []LoadProfile{GaussianLoad, RampLoad, StepLoad, SpikeLoad, SineLoad}
+
+func IsLoadProfile(profile LoadProfile) bool {
+	for _, p := range LoadProfiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// loadStep is a part of a round during which transactions are sent with a constant tps
+type loadStep struct {
+	StartMin, DurationMin int
+	Tps                   float64
+}
+
+// equalSteps splits the round into n steps of (almost) equal duration,
+// with tps of each step computed from the step's index
+func equalSteps(roundMin, n int, tps func(i int) float64) []loadStep {
+	res := make([]loadStep, n)
+	start := 0
+	for i := range res {
+		end := (i + 1) * roundMin / n
+		res[i] = loadStep{StartMin: start, DurationMin: end - start, Tps: tps(i)}
+		start = end
+	}
+	return res
+}
+
+// loadSteps splits a round into steps of constant tps according to the load profile
+func (p *GenParams) loadSteps(rng *rand.Rand) []loadStep {
+	roundMin := p.RoundDurationMin
+	base, stress := p.BaseTps, p.StressTps
+	switch p.LoadProfile {
+	case RampLoad:
+		n := p.LoadSteps
+		return equalSteps(roundMin, n, func(i int) float64 {
+			// Tps in the middle of the step
+			return base + (stress-base)*(float64(i)+0.5)/float64(n)
+		})
+	case StepLoad:
+		n := p.LoadSteps
+		return equalSteps(roundMin, n, func(i int) float64 {
+			if n == 1 {
+				return base
+			}
+			return base + (stress-base)*float64(i)/float64(n-1)
+		})
+	case SineLoad:
+		n := p.LoadSteps
+		return equalSteps(roundMin, n, func(i int) float64 {
+			t := (float64(i) + 0.5) * float64(roundMin) / float64(n)
+			return base + (stress-base)*(1-math.Cos(2*math.Pi*t/float64(p.LoadPeriodMin)))/2
+		})
+	case SpikeLoad:
+		res := []loadStep{}
+		for start := 0; start < roundMin; start += p.LoadPeriodMin {
+			spikeStart := min(start+p.LoadPeriodMin-p.LoadSpikeMin, roundMin)
+			res = append(res, loadStep{StartMin: start, DurationMin: spikeStart - start, Tps: base})
+			if spikeStart < roundMin {
+				spikeEnd := min(start+p.LoadPeriodMin, roundMin)
+				res = append(res, loadStep{StartMin: spikeStart, DurationMin: spikeEnd - spikeStart, Tps: stress})
+			}
+		}
+		return res
+	default:
+		return []loadStep{{DurationMin: roundMin, Tps: SampleTps(rng, base, stress)}}
+	}
+}
+
+// validLoadProfile checks that the load profile can be applied to the round
+func validLoadProfile(p *GenParams) bool {
+	switch p.LoadProfile {
+	case "", GaussianLoad:
+		return true
+	case RampLoad, StepLoad:
+		if p.LoadSteps <= 0 || p.LoadSteps > p.RoundDurationMin {
+			return false
+		}
+	case SineLoad:
+		if p.LoadSteps <= 0 || p.LoadSteps > p.RoundDurationMin || p.LoadPeriodMin <= 0 {
+			return false
+		}
+	case SpikeLoad:
+		if p.LoadSpikeMin <= 0 || p.LoadSpikeMin >= p.LoadPeriodMin {
+			return false
+		}
+	default:
+		return false
+	}
+	// Partitioning is performed within the first step
+	steps := p.loadSteps(nil)
+	return p.Partitions <= 1 || len(steps) == 1 || (len(steps) > 0 && steps[0].DurationMin >= p.PartitionMin)
+}
@@ -0,0 +1,102 @@
+package itn_orchestrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const loadProfileErrorMsg = "wrong load profile: profile should be known, number of steps should be positive and at most round duration, spikes should be shorter than their period, partitioning should fit into the first step"
+
+func TestLoadSteps(t *testing.T) {
+	p := someParams()
+	p.RoundDurationMin = 50
+	p.BaseTps, p.StressTps = 1, 2
+	p.LoadSteps = 4
+	p.LoadPeriodMin = 20
+	p.LoadSpikeMin = 5
+	tpsOf := func(profile LoadProfile) []float64 {
+		p.LoadProfile = profile
+		steps := p.loadSteps(nil)
+		end := 0
+		res := make([]float64, len(steps))
+		for i, step := range steps {
+			require.Equal(t, end, step.StartMin, "steps of %s are contiguous", profile)
+			require.Positive(t, step.DurationMin)
+			end += step.DurationMin
+			res[i] = step.Tps
+		}
+		require.Equal(t, p.RoundDurationMin, end, "steps of %s cover the round", profile)
+		return res
+	}
+	require.InDeltaSlice(t, []float64{1.125, 1.375, 1.625, 1.875}, tpsOf(RampLoad), 1e-9)
+	require.InDeltaSlice(t, []float64{1, 4.0 / 3, 5.0 / 3, 2}, tpsOf(StepLoad), 1e-9)
+	require.Equal(t, []float64{1, 2, 1, 2, 1}, tpsOf(SpikeLoad))
+	sine := tpsOf(SineLoad)
+	require.Len(t, sine, 4)
+	for _, tps := range sine {
+		require.True(t, tps >= 1 && tps <= 2)
+	}
+}
+
+func TestGenerateLoadProfile(t *testing.T) {
+	p := someParams()
+	p.Rounds = 2
+	p.StopsPerRound = 3
+	p.Seed = 1
+	p.LoadProfile = RampLoad
+	p.LoadSteps = 5
+	p.Partitions = 2
+	p.PartitionMin = 10
+	require.NotContains(t, ValidateAndCollectErrors(&p), loadProfileErrorMsg)
+	requireValidGeneratedScript(t, p)
+
+	round := p.Generate(0)
+	var paymentTps []float64
+	var zkappSteps []ZkappSubParams
+	experimentNames := map[string]struct{}{}
+	for _, cmd := range round.Commands {
+		if params, ok := cmd.Params.(PaymentRefParams); ok {
+			require.Equal(t, 10, params.DurationMin)
+			paymentTps = append(paymentTps, params.Tps)
+			experimentNames[params.ExperimentName] = struct{}{}
+		}
+		if params, ok := cmd.Params.(ZkappRefParams); ok {
+			zkappSteps = append(zkappSteps, params.ZkappSubParams)
+		}
+	}
+	require.Len(t, paymentTps, 5)
+	require.IsIncreasing(t, paymentTps)
+	// Memos of each step are distinct
+	require.Len(t, experimentNames, 5)
+	require.Contains(t, experimentNames, p.ExperimentName+"-0-4")
+	// Funds are budgeted for the peak tps over the whole round
+	peakParams := PaymentSubParams{Tps: paymentTps[4], MinTps: p.MinTps, DurationMin: p.RoundDurationMin, MaxFee: p.MaxPaymentFee, Amount: p.PaymentAmount}
+	keys, amount := PaymentKeygenRequirements(p.Gap, peakParams)
+	require.Equal(t, keys, round.PaymentFundCommand.Num)
+	require.Equal(t, amount, round.PaymentFundCommand.Amount)
+	// and for deployment of zkapps by each step
+	require.Len(t, zkappSteps, 5)
+	peakZkapps := zkappSteps[4]
+	peakZkapps.DurationMin = p.RoundDurationMin
+	_, _, _, initBalance := ZkappBalanceRequirements(peakZkapps.Tps, peakZkapps)
+	keys, amount = ZkappKeygenRequirements(initBalance, peakZkapps, 5)
+	require.Equal(t, keys, round.ZkappFundCommand.Num)
+	require.Equal(t, amount, round.ZkappFundCommand.Amount)
+	_, singleDeployment := ZkappKeygenRequirements(initBalance, peakZkapps, 1)
+	require.Greater(t, amount, singleDeployment)
+
+	p.PartitionMin = 11
+	require.Contains(t, ValidateAndCollectErrors(&p), loadProfileErrorMsg)
+	p.LoadProfile = "square"
+	require.Contains(t, ValidateAndCollectErrors(&p), loadProfileErrorMsg)
+
+	// Spike profile has no steps in an empty round
+	p.LoadProfile = SpikeLoad
+	p.LoadPeriodMin = 10
+	p.LoadSpikeMin = 2
+	p.RoundDurationMin = 0
+	errs := ValidateAndCollectErrors(&p)
+	require.Contains(t, errs, loadProfileErrorMsg)
+	require.Contains(t, errs, "round duration should be positive")
+}
@@ -0,0 +1,137 @@
+package itn_orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	stepsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orchestrator_steps_total",
+		Help: "Number of steps performed, by action",
+	}, []string{"action"})
+	currentStep = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "orchestrator_current_step",
+		Help: "Top-level step being performed",
+	})
+	currentAction = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "orchestrator_current_action",
+		Help: "Set to 1 for the action being performed",
+	}, []string{"action"})
+	actionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orchestrator_action_duration_seconds",
+		Help:    "Duration of steps (or batches of steps), by action",
+		Buckets: prometheus.ExponentialBuckets(0.1, 4, 10),
+	}, []string{"action"})
+	gqlRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orchestrator_graphql_requests_total",
+		Help: "GraphQL requests sent to nodes, by operation and HTTP status code",
+	}, []string{"operation", "status"})
+	gqlLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orchestrator_graphql_request_duration_seconds",
+		Help:    "Latency of GraphQL requests sent to nodes, by operation",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+	gqlReauths = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orchestrator_graphql_reauth_total",
+		Help: "Re-authentications after a sequencing error (412)",
+	})
+	discoveredNodes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "orchestrator_discovered_nodes",
+		Help: "Nodes found by the last discovery, by kind (bp or non_bp)",
+	}, []string{"kind"})
+	scheduledTps = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "orchestrator_scheduled_tps",
+		Help: "TPS of transactions scheduled on the node and not stopped yet, by kind (payments or zkapp)",
+	}, []string{"node", "kind"})
+	minaCliFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orchestrator_mina_cli_failures_total",
+		Help: "Failed invocations of mina CLI, by command",
+	}, []string{"command"})
+)
+
+// MetricsHandler serves metrics in Prometheus format
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ServeMetrics serves metrics on /metrics path of the address in background
+func ServeMetrics(address string, log logging.StandardLogger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	go func() {
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.Errorf("Metrics server failed: %v", err)
+		}
+	}()
+}
+
+func observeStep(action string, scope *Scope, step int) {
+	stepsTotal.WithLabelValues(action).Inc()
+	if scope == nil {
+		currentStep.Set(float64(step))
+	}
+	currentAction.Reset()
+	currentAction.WithLabelValues(action).Set(1)
+}
+
+func observeActionDuration(action string, start time.Time) {
+	actionDuration.WithLabelValues(action).Observe(time.Since(start).Seconds())
+}
+
+// gqlOperation extracts name of the operation from a GraphQL request body
+func gqlOperation(req *http.Request) string {
+	if req.GetBody == nil {
+		return "unknown"
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return "unknown"
+	}
+	var gqlReq struct {
+		OperationName string `json:"operationName"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&gqlReq); err != nil || gqlReq.OperationName == "" {
+		return "unknown"
+	}
+	return gqlReq.OperationName
+}
+
+func observeGqlRequest(operation string, start time.Time, resp *http.Response, err error) {
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	gqlRequests.WithLabelValues(operation, status).Inc()
+	gqlLatency.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func observeScheduled(node NodeAddress, kind string, tps float64) {
+	scheduledTps.WithLabelValues(string(node), kind).Add(tps)
+}
+
+// observeStopped removes scheduled tps of the kind on the node,
+// of all kinds if the kind is unknown (e.g. receipt was imported from an older run)
+func observeStopped(node NodeAddress, kind string) {
+	if kind == "" {
+		scheduledTps.DeletePartialMatch(prometheus.Labels{"node": string(node)})
+		return
+	}
+	scheduledTps.DeleteLabelValues(string(node), kind)
+}
+
+func observeMinaCliFailure(args []string) {
+	command := ""
+	if len(args) >= 2 {
+		command = args[0] + " " + args[1]
+	}
+	minaCliFailures.WithLabelValues(command).Inc()
+}
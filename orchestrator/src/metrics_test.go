@@ -0,0 +1,38 @@
+package itn_orchestrator
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGqlRequestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}))
+	defer server.Close()
+	doer := DoerWithStatus{Doer: http.DefaultClient}
+	before := testutil.ToFloat64(gqlRequests.WithLabelValues("slotsWon", "412"))
+	req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte(`{"query":"query slotsWon {}","operationName":"slotsWon"}`)))
+	require.NoError(t, err)
+	resp, err := doer.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, doer.LastStatusCode)
+	require.Equal(t, before+1, testutil.ToFloat64(gqlRequests.WithLabelValues("slotsWon", "412")))
+}
+
+func TestScheduledTpsMetrics(t *testing.T) {
+	node := NodeAddress("metrics-test:1")
+	observeScheduled(node, "payments", 0.5)
+	observeScheduled(node, "zkapp", 0.25)
+	observeStopped(node, "payments")
+	require.Equal(t, 0.25, testutil.ToFloat64(scheduledTps.WithLabelValues(string(node), "zkapp")))
+	require.Zero(t, testutil.ToFloat64(scheduledTps.WithLabelValues(string(node), "payments")))
+	observeStopped(node, "")
+	require.Zero(t, testutil.ToFloat64(scheduledTps.WithLabelValues(string(node), "zkapp")))
+}
@@ -36,18 +36,24 @@ func (WaitAction) Run(config Config, rawParams json.RawMessage, output OutputF)
 	if params.Slot > 0 {
 		at := config.GenesisTimestamp.Add(time.Millisecond*time.Duration(config.SlotDurationMs)*time.Duration(params.Slot) + delay)
 		delay = time.Until(at)
-		if delay > 0 {
-			time.Sleep(delay)
+	}
+	if delay > 0 {
+		select {
+		case <-config.Ctx.Done():
+			return config.Ctx.Err()
+		case <-time.After(delay):
 		}
-	} else {
-		time.Sleep(delay)
 	}
 	return nil
 }
 
 func (WaitAction) Name() string { return "wait" }
 
-var _ Action = WaitAction{}
+func (WaitAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: WaitParams{}, Outputs: nil}
+}
+
+var _ DeclaredAction = WaitAction{}
 
 // NextPermutation generates the next permutation of the
 // sortable collection x in lexical order.  It returns false
@@ -132,7 +138,11 @@ func (JoinAction) Run(config Config, rawParams json.RawMessage, output OutputF)
 
 func (JoinAction) Name() string { return "join" }
 
-var _ Action = JoinAction{}
+func (JoinAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: JoinParams{}, Outputs: []OutputSpec{{Name: "group", Kind: StringKind, Multi: true}}}
+}
+
+var _ DeclaredAction = JoinAction{}
 
 type ExceptParams struct {
 	Group  []NodeAddress `json:"group"`
@@ -161,7 +171,11 @@ func (ExceptAction) Run(config Config, rawParams json.RawMessage, output OutputF
 
 func (ExceptAction) Name() string { return "except" }
 
-var _ Action = ExceptAction{}
+func (ExceptAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: ExceptParams{}, Outputs: []OutputSpec{{Name: "group", Kind: StringKind, Multi: true}}}
+}
+
+var _ DeclaredAction = ExceptAction{}
 
 type SampleParams struct {
 	Group  []NodeAddress `json:"group"`
@@ -187,7 +201,7 @@ func (SampleAction) Run(config Config, rawParams json.RawMessage, output OutputF
 	}
 	group := params.Group
 	groupLen := len(group)
-	rand.Shuffle(groupLen, func(i, j int) {
+	config.rand().Shuffle(groupLen, func(i, j int) {
 		group[i], group[j] = group[j], group[i]
 	})
 	for i, r := range params.Ratios {
@@ -201,46 +215,42 @@ func (SampleAction) Run(config Config, rawParams json.RawMessage, output OutputF
 
 func (SampleAction) Name() string { return "sample" }
 
-var _ Action = SampleAction{}
+func (SampleAction) Schema(params RawParams) ActionSchema {
+	outputs := []OutputSpec{{Name: "rest", Kind: ArrayKind}}
+	var ratios []float64
+	if err := json.Unmarshal(params["ratios"], &ratios); err == nil {
+		for i := range ratios {
+			outputs = append(outputs, OutputSpec{Name: fmt.Sprintf("group%d", i+1), Kind: ArrayKind})
+		}
+	} else {
+		outputs = append(outputs, OutputSpec{Name: "group", Kind: ArrayKind, Indexed: true})
+	}
+	return ActionSchema{Params: SampleParams{}, Outputs: outputs}
+}
+
+var _ DeclaredAction = SampleAction{}
 
-func selectNodes(tps, minTps float64, nodes []NodeAddress) (float64, []NodeAddress) {
+func selectNodes(rng *rand.Rand, tps, minTps float64, nodes []NodeAddress) (float64, []NodeAddress) {
 	nodesF := math.Floor(tps / minTps)
 	nodesMax := int(nodesF)
 	if nodesMax >= len(nodes) {
 		return tps / float64(len(nodes)), nodes
 	}
-	rand.Shuffle(len(nodes), func(i, j int) {
+	rng.Shuffle(len(nodes), func(i, j int) {
 		nodes[i], nodes[j] = nodes[j], nodes[i]
 	})
 	return tps / nodesF, nodes[:nodesMax]
 }
 
-func retryOnMultipleServers(servers []string, ctx context.Context, serverIx int, commandName string, log logging.StandardLogger, try func(string) error) (err error) {
-	server := ""
-	if len(servers) > 0 {
-		server = servers[serverIx]
-	}
-	for retryPause := 1; retryPause <= 16; retryPause = retryPause * 2 {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		err = try(server)
-		if err == nil {
-			break
-		}
-		if retryPause <= 8 {
-			log.Warnf("Failed to run %s command, retrying in %d minutes: %s", commandName, retryPause, err)
-			time.Sleep(time.Duration(retryPause) * time.Minute)
-		}
+// retryOnMultipleServers retries the command switching to the next server on each attempt
+func retryOnMultipleServers(servers []string, ctx context.Context, serverIx int, commandName string, log logging.StandardLogger, policy RetryPolicy, try func(string) error) error {
+	return retry(ctx, log, policy, "run "+commandName+" command", func(attempt int) error {
+		server := ""
 		if len(servers) > 0 {
-			serverIx = (serverIx + 1) % len(servers)
-			server = servers[serverIx]
+			server = servers[(serverIx+attempt)%len(servers)]
 		}
-	}
-	return
+		return try(server)
+	})
 }
 
 func listKeyfiles(dir string) ([]string, error) {
@@ -304,7 +314,11 @@ func execMina(ctx context.Context, minaExec string, args, env []string) error {
 	cmd.Stdout = os.Stderr
 	cmd.Stderr = os.Stderr
 	cmd.Env = env
-	return cmd.Run()
+	err := cmd.Run()
+	if err != nil {
+		observeMinaCliFailure(args)
+	}
+	return err
 }
 
 func execScanMina(ctx context.Context, minaExec string, args, env []string, scan func(*bufio.Scanner) error) error {
@@ -325,13 +339,17 @@ func execScanMina(ctx context.Context, minaExec string, args, env []string, scan
 	}
 	scanner := bufio.NewScanner(stdout)
 	scanner.Split(bufio.ScanWords)
-	if err := scan(scanner); err != nil {
-		return err
+	err := scan(scanner)
+	if err == nil {
+		err = stdout.Close()
 	}
-	if err := stdout.Close(); err != nil {
-		return err
+	if err == nil {
+		err = cmd.Wait()
+	}
+	if err != nil {
+		observeMinaCliFailure(args)
 	}
-	return cmd.Wait()
+	return err
 }
 
 func SetOrDefault[T any](src *T, dst *T, def T) {
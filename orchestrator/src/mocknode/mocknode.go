@@ -0,0 +1,398 @@
+// Package mocknode provides in-process fake ITN nodes serving the subset of
+// the ITN GraphQL API used by the orchestrator, for tests only
+package mocknode
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Options struct {
+	// Public keys allowed to access the node, any key is allowed when empty
+	AllowedKeys     []ed25519.PublicKey
+	Uuid            string
+	Libp2pPort      uint16
+	PeerId          string
+	IsBlockProducer bool
+	SlotsWon        []int
+}
+
+// Failure is returned instead of handling an operation
+type Failure struct {
+	// HTTP status code of the response, 200 to return a GraphQL error
+	Status  int
+	Message string
+	// Number of requests to fail, zero to fail all of them
+	Times int
+}
+
+// InternalLog is a record of internal logs of the node
+type InternalLog struct {
+	Id        int            `json:"id"`
+	Timestamp string         `json:"timestamp"`
+	Message   string         `json:"message"`
+	Metadata  []LogMetadatum `json:"metadata"`
+	Process   *string        `json:"process"`
+}
+
+type LogMetadatum struct {
+	Item  string          `json:"item"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Request is a recorded mutation with its variables
+type Request struct {
+	Operation string
+	Variables json.RawMessage
+}
+
+type Node struct {
+	opts   Options
+	server *httptest.Server
+
+	mu            sync.Mutex
+	seqnos        map[string]uint16
+	failures      map[string]*Failure
+	handles       int
+	scheduled     map[string]Request
+	stopped       []string
+	mutations     []Request
+	daemonStopped bool
+	internalLogs  []InternalLog
+	nextLogId     int
+}
+
+// New starts a fake node, it should be closed after use
+func New(opts Options) *Node {
+	if opts.Uuid == "" {
+		opts.Uuid = "mock-uuid"
+	}
+	n := &Node{opts: opts, seqnos: map[string]uint16{}, failures: map[string]*Failure{}, scheduled: map[string]Request{}}
+	n.server = httptest.NewServer(http.HandlerFunc(n.serve))
+	return n
+}
+
+func (n *Node) Close() { n.server.Close() }
+
+// Host returns address of the node without the port
+func (n *Node) Host() string {
+	host, _, _ := strings.Cut(n.Address(), ":")
+	return host
+}
+
+// Port returns port the GraphQL API is served on
+func (n *Node) Port() uint16 {
+	_, port, _ := strings.Cut(n.Address(), ":")
+	p, _ := strconv.ParseUint(port, 10, 16)
+	return uint16(p)
+}
+
+// Address returns host:port of the node, GraphQL API is served on /graphql path
+func (n *Node) Address() string {
+	return strings.TrimPrefix(n.server.URL, "http://")
+}
+
+// Fail makes requests of the operation fail
+func (n *Node) Fail(operation string, failure Failure) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failures[operation] = &failure
+}
+
+// ResetSequencing emulates restart of the node: sequence numbers of
+// all signers are forgotten, requests fail with 412 until re-authentication
+func (n *Node) ResetSequencing(uuid string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.opts.Uuid = uuid
+	n.seqnos = map[string]uint16{}
+}
+
+// Scheduled returns scheduled transactions that weren't stopped, by handle
+func (n *Node) Scheduled() map[string]Request {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	res := make(map[string]Request, len(n.scheduled))
+	for h, r := range n.scheduled {
+		res[h] = r
+	}
+	return res
+}
+
+// Stopped returns handles of stopped transactions in order of stopping
+func (n *Node) Stopped() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string{}, n.stopped...)
+}
+
+// Mutations returns all successful mutations in order they were received
+func (n *Node) Mutations() []Request {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Request{}, n.mutations...)
+}
+
+// AddInternalLogs appends records to the internal logs of the node, IDs are assigned by the node
+func (n *Node) AddInternalLogs(logs ...InternalLog) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, log := range logs {
+		log.Id = n.nextLogId
+		n.nextLogId++
+		n.internalLogs = append(n.internalLogs, log)
+	}
+}
+
+// InternalLogs returns internal logs that weren't flushed
+func (n *Node) InternalLogs() []InternalLog {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]InternalLog{}, n.internalLogs...)
+}
+
+func (n *Node) DaemonStopped() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.daemonStopped
+}
+
+type gqlRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+}
+
+type gqlError struct {
+	Message string `json:"message"`
+}
+
+type gqlResponse struct {
+	Data   any        `json:"data,omitempty"`
+	Errors []gqlError `json:"errors,omitempty"`
+}
+
+func writeJson(w http.ResponseWriter, status int, resp gqlResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...any) {
+	writeJson(w, status, gqlResponse{Errors: []gqlError{{Message: fmt.Sprintf(format, args...)}}})
+}
+
+// authorization is a parsed Authorization header:
+// Signature <pk> <sig>[ ; Sequencing <uuid> <seqno>]
+type authorization struct {
+	pk        ed25519.PublicKey
+	pkStr     string
+	sig       []byte
+	sequenced bool
+	uuid      string
+	seqno     uint16
+}
+
+func parseAuthorization(header string) (auth authorization, err error) {
+	parts := strings.Fields(header)
+	if len(parts) != 3 && len(parts) != 7 {
+		return auth, fmt.Errorf("malformed authorization header")
+	}
+	if parts[0] != "Signature" {
+		return auth, fmt.Errorf("unexpected authorization scheme %s", parts[0])
+	}
+	auth.pkStr = parts[1]
+	pk, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(pk) != ed25519.PublicKeySize {
+		return auth, fmt.Errorf("malformed public key")
+	}
+	auth.pk = pk
+	if auth.sig, err = base64.StdEncoding.DecodeString(parts[2]); err != nil {
+		return auth, fmt.Errorf("malformed signature")
+	}
+	if len(parts) == 7 {
+		if parts[3] != ";" || parts[4] != "Sequencing" {
+			return auth, fmt.Errorf("malformed sequencing")
+		}
+		seqno, err := strconv.ParseUint(parts[6], 10, 16)
+		if err != nil {
+			return auth, fmt.Errorf("malformed sequence number")
+		}
+		auth.sequenced, auth.uuid, auth.seqno = true, parts[5], uint16(seqno)
+	}
+	return auth, nil
+}
+
+func (n *Node) allowed(pk ed25519.PublicKey) bool {
+	if len(n.opts.AllowedKeys) == 0 {
+		return true
+	}
+	for _, k := range n.opts.AllowedKeys {
+		if k.Equal(pk) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/graphql" || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body: %v", err)
+		return
+	}
+	var req gqlRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "failed to decode request: %v", err)
+		return
+	}
+	auth, err := parseAuthorization(r.Header.Get("Authorization"))
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "%v", err)
+		return
+	}
+	if !n.allowed(auth.pk) {
+		writeError(w, http.StatusUnauthorized, "public key is not allowed")
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	msg := body
+	if auth.sequenced {
+		msg = make([]byte, 2+len(auth.uuid)+len(body))
+		binary.BigEndian.PutUint16(msg, auth.seqno)
+		copy(msg[2:], auth.uuid)
+		copy(msg[2+len(auth.uuid):], body)
+	}
+	if !ed25519.Verify(auth.pk, msg, auth.sig) {
+		writeError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	if req.OperationName != "auth" {
+		if !auth.sequenced {
+			writeError(w, http.StatusUnauthorized, "sequencing is required")
+			return
+		}
+		if auth.uuid != n.opts.Uuid || auth.seqno != n.seqnos[auth.pkStr] {
+			writeError(w, http.StatusPreconditionFailed, "sequence number mismatch")
+			return
+		}
+		n.seqnos[auth.pkStr]++
+	}
+	if f := n.failures[req.OperationName]; f != nil {
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				delete(n.failures, req.OperationName)
+			}
+		}
+		writeError(w, f.Status, "%s", f.Message)
+		return
+	}
+	data, err := n.handle(req, auth.pkStr)
+	if err != nil {
+		writeError(w, http.StatusOK, "%v", err)
+		return
+	}
+	writeJson(w, http.StatusOK, gqlResponse{Data: data})
+}
+
+// handle performs the operation, called under the lock
+func (n *Node) handle(req gqlRequest, signer string) (any, error) {
+	record := Request{Operation: req.OperationName, Variables: req.Variables}
+	switch req.OperationName {
+	case "auth":
+		return map[string]any{"auth": map[string]any{
+			"serverUuid":           n.opts.Uuid,
+			"signerSequenceNumber": strconv.Itoa(int(n.seqnos[signer])),
+			"libp2pPort":           strconv.Itoa(int(n.opts.Libp2pPort)),
+			"peerId":               n.opts.PeerId,
+			"isBlockProducer":      n.opts.IsBlockProducer,
+		}}, nil
+	case "slotsWon":
+		if !n.opts.IsBlockProducer {
+			return nil, fmt.Errorf("not a block producer")
+		}
+		return map[string]any{"slotsWon": n.opts.SlotsWon}, nil
+	case "internalLogs":
+		var vars struct {
+			StartLogId int `json:"startLogId"`
+		}
+		if err := json.Unmarshal(req.Variables, &vars); err != nil {
+			return nil, err
+		}
+		logs := []InternalLog{}
+		for _, log := range n.internalLogs {
+			if log.Id >= vars.StartLogId {
+				logs = append(logs, log)
+			}
+		}
+		return map[string]any{"internalLogs": logs}, nil
+	case "flushInternalLogs":
+		var vars struct {
+			EndLogId int `json:"endLogId"`
+		}
+		if err := json.Unmarshal(req.Variables, &vars); err != nil {
+			return nil, err
+		}
+		remaining := []InternalLog{}
+		for _, log := range n.internalLogs {
+			if log.Id > vars.EndLogId {
+				remaining = append(remaining, log)
+			}
+		}
+		n.internalLogs = remaining
+		n.mutations = append(n.mutations, record)
+		return map[string]any{"flushInternalLogs": "flushed"}, nil
+	case "schedulePayments", "scheduleZkappCommands":
+		n.handles++
+		handle := fmt.Sprintf("handle-%d", n.handles)
+		n.scheduled[handle] = record
+		n.mutations = append(n.mutations, record)
+		return map[string]any{req.OperationName: handle}, nil
+	case "stopScheduledTransactions":
+		var vars struct {
+			Handle string `json:"handle"`
+		}
+		if err := json.Unmarshal(req.Variables, &vars); err != nil {
+			return nil, err
+		}
+		if _, has := n.scheduled[vars.Handle]; !has {
+			return nil, fmt.Errorf("unknown handle %s", vars.Handle)
+		}
+		delete(n.scheduled, vars.Handle)
+		n.stopped = append(n.stopped, vars.Handle)
+		n.mutations = append(n.mutations, record)
+		return map[string]any{"stopScheduledTransactions": "stopped"}, nil
+	case "updateGating":
+		n.mutations = append(n.mutations, record)
+		return map[string]any{"updateGating": "ok"}, nil
+	case "stopDaemon":
+		n.daemonStopped = true
+		n.mutations = append(n.mutations, record)
+		return map[string]any{"stopDaemon": "ok"}, nil
+	case "setZkappSoftLimit":
+		var vars struct {
+			Limit *int `json:"limit"`
+		}
+		if err := json.Unmarshal(req.Variables, &vars); err != nil {
+			return nil, err
+		}
+		n.mutations = append(n.mutations, record)
+		return map[string]any{"zkAppCommandLimit": vars.Limit}, nil
+	}
+	return nil, fmt.Errorf("unknown operation %s", req.OperationName)
+}
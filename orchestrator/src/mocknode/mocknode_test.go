@@ -0,0 +1,55 @@
+package mocknode
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorization(t *testing.T) {
+	pk, sk, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherPk, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	node := New(Options{AllowedKeys: []ed25519.PublicKey{pk}, Uuid: "u1"})
+	defer node.Close()
+	pkStr := base64.StdEncoding.EncodeToString(pk)
+	post := func(body string, header string) int {
+		req, err := http.NewRequest(http.MethodPost, "http://"+node.Address()+"/graphql", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", header)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	sign := func(msg []byte) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(sk, msg))
+	}
+	sequenced := func(body string, uuid string, seqno uint16) string {
+		msg := binary.BigEndian.AppendUint16(nil, seqno)
+		msg = append(append(msg, uuid...), body...)
+		return "Signature " + pkStr + " " + sign(msg) + " ; Sequencing " + uuid + " " + strconv.Itoa(int(seqno))
+	}
+	auth := `{"operationName":"auth"}`
+	slots := `{"operationName":"slotsWon"}`
+	require.Equal(t, http.StatusOK, post(auth, "Signature "+pkStr+" "+sign([]byte(auth))))
+	require.Equal(t, http.StatusUnauthorized, post(auth, "Signature "+pkStr+" "+sign([]byte(slots))))
+	otherSig := "Signature " + base64.StdEncoding.EncodeToString(otherPk) + " " + sign([]byte(auth))
+	require.Equal(t, http.StatusUnauthorized, post(auth, otherSig))
+	require.Equal(t, http.StatusUnauthorized, post(slots, "Signature "+pkStr+" "+sign([]byte(slots))))
+	require.Equal(t, http.StatusOK, post(slots, sequenced(slots, "u1", 0)))
+	require.Equal(t, http.StatusPreconditionFailed, post(slots, sequenced(slots, "u1", 0)))
+	require.Equal(t, http.StatusOK, post(slots, sequenced(slots, "u1", 1)))
+	node.ResetSequencing("u2")
+	require.Equal(t, http.StatusPreconditionFailed, post(slots, sequenced(slots, "u1", 2)))
+	node.Fail("slotsWon", Failure{Status: http.StatusServiceUnavailable, Times: 1})
+	require.Equal(t, http.StatusServiceUnavailable, post(slots, sequenced(slots, "u2", 0)))
+	require.Equal(t, http.StatusOK, post(slots, sequenced(slots, "u2", 1)))
+}
@@ -0,0 +1,208 @@
+package itn_orchestrator
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of the node registry
+const (
+	defaultNodeTtl         = 30 * time.Minute
+	defaultNodeMaxFailures = 5
+)
+
+// NodeRegistryConfig controls how long connections to nodes are cached
+type NodeRegistryConfig struct {
+	// Seconds after authentication when a node is re-authenticated, 30 minutes by default
+	TtlSec int `json:"ttlSec,omitempty"`
+	// Number of consecutive failed requests after which a node is evicted, 5 by default
+	MaxFailures int `json:"maxFailures,omitempty"`
+}
+
+// NodeHealth describes the recent history of requests to a node
+type NodeHealth struct {
+	AuthenticatedAt time.Time
+	LastSeen        time.Time
+	LastError       time.Time
+	LastErrorMsg    string
+	// Failed requests since the last successful one
+	Failures int
+}
+
+type registryEntry struct {
+	entry  NodeEntry
+	health NodeHealth
+}
+
+// NodeRegistry is a thread-safe cache of authenticated connections to nodes.
+// Methods of a nil registry behave as of an empty one that doesn't store entries.
+type NodeRegistry struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	maxFailures int
+	entries     map[NodeAddress]*registryEntry
+	now         func() time.Time
+}
+
+func NewNodeRegistry(config NodeRegistryConfig) *NodeRegistry {
+	r := &NodeRegistry{
+		ttl:         time.Duration(config.TtlSec) * time.Second,
+		maxFailures: config.MaxFailures,
+		entries:     map[NodeAddress]*registryEntry{},
+		now:         time.Now,
+	}
+	if r.ttl == 0 {
+		r.ttl = defaultNodeTtl
+	}
+	if r.maxFailures == 0 {
+		r.maxFailures = defaultNodeMaxFailures
+	}
+	return r
+}
+
+// Lookup returns the node's entry if it was authenticated within the TTL
+func (r *NodeRegistry) Lookup(addr NodeAddress) (NodeEntry, bool) {
+	if r == nil {
+		return NodeEntry{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, has := r.entries[addr]
+	if !has || r.now().Sub(e.health.AuthenticatedAt) >= r.ttl {
+		return NodeEntry{}, false
+	}
+	return e.entry, true
+}
+
+// Get returns the node's entry regardless of its TTL,
+// e.g. to read node's libp2p parameters
+func (r *NodeRegistry) Get(addr NodeAddress) (NodeEntry, bool) {
+	if r == nil {
+		return NodeEntry{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, has := r.entries[addr]
+	if !has {
+		return NodeEntry{}, false
+	}
+	return e.entry, true
+}
+
+// Set stores the entry of a just authenticated node
+func (r *NodeRegistry) Set(addr NodeAddress, entry NodeEntry) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	e, has := r.entries[addr]
+	if !has {
+		e = &registryEntry{}
+		r.entries[addr] = e
+	}
+	e.entry = entry
+	e.health.AuthenticatedAt = now
+	e.health.LastSeen = now
+}
+
+// Expire makes the node re-authenticated on the next request, e.g. after it was restarted
+func (r *NodeRegistry) Expire(addr NodeAddress) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, has := r.entries[addr]; has {
+		e.health.AuthenticatedAt = time.Time{}
+	}
+}
+
+func (r *NodeRegistry) Remove(addr NodeAddress) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, addr)
+}
+
+// ReportSuccess records a successful request to the node
+func (r *NodeRegistry) ReportSuccess(addr NodeAddress) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, has := r.entries[addr]; has {
+		e.health.LastSeen = r.now()
+		e.health.Failures = 0
+	}
+}
+
+// ReportFailure records a failed request to the node and evicts
+// the node when it failed too many times in a row. Returns true
+// if the node was evicted.
+func (r *NodeRegistry) ReportFailure(addr NodeAddress, err error) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, has := r.entries[addr]
+	if !has {
+		return false
+	}
+	e.health.LastError = r.now()
+	e.health.LastErrorMsg = err.Error()
+	e.health.Failures++
+	if e.health.Failures >= r.maxFailures {
+		delete(r.entries, addr)
+		return true
+	}
+	return false
+}
+
+func (r *NodeRegistry) Health(addr NodeAddress) (NodeHealth, bool) {
+	if r == nil {
+		return NodeHealth{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, has := r.entries[addr]
+	if !has {
+		return NodeHealth{}, false
+	}
+	return e.health, true
+}
+
+// Addresses returns addresses of all nodes in the registry in sorted order
+func (r *NodeRegistry) Addresses() []NodeAddress {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]NodeAddress, 0, len(r.entries))
+	for addr := range r.entries {
+		res = append(res, addr)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// Entries returns a snapshot of all entries of the registry regardless of their TTL
+func (r *NodeRegistry) Entries() map[NodeAddress]NodeEntry {
+	res := map[NodeAddress]NodeEntry{}
+	if r == nil {
+		return res
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for addr, e := range r.entries {
+		res[addr] = e.entry
+	}
+	return res
+}
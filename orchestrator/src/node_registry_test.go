@@ -0,0 +1,75 @@
+package itn_orchestrator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"itn_orchestrator/mocknode"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeRegistry(t *testing.T) {
+	r := NewNodeRegistry(NodeRegistryConfig{TtlSec: 60, MaxFailures: 2})
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	r.Set("a:1", NodeEntry{PeerId: "pa"})
+	r.Set("b:1", NodeEntry{PeerId: "pb"})
+	require.Equal(t, []NodeAddress{"a:1", "b:1"}, r.Addresses())
+
+	now = now.Add(time.Minute)
+	_, fresh := r.Lookup("a:1")
+	require.False(t, fresh, "entry is expired after TTL")
+	entry, has := r.Get("a:1")
+	require.True(t, has, "expired entry is still available")
+	require.Equal(t, "pa", entry.PeerId)
+	r.Set("a:1", NodeEntry{PeerId: "pa"})
+	_, fresh = r.Lookup("a:1")
+	require.True(t, fresh)
+	r.Expire("a:1")
+	_, fresh = r.Lookup("a:1")
+	require.False(t, fresh)
+
+	require.False(t, r.ReportFailure("b:1", errors.New("e1")))
+	r.ReportSuccess("b:1")
+	require.False(t, r.ReportFailure("b:1", errors.New("e2")))
+	health, _ := r.Health("b:1")
+	require.Equal(t, NodeHealth{AuthenticatedAt: now.Add(-time.Minute), LastSeen: now, LastError: now, LastErrorMsg: "e2", Failures: 1}, health)
+	require.True(t, r.ReportFailure("b:1", errors.New("e3")))
+	require.Equal(t, []NodeAddress{"a:1"}, r.Addresses())
+
+	var nilRegistry *NodeRegistry
+	nilRegistry.Set("a:1", NodeEntry{})
+	_, has = nilRegistry.Get("a:1")
+	require.False(t, has)
+}
+
+func TestNodeRegistryEviction(t *testing.T) {
+	config, nodes := startMockNodes(t, mocknode.Options{IsBlockProducer: true})
+	config.Nodes = NewNodeRegistry(NodeRegistryConfig{MaxFailures: 2})
+	config.Retry = RetryConfig{}
+	addr := NodeAddress(nodes[0].Address())
+	_, err := GetNodeEntry(config, addr)
+	require.NoError(t, err)
+
+	// Errors returned by an available node aren't failures of the node
+	nodes[0].Fail("slotsWon", mocknode.Failure{Status: 200, Message: "no slots"})
+	for i := 0; i < 3; i++ {
+		_, _, err = SlotsWonGql(config, addr)
+		require.Error(t, err)
+	}
+	health, has := config.Nodes.Health(addr)
+	require.True(t, has)
+	require.Zero(t, health.Failures)
+
+	nodes[0].Fail("slotsWon", mocknode.Failure{Status: 500, Message: "internal"})
+	_, _, err = SlotsWonGql(config, addr)
+	require.Error(t, err)
+	health, _ = config.Nodes.Health(addr)
+	require.Equal(t, 1, health.Failures)
+	_, _, err = SlotsWonGql(config, addr)
+	require.Error(t, err)
+	_, has = config.Nodes.Health(addr)
+	require.False(t, has, "node is evicted after repeated failures")
+}
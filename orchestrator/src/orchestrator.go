@@ -1,6 +1,8 @@
 package itn_orchestrator
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/ed25519"
 	"encoding/json"
@@ -18,11 +20,16 @@ import (
 )
 
 var actions map[string]Action
+var compositeActions map[string]CompositeAction
 
 func addAction(actions map[string]Action, action Action) {
 	actions[action.Name()] = action
 }
 
+func addCompositeAction(compositeActions map[string]CompositeAction, action CompositeAction) {
+	compositeActions[action.Name()] = action
+}
+
 func init() {
 	actions = map[string]Action{}
 	addAction(actions, DiscoveryAction{})
@@ -44,6 +51,19 @@ func init() {
 	addAction(actions, RotateAction{})
 	addAction(actions, SetZkappSoftLimitAction{})
 	addAction(actions, SlotsCoveredCheckAction{})
+	addAction(actions, CollectInternalLogsAction{})
+	addAction(actions, WaitReadyAction{})
+	addAction(actions, FilterAction{})
+	addAction(actions, PartitionAction{})
+	addAction(actions, HealAction{})
+	addAction(actions, SnapshotTopologyAction{})
+	addAction(actions, RestoreTopologyAction{})
+	addAction(actions, InjectFaultAction{})
+	addAction(actions, RevertFaultsAction{})
+	compositeActions = map[string]CompositeAction{}
+	addCompositeAction(compositeActions, ParallelAction{})
+	addCompositeAction(compositeActions, RepeatAction{})
+	addCompositeAction(compositeActions, IfAction{})
 }
 
 type AwsConfig struct {
@@ -74,18 +94,42 @@ func loadAwsCredentials(filename string, log logging.EventLogger) {
 }
 
 type OrchestratorConfig struct {
-	LogLevel         zapcore.Level `json:",omitempty"`
-	LogFile          string        `json:",omitempty"`
-	Key              itn_json_types.Ed25519Privkey
-	Aws              *AwsConfig `json:"aws,omitempty"`
-	OnlineURL        string     `json:"onlineURL,omitempty"`
-	FundDaemonPorts  []string   `json:",omitempty"`
-	MinaExec         string     `json:",omitempty"`
+	LogLevel  zapcore.Level `json:",omitempty"`
+	LogFile   string        `json:",omitempty"`
+	Key       itn_json_types.Ed25519Privkey
+	Aws       *AwsConfig `json:"aws,omitempty"`
+	OnlineURL string     `json:"onlineURL,omitempty"`
+	// Local directory with the layout of the uptime bucket
+	SubmissionsDir string `json:"submissionsDir,omitempty"`
+	// File with a JSON array of submissions
+	InventoryFile string `json:"inventoryFile,omitempty"`
+	// Lookback window of submissions in the bucket or directory, 15 minutes by default
+	LookbackMin      int      `json:"lookbackMin,omitempty"`
+	FundDaemonPorts  []string `json:",omitempty"`
+	MinaExec         string   `json:",omitempty"`
 	SlotDurationMs   int
 	GenesisTimestamp itn_json_types.Time
-	ControlExec      string   `json:",omitempty"`
-	UrlOverrides     []string `json:",omitempty"`
-	PrintRequests    bool     `json:"printRequests,omitempty"`
+	ControlExec      string             `json:",omitempty"`
+	UrlOverrides     []string           `json:",omitempty"`
+	PrintRequests    bool               `json:"printRequests,omitempty"`
+	Plugins          []PluginConfig     `json:"plugins,omitempty"`
+	Retry            RetryConfig        `json:"retry"`
+	Scheduling       SchedulingConfig   `json:"scheduling"`
+	NodeRegistry     NodeRegistryConfig `json:"nodeRegistry"`
+	// Address to serve Prometheus metrics on (CLI only, the service serves them on its own router)
+	MetricsAddress string `json:"metricsAddress,omitempty"`
+	// Directory to write internal logs of nodes to, "internal-logs" by default
+	InternalLogsDir string `json:"internalLogsDir,omitempty"`
+	// Period of background collection of internal logs from all known nodes, disabled when zero
+	InternalLogsIntervalSec int `json:"internalLogsIntervalSec,omitempty"`
+	// File every gating update is appended to, not written when empty
+	TopologyJournal string `json:"topologyJournal,omitempty"`
+	// Executables injecting faults of specific kinds, ControlExec is used for other kinds
+	Faults []FaultExecutorConfig `json:"faults,omitempty"`
+	// Executable reporting status of a node to wait-ready, wait-ready fails when not set
+	Readiness *ExecProbe `json:"readiness,omitempty"`
+	// Seed of random choices made by actions (node sampling, partitioning), a random seed is chosen when zero
+	Seed int64 `json:"seed,omitempty"`
 }
 
 func (config *AwsConfig) GetBucketName() string {
@@ -105,8 +149,14 @@ func LoadAppConfig(configFilename string) (res OrchestratorConfig) {
 		os.Exit(3)
 		return
 	}
-	if (res.Aws == nil) == (res.OnlineURL == "") {
-		os.Stderr.WriteString("Neither aws nor online url configured")
+	sources := 0
+	for _, configured := range []bool{res.Aws != nil, res.OnlineURL != "", res.SubmissionsDir != "", res.InventoryFile != ""} {
+		if configured {
+			sources++
+		}
+	}
+	if sources != 1 {
+		os.Stderr.WriteString("Exactly one of aws, online url, submissions dir and inventory file should be configured")
 		os.Exit(11)
 	}
 	return
@@ -138,9 +188,7 @@ func (e *OrchestratorError) Error() string {
 	return e.Message
 }
 
-type outCacheT = map[string]map[int]map[string]OutputCacheEntry
-
-func outputF(outCache outCacheT, log logging.StandardLogger, step int) func(string, any, bool, bool) error {
+func outputF(outCache outCacheT, log logging.StandardLogger, scope *Scope, step int) func(string, any, bool, bool) error {
 	return func(name string, value_ any, multiple bool, sensitive bool) error {
 		value, err := json.Marshal(value_)
 		if err != nil {
@@ -149,30 +197,21 @@ func outputF(outCache outCacheT, log logging.StandardLogger, step int) func(stri
 				Code:    7,
 			}
 		}
-		if _, has := outCache[""][step]; !has {
-			outCache[""][step] = map[string]OutputCacheEntry{}
+		output := Output{
+			Scope: scope.name(),
+			Name:  name,
+			Multi: multiple,
+			Value: value,
+			Step:  step,
+			Time:  time.Now().UTC(),
 		}
-		prev, has := outCache[""][step][name]
-		if has {
-			if multiple && prev.Multi {
-				outCache[""][step][name] = OutputCacheEntry{Multi: true, Values: append(prev.Values, value)}
-			} else {
-				return &OrchestratorError{
-					Message: fmt.Sprintf("Error outputting multiple values for %s on step %d", name, step),
-					Code:    8,
-				}
+		// Output is written to stdout under the cache lock, so that
+		// lines of concurrently performed steps do not interleave
+		err = outCache.add(output, func() error {
+			if sensitive {
+				return nil
 			}
-		} else {
-			outCache[""][step][name] = OutputCacheEntry{Multi: multiple, Values: []json.RawMessage{value}}
-		}
-		if !sensitive {
-			json, err := json.Marshal(Output{
-				Name:  name,
-				Multi: multiple,
-				Value: value,
-				Step:  step,
-				Time:  time.Now().UTC(),
-			})
+			json, err := json.Marshal(output)
 			if err != nil {
 				return &OrchestratorError{
 					Message: fmt.Sprintf("Error marshalling output %s for step %d: %v", name, step, err),
@@ -186,8 +225,15 @@ func outputF(outCache outCacheT, log logging.StandardLogger, step int) func(stri
 					Code:    8,
 				}
 			}
+			return nil
+		})
+		if _, isOrchestratorError := err.(*OrchestratorError); err != nil && !isOrchestratorError {
+			return &OrchestratorError{
+				Message: fmt.Sprintf("Error outputting %s on step %d: %v", name, step, err),
+				Code:    8,
+			}
 		}
-		return nil
+		return err
 	}
 }
 
@@ -205,30 +251,48 @@ func (awsConf *AwsConfig) load(ctx context.Context, log logging.StandardLogger)
 }
 
 func SetupConfig(ctx context.Context, orchestratorConfig OrchestratorConfig, log logging.StandardLogger) Config {
-	nodeData := make(map[NodeAddress]NodeEntry)
-	var awsctx *AwsContext
-	if orchestratorConfig.Aws != nil {
-		awsctx = orchestratorConfig.Aws.load(ctx, log)
+	var submissions SubmissionSource
+	switch {
+	case orchestratorConfig.Aws != nil:
+		submissions = *orchestratorConfig.Aws.load(ctx, log)
+	case orchestratorConfig.SubmissionsDir != "":
+		submissions = DirectorySource{Dir: orchestratorConfig.SubmissionsDir}
+	case orchestratorConfig.InventoryFile != "":
+		submissions = InventorySource{File: orchestratorConfig.InventoryFile}
+	default:
+		submissions = OnlineSource{URL: orchestratorConfig.OnlineURL}
 	}
 
-	if orchestratorConfig.Aws != nil {
-		awsctx = orchestratorConfig.Aws.load(ctx, log)
+	seed := orchestratorConfig.Seed
+	if seed == 0 {
+		seed = NewSeed()
 	}
+	log.Infof("Random seed: %d", seed)
 
 	config := Config{
-		Ctx:              ctx,
-		AwsContext:       awsctx,
-		Sk:               ed25519.PrivateKey(orchestratorConfig.Key),
-		Log:              log,
-		FundDaemonPorts:  orchestratorConfig.FundDaemonPorts,
-		MinaExec:         orchestratorConfig.MinaExec,
-		NodeData:         nodeData,
-		SlotDurationMs:   orchestratorConfig.SlotDurationMs,
-		GenesisTimestamp: time.Time(orchestratorConfig.GenesisTimestamp),
-		ControlExec:      orchestratorConfig.ControlExec,
-		OnlineURL:        orchestratorConfig.OnlineURL,
-		UrlOverrides:     orchestratorConfig.UrlOverrides,
-		PrintRequests:    orchestratorConfig.PrintRequests,
+		Ctx:                 ctx,
+		Submissions:         submissions,
+		SubmissionsLookback: time.Duration(orchestratorConfig.LookbackMin) * time.Minute,
+		Sk:                  ed25519.PrivateKey(orchestratorConfig.Key),
+		Log:                 log,
+		FundDaemonPorts:     orchestratorConfig.FundDaemonPorts,
+		MinaExec:            orchestratorConfig.MinaExec,
+		Nodes:               NewNodeRegistry(orchestratorConfig.NodeRegistry),
+		SlotDurationMs:      orchestratorConfig.SlotDurationMs,
+		GenesisTimestamp:    time.Time(orchestratorConfig.GenesisTimestamp),
+		ControlExec:         orchestratorConfig.ControlExec,
+		UrlOverrides:        orchestratorConfig.UrlOverrides,
+		PrintRequests:       orchestratorConfig.PrintRequests,
+		Receipts:            NewReceiptTracker(),
+		Gating:              NewGatingTracker(orchestratorConfig.TopologyJournal),
+		Faults:              NewFaultTracker(),
+		FaultExecutors:      faultExecutors(orchestratorConfig.Faults),
+		Readiness:           readinessProbe(orchestratorConfig.Readiness),
+		Rand:                NewRand(seed),
+		Retry:               orchestratorConfig.Retry,
+		Scheduling:          orchestratorConfig.Scheduling,
+		InternalLogs: NewInternalLogsCollector(orchestratorConfig.InternalLogsDir,
+			time.Duration(orchestratorConfig.InternalLogsIntervalSec)*time.Second),
 	}
 	if config.MinaExec == "" {
 		config.MinaExec = "mina"
@@ -239,14 +303,39 @@ func SetupConfig(ctx context.Context, orchestratorConfig OrchestratorConfig, log
 	return config
 }
 
-func EmptyOutputCache() outCacheT {
-	return map[string]map[int]map[string]OutputCacheEntry{
-		"": {},
+// ReadScriptSeed reads comments at the start of the script and returns the seed
+// recorded in its header (zero if there is none), along with the reader of the whole script
+func ReadScriptSeed(r io.Reader) (int64, io.Reader, error) {
+	br := bufio.NewReader(r)
+	var header bytes.Buffer
+	var seed int64
+	for {
+		line, err := br.ReadBytes('\n')
+		header.Write(line)
+		var comment string
+		if json.Unmarshal(line, &comment) != nil {
+			// Not a comment, or the end of the script
+			if err != nil && err != io.EOF {
+				return 0, nil, err
+			}
+			break
+		}
+		fmt.Sscanf(comment, "Seed: %d", &seed)
+		if err != nil {
+			if err != io.EOF {
+				return 0, nil, err
+			}
+			break
+		}
 	}
+	return seed, io.MultiReader(&header, br), nil
 }
 
+// RunActions reads commands from the decoder and performs them starting with the given step.
+// When checkpoint is not nil, it's called with the number of performed steps after each step.
 func RunActions(inDecoder *json.Decoder, config Config, outCache outCacheT, log logging.StandardLogger, step int,
-	handlePrevAction func() error, actionAccum *[]ActionIO, rconfig ResolutionConfig, prevAction *BatchAction) error {
+	handlePrevAction func() error, actionAccum *[]ActionIO, rconfig ResolutionConfig, prevAction *BatchAction,
+	checkpoint func(step int) error) error {
 	for {
 
 		select {
@@ -273,47 +362,191 @@ func RunActions(inDecoder *json.Decoder, config Config, outCache outCacheT, log
 		}
 		cmd := *commandOrComment.command
 		if *prevAction != nil && (*prevAction).Name() != cmd.Action {
-			handlePrevAction()
+			if err := handlePrevAction(); err != nil {
+				return err
+			}
+			if checkpoint != nil {
+				if err := checkpoint(step); err != nil {
+					return &OrchestratorError{
+						Message: fmt.Sprintf("Error writing checkpoint for step %d: %v", step, err),
+						Code:    12,
+					}
+				}
+			}
+		}
+		if err := performStep(config, outCache, log, rconfig, step, cmd, actionAccum, prevAction); err != nil {
+			return err
+		}
+		step++
+		if checkpoint != nil {
+			if err := checkpoint(step); err != nil {
+				return &OrchestratorError{
+					Message: fmt.Sprintf("Error writing checkpoint for step %d: %v", step, err),
+					Code:    12,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// RunBatch performs accumulated steps of a batch action
+func RunBatch(config Config, action BatchAction, actionIOs []ActionIO) error {
+	start := time.Now()
+	err := action.RunMany(config, actionIOs)
+	observeActionDuration(action.Name(), start)
+	return err
+}
+
+// performStep resolves params of the command and either performs it
+// or appends it to the accumulator of a batch action
+func performStep(config Config, outCache outCacheT, log logging.StandardLogger, rconfig ResolutionConfig, step int,
+	cmd Command, actionAccum *[]ActionIO, prevAction *BatchAction) error {
+	composite := compositeActions[cmd.Action]
+	var deferred []string
+	if composite != nil {
+		deferred = composite.DeferredParams()
+	}
+	params, err := ResolveParams(rconfig, step, cmd.Params, append(deferred, retryParam)...)
+	if err != nil {
+		return &OrchestratorError{
+			Message: fmt.Sprintf("Error resolving params for step %d: %v", step, err),
+			Code:    6,
 		}
-		params, err := ResolveParams(rconfig, step, cmd.Params)
+	}
+	config, err = withStepRetry(config, params)
+	if err != nil {
+		return &OrchestratorError{
+			Message: fmt.Sprintf("Error validating action '%s' for step %d: %v", cmd.Action, step, err),
+			Code:    1,
+		}
+	}
+	if composite != nil {
+		logStep(log, rconfig.Scope, cmd.Action, step)
+		observeStep(cmd.Action, rconfig.Scope, step)
+		runner := NestedRunner{Config: config, Log: log, outCache: outCache, scope: rconfig.Scope, step: step}
+		start := time.Now()
+		err := composite.RunNested(runner, params, outputF(outCache, log, rconfig.Scope, step))
+		observeActionDuration(cmd.Action, start)
 		if err != nil {
 			return &OrchestratorError{
-				Message: fmt.Sprintf("Error resolving params for step %d: %v", step, err),
-				Code:    6,
+				Message: fmt.Sprintf("Error running step %d: %v", step, err),
+				Code:    9,
 			}
 		}
-		action := actions[cmd.Action]
-		if action == nil {
+		return nil
+	}
+	action := actions[cmd.Action]
+	if action == nil {
+		return &OrchestratorError{
+			Message: fmt.Sprintf("Unknown action name: %s", cmd.Action),
+			Code:    10,
+		}
+	}
+	batchAction, isBatchAction := action.(BatchAction)
+
+	if isBatchAction {
+		if err := batchAction.Validate(params); err != nil {
 			return &OrchestratorError{
-				Message: fmt.Sprintf("Unknown action name: %s", cmd.Action),
-				Code:    10,
+				Message: fmt.Sprintf("Error validating action '%s' for step %d: %v", cmd.Action, step, err),
+				Code:    1,
 			}
 		}
-		batchAction, isBatchAction := action.(BatchAction)
+		observeStep(cmd.Action, rconfig.Scope, step)
+		*prevAction = batchAction
+		*actionAccum = append(*actionAccum, ActionIO{
+			Step:   step,
+			Params: params,
+			Output: outputF(outCache, log, rconfig.Scope, step),
+		})
+	} else {
+		logStep(log, rconfig.Scope, cmd.Action, step)
+		observeStep(cmd.Action, rconfig.Scope, step)
+		start := time.Now()
+		err = action.Run(config, params, outputF(outCache, log, rconfig.Scope, step))
+		observeActionDuration(cmd.Action, start)
+		if err != nil {
+			return &OrchestratorError{
+				Message: fmt.Sprintf("Error running step %d: %v", step, err),
+				Code:    9,
+			}
+		}
+	}
+	return nil
+}
 
-		if isBatchAction {
-			if err := batchAction.Validate(params); err != nil {
-				return &OrchestratorError{
-					Message: fmt.Sprintf("Error validating action '%s' for step %d: %v", cmd.Action, step, err),
-					Code:    1,
-				}
+func logStep(log logging.StandardLogger, scope *Scope, action string, step int) {
+	if scope == nil {
+		log.Infof("Performing step %s (%d)", action, step)
+	} else {
+		log.Infof("Performing step %s (%d) of scope %s", action, step, scope.Name)
+	}
+}
+
+// NestedRunner is provided to composite actions to perform nested commands
+type NestedRunner struct {
+	Config   Config
+	Log      logging.StandardLogger
+	outCache outCacheT
+	// Scope and step of the composite action
+	scope *Scope
+	step  int
+}
+
+// Run performs commands in a new scope, suffix should be unique among
+// all scopes opened by the composite action
+func (r NestedRunner) Run(suffix string, cmds []Command) error {
+	scope := r.nestedScope(suffix)
+	name := scope.Name
+	rconfig := ResolutionConfig{OutputCache: r.outCache, Scope: scope}
+	var prevAction BatchAction
+	var actionAccum []ActionIO
+	handlePrevAction := func() error {
+		r.Log.Infof("Performing steps %s (%d-%d) of scope %s", prevAction.Name(), actionAccum[0].Step, actionAccum[len(actionAccum)-1].Step, name)
+		if err := RunBatch(r.Config, prevAction, actionAccum); err != nil {
+			return &OrchestratorError{
+				Message: fmt.Sprintf("Error running steps %d-%d of scope %s: %v", actionAccum[0].Step, actionAccum[len(actionAccum)-1].Step, name, err),
+				Code:    9,
 			}
-			*prevAction = batchAction
-			*actionAccum = append(*actionAccum, ActionIO{
-				Params: params,
-				Output: outputF(outCache, log, step),
-			})
-		} else {
-			log.Infof("Performing step %s (%d)", cmd.Action, step)
-			err = action.Run(config, params, outputF(outCache, log, step))
-			if err != nil {
-				return &OrchestratorError{
-					Message: fmt.Sprintf("Error running step %d: %v", step, err),
-					Code:    9,
-				}
+		}
+		prevAction = nil
+		actionAccum = nil
+		return nil
+	}
+	for step, cmd := range cmds {
+		if err := r.Config.Ctx.Err(); err != nil {
+			return err
+		}
+		if prevAction != nil && prevAction.Name() != cmd.Action {
+			if err := handlePrevAction(); err != nil {
+				return err
 			}
 		}
-		step++
+		if err := performStep(r.Config, r.outCache, r.Log, rconfig, step, cmd, &actionAccum, &prevAction); err != nil {
+			return fmt.Errorf("scope %s: %w", name, err)
+		}
+	}
+	if prevAction != nil {
+		return handlePrevAction()
 	}
 	return nil
 }
+
+func (r NestedRunner) nestedScope(suffix string) *Scope {
+	name := fmt.Sprintf("%d.%s", r.step, suffix)
+	if r.scope != nil {
+		name = r.scope.Name + "/" + name
+	}
+	return &Scope{Name: name, Parent: r.scope, Step: r.step}
+}
+
+// Resolve resolves a deferred param value as a param of the composite step
+func (r NestedRunner) Resolve(raw json.RawMessage) (json.RawMessage, error) {
+	return ResolveParam(ResolutionConfig{OutputCache: r.outCache, Scope: r.scope}, r.step, raw)
+}
+
+// ResolveIn resolves a deferred param value as a param of the given
+// step of commands performed with the suffix
+func (r NestedRunner) ResolveIn(suffix string, step int, raw json.RawMessage) (json.RawMessage, error) {
+	return ResolveParam(ResolutionConfig{OutputCache: r.outCache, Scope: r.nestedScope(suffix)}, step, raw)
+}
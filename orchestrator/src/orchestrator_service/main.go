@@ -8,7 +8,6 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -38,8 +37,10 @@ func (a *App) initializeRoutes() {
 
 	a.Router.HandleFunc("/api/v0/experiment/run", a.createExperimentHandler()).Methods(http.MethodPost)
 	a.Router.HandleFunc("/api/v0/experiment/test", a.infoExperimentHandler).Methods(http.MethodPost)
+	a.Router.HandleFunc("/api/v0/experiment/estimate", a.estimateExperimentHandler).Methods(http.MethodPost)
 	a.Router.HandleFunc("/api/v0/experiment/status", a.statusHandler).Methods(http.MethodGet)
 	a.Router.HandleFunc("/api/v0/experiment/cancel", a.cancelHandler()).Methods(http.MethodPost)
+	a.Router.Handle("/metrics", lib.MetricsHandler()).Methods(http.MethodGet)
 
 }
 
@@ -76,16 +77,13 @@ func (a *App) Run(address string) {
 func (a *App) infoExperimentHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	experimentSetup, err := a.GetExperimentSetup(*r)
+	p, err := a.GetExperimentSetup(*r)
 
 	if err != nil {
 		Error([]string{err.Error()}, w)
 		return
 	}
 
-	var p lib.GenParams
-	experimentSetup.ApplyWithDefaults(&p)
-
 	validationErrors := lib.ValidateAndCollectErrors(&p)
 
 	if len(validationErrors) > 0 {
@@ -93,104 +91,84 @@ func (a *App) infoExperimentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var errors []string
-
 	type Round struct {
 		No           int
 		PaymentsRate float64
 		ZkappRate    float64
 	}
 
-	var rounds []Round
-	var result strings.Builder
-
-	encoder := json.NewEncoder(&result)
-	writeComment := func(comment string) {
-		if err := encoder.Encode(comment); err != nil {
-			errors = append(errors, fmt.Sprintf("Error writing comment: %v", err))
-		}
-	}
-	writeCommand := func(cmd lib.GeneratedCommand) {
-		comment := cmd.Comment()
-		if comment != "" {
-			writeComment(comment)
-		}
-		if err := encoder.Encode(cmd); err != nil {
-			errors = append(errors, fmt.Sprintf("Error writing command: %v", err))
+	plan := p.Plan()
+	estimate := plan.Estimate(0)
+	rounds := make([]Round, len(estimate.Rounds))
+	for i, round := range estimate.Rounds {
+		rounds[i] = Round{
+			No:           round.Round,
+			PaymentsRate: round.PaymentsRate,
+			ZkappRate:    round.ZkappRate,
 		}
 	}
 
-	if len(errors) > 0 {
-		Error(errors, w)
-		return
-	}
-
-	lib.Encode(&p, writeCommand, writeComment)
-
 	setup_json, err := p.ToJSON()
 	if err != nil {
 		Error([]string{fmt.Sprintf("Error converting to JSON: %v", err)}, w)
 		return
 	}
 
-	for _, line := range strings.Split(result.String(), "\n") {
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(
+		map[string]interface{}{
+			"setup":    setup_json,
+			"rounds":   rounds,
+			"timeline": plan,
+		},
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
 
-		re := regexp.MustCompile(`Starting round (\d), .*`)
+// estimateExperimentHandler responds with estimated cost and schedule of the experiment,
+// optional nodes query parameter is the number of nodes to estimate affected nodes of stops for
+func (a *App) estimateExperimentHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-		if re.MatchString(line) {
-			m := re.FindStringSubmatch(line)
-			if len(m) == 2 {
-				rounds = append(rounds, Round{
-					No: func() int {
-						no, err := strconv.Atoi(m[1])
-						if err != nil {
-							errors = append(errors, fmt.Sprintf("Error parsing round number: %v", err))
-							return 0
-						}
-						return no
-					}(),
-				})
-			}
+	nodes := 0
+	if s := r.URL.Query().Get("nodes"); s != "" {
+		var err error
+		if nodes, err = strconv.Atoi(s); err != nil || nodes < 0 {
+			Error([]string{fmt.Sprintf("Invalid number of nodes: %s", s)}, w)
+			return
 		}
+	}
 
-		re = regexp.MustCompile(`\b\d+\s+(zkapp|payments)\b.*?\(([\d.]+)\s*txs\/min\)`)
-
-		if re.MatchString(line) {
-			m := re.FindStringSubmatch(line)
-			if len(m) == 3 {
-				round := &rounds[len(rounds)-1]
-				rate, err := strconv.ParseFloat(m[2], 64)
-				if err != nil {
-					errors = append(errors, fmt.Sprintf("Error parsing rate: %v", err))
-					return
-				}
-				switch m[1] {
-				case "zkapp":
-					round.ZkappRate = rate
-				case "payments":
-					round.PaymentsRate = rate
-				}
-			}
-		}
+	p, err := a.GetExperimentSetup(*r)
+
+	if err != nil {
+		Error([]string{err.Error()}, w)
+		return
 	}
 
-	if len(errors) > 0 {
-		Error(errors, w)
+	validationErrors := lib.ValidateAndCollectErrors(&p)
+
+	if len(validationErrors) > 0 {
+		ValidationError(validationErrors, w)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(
-		map[string]interface{}{
-			"setup":  setup_json,
-			"rounds": rounds,
-		},
-	); err != nil {
+	if err := json.NewEncoder(w).Encode(lib.Estimate(&p, nodes)); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
 func (a *App) loadRun(inDecoder *json.Decoder, config lib.Config, log logging.StandardLogger) {
+	stopInternalLogs := config.InternalLogs.Start(config)
+	defer stopInternalLogs()
+	// Injected faults shouldn't outlive the experiment
+	defer func() {
+		if err := lib.RevertOutstandingFaults(config); err != nil {
+			log.Warnf("Failed to revert some of injected faults: %v", err)
+		}
+	}()
 
 	outCache := lib.EmptyOutputCache()
 	rconfig := lib.ResolutionConfig{
@@ -213,7 +191,7 @@ func (a *App) loadRun(inDecoder *json.Decoder, config lib.Config, log logging.St
 			end = 0
 		}
 		log.Infof("Performing steps %s (%d-%d)", prevAction.Name(), start, end)
-		err := prevAction.RunMany(config, actionAccum)
+		err := lib.RunBatch(config, prevAction, actionAccum)
 		if err != nil {
 			return &lib.OrchestratorError{
 				Message: fmt.Sprintf("Error running steps %d-%d: %v", start, end, err),
@@ -224,15 +202,31 @@ func (a *App) loadRun(inDecoder *json.Decoder, config lib.Config, log logging.St
 		actionAccum = nil
 		return nil
 	}
+	// Scheduled transactions shouldn't outlive a canceled or failed experiment
+	stopOutstanding := func() {
+		if err := lib.StopOutstandingTransactions(config); err != nil {
+			log.Warnf("Failed to stop some of scheduled transactions: %v", err)
+		}
+	}
 	err := lib.RunActions(inDecoder, config, outCache, log, step,
-		handlePrevAction, &actionAccum, rconfig, &prevAction)
+		handlePrevAction, &actionAccum, rconfig, &prevAction, nil)
 	if err != nil {
 		if err, ok := err.(*lib.OrchestratorError); ok {
+			stopOutstanding()
+			// If context is canceled, we don't want to finish with error
+			// because it means the user canceled the experiment
+			if config.Ctx.Err() != nil {
+				log.Infof("Experiment canceled: %v", err)
+				return
+			}
 			log.Errorf("Experiment finished with error: %v", err)
 			a.Store.FinishWithError(err)
 			return
 		}
 	}
+	if config.Ctx.Err() != nil {
+		stopOutstanding()
+	}
 
 	if prevAction != nil {
 		if err := handlePrevAction(); err != nil {
@@ -240,6 +234,7 @@ func (a *App) loadRun(inDecoder *json.Decoder, config lib.Config, log logging.St
 			// If context is canceled, we don't want to finish with error
 			// because it means the user canceled the experiment
 			if config.Ctx.Err() == nil {
+				stopOutstanding()
 				a.Store.FinishWithError(&lib.OrchestratorError{
 					Message: fmt.Sprintf("Error running previous action: %v", err),
 					Code:    9,
@@ -321,23 +316,22 @@ func Success(w http.ResponseWriter) {
 	}
 }
 
-func (a *App) GetExperimentSetup(r http.Request) (*service_inputs.GeneratorInputData, error) {
+func (a *App) GetExperimentSetup(r http.Request) (lib.GenParams, error) {
 
 	var input service_inputs.Input
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		return nil, fmt.Errorf("Failed to decode request body: %v", err)
+		return lib.GenParams{}, fmt.Errorf("Failed to decode request body: %v", err)
 	}
 
-	experimentSetup := input.ExperimentSetup
-
-	if input.ExperimentSetup == nil {
-		return nil, fmt.Errorf("Experiment setup is required")
+	p, err := input.GenParams()
+	if err != nil {
+		return lib.GenParams{}, err
 	}
 
-	if !a.Store.CheckExperimentIsUnique(*experimentSetup.ExperimentName) {
-		return nil, fmt.Errorf("Experiment with the same name already exists")
+	if !a.Store.CheckExperimentIsUnique(p.ExperimentName) {
+		return lib.GenParams{}, fmt.Errorf("Experiment with the same name already exists")
 	}
-	return experimentSetup, nil
+	return p, nil
 }
 
 func (a *App) createExperimentHandler() func(w http.ResponseWriter, r *http.Request) {
@@ -347,16 +341,13 @@ func (a *App) createExperimentHandler() func(w http.ResponseWriter, r *http.Requ
 
 		var input service_inputs.Input
 
-		experimentSetup, err := a.GetExperimentSetup(*r)
+		p, err := a.GetExperimentSetup(*r)
 
 		if err != nil {
 			Error([]string{err.Error()}, w)
 			return
 		}
 
-		var p lib.GenParams
-		experimentSetup.ApplyWithDefaults(&p)
-
 		validationErrors := lib.ValidateAndCollectErrors(&p)
 
 		if len(validationErrors) > 0 {
@@ -396,13 +387,23 @@ func (a *App) createExperimentHandler() func(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
-		job := &service.ExperimentState{Name: *experimentSetup.ExperimentName, Status: "running", CreatedAt: time.Now(),
+		job := &service.ExperimentState{Name: p.ExperimentName, Status: "running", CreatedAt: time.Now(),
 			SetupJSON: setup_json,
 		}
 
-		ctx, cancel := context.WithCancel(context.Background())
-
 		orchestratorConfig := input.GetOrchestratorConfig(a.Config)
+		if orchestratorConfig.Seed == 0 {
+			// Seed of the experiment setup is used, so that the run can be reproduced from the setup
+			orchestratorConfig.Seed = p.Seed
+		}
+		// Internal logs of different experiments are kept apart
+		orchestratorConfig.InternalLogsDir, err = lib.ExperimentInternalLogsDir(orchestratorConfig.InternalLogsDir, p.ExperimentName)
+		if err != nil {
+			Error([]string{err.Error()}, w)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
 
 		log := service.StoreLogging{Store: a.Store, Log: logging.Logger("orchestrator")}
 		config := lib.SetupConfig(ctx, orchestratorConfig, log)
@@ -442,6 +443,10 @@ func main() {
 	}
 
 	config := lib.LoadAppConfig(*configFilename)
+	if err := lib.RegisterPlugins(config.Plugins); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to register plugins: %v\n", err)
+		os.Exit(11)
+	}
 
 	logging.SetupLogging(logging.Config{
 		Format: logging.ColorizedOutput,
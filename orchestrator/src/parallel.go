@@ -0,0 +1,62 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+type ParallelParams struct {
+	Branches [][]Command `json:"branches"`
+}
+
+// ParallelAction performs each branch in its own goroutine. Steps of a branch
+// are numbered from zero, negative references reaching before the start of the
+// branch refer to the steps preceding the parallel step. When one of branches
+// fails, the remaining branches are canceled.
+type ParallelAction struct{}
+
+func (ParallelAction) Name() string { return "parallel" }
+
+func (ParallelAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: ParallelParams{}}
+}
+
+func (ParallelAction) Nested(params RawParams) ([][]Command, error) {
+	var branches [][]Command
+	if err := json.Unmarshal(params["branches"], &branches); err != nil {
+		return nil, fmt.Errorf("failed to decode branches: %v", err)
+	}
+	return branches, nil
+}
+
+func (ParallelAction) DeferredParams() []string { return nil }
+
+func (ParallelAction) RunNested(runner NestedRunner, rawParams json.RawMessage, output OutputF) error {
+	var params ParallelParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	ctx, cancelF := context.WithCancel(runner.Config.Ctx)
+	defer cancelF()
+	runner.Config.Ctx = ctx
+	errs := make([]error, len(params.Branches))
+	var wg sync.WaitGroup
+	for i, branch := range params.Branches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := runner.Run(strconv.Itoa(i), branch); err != nil {
+				errs[i] = fmt.Errorf("branch %d: %w", i, err)
+				cancelF()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+var _ CompositeAction = ParallelAction{}
@@ -0,0 +1,34 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveScopedParam(t *testing.T) {
+	log := logging.Logger("test")
+	outCache := EmptyOutputCache()
+	outer := &Scope{Name: "3.0", Step: 3}
+	inner := &Scope{Name: "3.0/1.1", Parent: outer, Step: 1}
+	require.NoError(t, outputF(outCache, log, nil, 1)("x", "top", false, false))
+	require.NoError(t, outputF(outCache, log, outer, 0)("x", "outer", false, false))
+	require.NoError(t, outputF(outCache, log, inner, 0)("x", "inner", false, false))
+
+	resolve := func(scope *Scope, step int, ref string) string {
+		res, err := ResolveParam(ResolutionConfig{OutputCache: outCache, Scope: scope}, step, json.RawMessage(ref))
+		require.NoError(t, err)
+		return string(res)
+	}
+	require.Equal(t, `"inner"`, resolve(inner, 1, `{"type":"output","step":-1,"name":"x"}`))
+	require.Equal(t, `"outer"`, resolve(inner, 1, `{"type":"output","step":-2,"name":"x"}`))
+	require.Equal(t, `"top"`, resolve(inner, 1, `{"type":"output","step":-4,"name":"x"}`))
+	require.Equal(t, `"top"`, resolve(inner, 1, `{"type":"output","step":1,"name":"x"}`))
+
+	_, err := ResolveParam(ResolutionConfig{OutputCache: outCache}, 4, json.RawMessage(`{"type":"output","step":-4,"name":"x"}`))
+	require.Error(t, err)
+	require.Len(t, outCache.outputs(""), 1)
+	require.Len(t, outCache.outputs("3.0/1.1"), 1)
+}
@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"sort"
+	"sync"
 	"time"
 )
 
 type Output struct {
 	Time  time.Time       `json:"time,omitempty"`
+	Scope string          `json:"scope,omitempty"`
 	Step  int             `json:"step"`
 	Name  string          `json:"name"`
 	Multi bool            `json:"multi,omitempty"`
@@ -22,8 +26,108 @@ type OutputCacheEntry struct {
 	Values []json.RawMessage
 }
 
+// Scope of step numbering: either the top-level script (represented by nil)
+// or a list of commands nested into a step of the parent scope
+type Scope struct {
+	// Unique name of the scope within the run
+	Name   string
+	Parent *Scope
+	// Step of the parent scope that runs the nested commands
+	Step int
+}
+
+func (scope *Scope) name() string {
+	if scope == nil {
+		return ""
+	}
+	return scope.Name
+}
+
+// OutputCache keeps outputs of performed steps (per scope) and outputs
+// loaded from files. It's safe for concurrent use.
+type OutputCache struct {
+	mu     sync.Mutex
+	scopes map[string]map[int]map[string]OutputCacheEntry
+	files  map[string]map[int]map[string]OutputCacheEntry
+}
+
+type outCacheT = *OutputCache
+
+func EmptyOutputCache() outCacheT {
+	return &OutputCache{
+		scopes: map[string]map[int]map[string]OutputCacheEntry{"": {}},
+		files:  map[string]map[int]map[string]OutputCacheEntry{},
+	}
+}
+
+// add puts an output into the cache and calls onAdded while still holding the lock
+func (c *OutputCache) add(output Output, onAdded func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, has := c.scopes[output.Scope]; !has {
+		c.scopes[output.Scope] = map[int]map[string]OutputCacheEntry{}
+	}
+	if err := addOutput(c.scopes[output.Scope], output); err != nil {
+		return err
+	}
+	if onAdded != nil {
+		return onAdded()
+	}
+	return nil
+}
+
+func (c *OutputCache) lookup(scope string, step int, name string) (OutputCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, has := c.scopes[scope][step][name]
+	return entry, has
+}
+
+func (c *OutputCache) lookupFile(file string, step int, name string) (OutputCacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, has := c.files[file]; !has {
+		fileEntry, err := loadOutputFile(file)
+		if err != nil {
+			return OutputCacheEntry{}, false, err
+		}
+		c.files[file] = fileEntry
+	}
+	entry, has := c.files[file][step][name]
+	return entry, has, nil
+}
+
+// outputs lists all outputs of the scope ordered by step and name
+func (c *OutputCache) outputs(scope string) []Output {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	local := c.scopes[scope]
+	steps := make([]int, 0, len(local))
+	for s := range local {
+		steps = append(steps, s)
+	}
+	sort.Ints(steps)
+	outputs := []Output{}
+	for _, s := range steps {
+		names := make([]string, 0, len(local[s]))
+		for name := range local[s] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			entry := local[s][name]
+			for _, value := range entry.Values {
+				outputs = append(outputs, Output{Scope: scope, Step: s, Name: name, Multi: entry.Multi, Value: value})
+			}
+		}
+	}
+	return outputs
+}
+
 type ResolutionConfig struct {
-	OutputCache map[string]map[int]map[string]OutputCacheEntry
+	OutputCache outCacheT
+	// Scope of the steps being resolved, nil for the top-level script
+	Scope *Scope
 }
 
 func loadOutputFile(filename string) (map[int]map[string]OutputCacheEntry, error) {
@@ -42,23 +146,35 @@ func loadOutputFile(filename string) (map[int]map[string]OutputCacheEntry, error
 			}
 			break
 		}
-		if _, has := res[output.Step]; !has {
-			res[output.Step] = map[string]OutputCacheEntry{}
+		if output.Scope != "" {
+			// Outputs of nested commands can't be referenced from other runs
+			continue
 		}
-		prev, has := res[output.Step][output.Name]
-		if has {
-			if output.Multi && prev.Multi {
-				res[output.Step][output.Name] = OutputCacheEntry{Multi: true, Values: append(prev.Values, output.Value)}
-			} else {
-				return nil, fmt.Errorf("wrong output file %s: outputing multiple values for %s on step %d", filename, output.Name, output.Step)
-			}
-		} else {
-			res[output.Step][output.Name] = OutputCacheEntry{Multi: output.Multi, Values: []json.RawMessage{output.Value}}
+		if err := addOutput(res, output); err != nil {
+			return nil, fmt.Errorf("wrong output file %s: %v", filename, err)
 		}
 	}
 	return res, nil
 }
 
+// addOutput replays a single output entry into the per-step cache
+func addOutput(cache map[int]map[string]OutputCacheEntry, output Output) error {
+	if _, has := cache[output.Step]; !has {
+		cache[output.Step] = map[string]OutputCacheEntry{}
+	}
+	prev, has := cache[output.Step][output.Name]
+	if has {
+		if output.Multi && prev.Multi {
+			cache[output.Step][output.Name] = OutputCacheEntry{Multi: true, Values: append(prev.Values, output.Value)}
+		} else {
+			return fmt.Errorf("outputing multiple values for %s on step %d", output.Name, output.Step)
+		}
+	} else {
+		cache[output.Step][output.Name] = OutputCacheEntry{Multi: output.Multi, Values: []json.RawMessage{output.Value}}
+	}
+	return nil
+}
+
 type ComplexValue struct {
 	Type    string          `json:"type"`
 	File    string          `json:"file,omitempty"`
@@ -86,20 +202,33 @@ func ResolveParam(config ResolutionConfig, step int, raw json.RawMessage) (json.
 	if val.Step < 0 && val.File != "" {
 		return nil, fmt.Errorf("use of negative step with file is prohibited, needed for step %d", step)
 	}
-	if _, has := config.OutputCache[val.File]; !has {
-		fileEntry, err := loadOutputFile(val.File)
+	var entry OutputCacheEntry
+	var has bool
+	scope := config.Scope
+	if val.File != "" {
+		var err error
+		entry, has, err = config.OutputCache.lookupFile(val.File, val.Step, val.Name)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read output file %s needed for step %d: %v", val.File, step, err)
 		}
-		config.OutputCache[val.File] = fileEntry
-	}
-	if val.Step < 0 {
-		val.Step = val.Step + step
+	} else {
+		if val.Step < 0 {
+			val.Step = val.Step + step
+			// Negative steps reaching before the first step of a nested
+			// scope refer to steps of the enclosing scopes
+			for val.Step < 0 && scope != nil {
+				val.Step += scope.Step
+				scope = scope.Parent
+			}
+		} else {
+			// Non-negative steps always refer to the top-level script
+			scope = nil
+		}
+		entry, has = config.OutputCache.lookup(scope.name(), val.Step, val.Name)
 	}
-	entry, has := config.OutputCache[val.File][val.Step][val.Name]
 	if !has {
 		if val.OnEmpty == nil {
-			return nil, fmt.Errorf("couldn't find output %s (step %d, file \"%s\") needed for step %d", val.Name, val.Step, val.File, step)
+			return nil, fmt.Errorf("couldn't find output %s (step %d, file \"%s\", scope \"%s\") needed for step %d", val.Name, val.Step, val.File, scope.name(), step)
 		}
 		return val.OnEmpty, nil
 	}
@@ -116,16 +245,20 @@ func ResolveParam(config ResolutionConfig, step int, raw json.RawMessage) (json.
 
 var nullJson = json.RawMessage([]byte("null"))
 
-func ResolveParams(config ResolutionConfig, step int, raw RawParams) (json.RawMessage, error) {
+// ResolveParams resolves references in all params except the skipped ones
+func ResolveParams(config ResolutionConfig, step int, raw RawParams, skip ...string) (json.RawMessage, error) {
+	// Resolved values are put into a copy so that nested commands can be performed multiple times
+	resolved := make(RawParams, len(raw))
 	for k, v := range raw {
-		if bytes.Equal(v, nullJson) {
+		if bytes.Equal(v, nullJson) || slices.Contains(skip, k) {
+			resolved[k] = v
 			continue
 		}
 		v_, err := ResolveParam(config, step, v)
 		if err != nil {
 			return nil, err
 		}
-		raw[k] = v_
+		resolved[k] = v_
 	}
-	return json.Marshal(raw)
+	return json.Marshal(resolved)
 }
@@ -0,0 +1,257 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+type partitionedNode struct {
+	// Gating configuration before the node was partitioned
+	prior GatingUpdate
+	// Peers banned by partitioning
+	banned []NetworkPeer
+}
+
+// markPartitioned records peers banned by partitioning, configuration prior to
+// partitioning is kept from the first of consecutive partitionings
+func (t *GatingTracker) markPartitioned(addr NodeAddress, prior GatingUpdate, banned []NetworkPeer) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, has := t.partitioned[addr]; has {
+		p.banned = append(p.banned, banned...)
+		t.partitioned[addr] = p
+		return
+	}
+	t.partitioned[addr] = partitionedNode{prior: prior, banned: banned}
+}
+
+func (t *GatingTracker) takePartitioned(addr NodeAddress) (partitionedNode, bool) {
+	if t == nil {
+		return partitionedNode{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, has := t.partitioned[addr]
+	delete(t.partitioned, addr)
+	return p, has
+}
+
+// PartitionLink lets nodes of one partition connect to nodes of another
+type PartitionLink struct {
+	// Partitions are numbered from 1
+	From int `json:"from"`
+	To   int `json:"to"`
+	// Ratio of nodes of partition `to` linked to partition `from`, 1 by default
+	Ratio float64 `json:"ratio,omitempty"`
+	// Only nodes of partition `from` accept the linked nodes of partition `to`, not vice versa
+	OneWay bool `json:"oneWay,omitempty"`
+}
+
+type PartitionParams struct {
+	Nodes []NodeAddress `json:"nodes"`
+	// Relative sizes of partitions
+	Ratios []float64       `json:"ratios"`
+	Links  []PartitionLink `json:"links,omitempty"`
+}
+
+func splitPartitions(rng *rand.Rand, nodes []NodeAddress, ratios []float64) [][]NodeAddress {
+	total := 0.0
+	for _, r := range ratios {
+		total += r
+	}
+	nodes = append([]NodeAddress{}, nodes...)
+	rng.Shuffle(len(nodes), func(i, j int) {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	})
+	res := make([][]NodeAddress, len(ratios))
+	n := float64(len(nodes))
+	for i, r := range ratios[:len(ratios)-1] {
+		take := min(int(math.Round(r/total*n)), len(nodes))
+		res[i] = nodes[:take]
+		nodes = nodes[take:]
+	}
+	res[len(ratios)-1] = nodes
+	return res
+}
+
+func validatePartitionParams(params PartitionParams) error {
+	if len(params.Ratios) < 2 {
+		return errors.New("at least two partitions expected")
+	}
+	for _, r := range params.Ratios {
+		if r <= 0 {
+			return errors.New("invalid ratios entry")
+		}
+	}
+	for _, l := range params.Links {
+		if l.From < 1 || l.From > len(params.Ratios) || l.To < 1 || l.To > len(params.Ratios) || l.From == l.To {
+			return fmt.Errorf("invalid link %d -> %d", l.From, l.To)
+		}
+		if l.Ratio < 0 || l.Ratio > 1 {
+			return fmt.Errorf("invalid ratio of link %d -> %d", l.From, l.To)
+		}
+	}
+	return nil
+}
+
+// Partition splits nodes into partitions of the given relative sizes and bans
+// peers of other partitions on every node, except for peers allowed by links
+func Partition(config Config, params PartitionParams, output func(ix int, nodes []NodeAddress)) error {
+	if err := validatePartitionParams(params); err != nil {
+		return err
+	}
+	peers := make(map[NodeAddress]NetworkPeer, len(params.Nodes))
+	for _, addr := range params.Nodes {
+		peer, err := networkPeer(config, addr)
+		if err != nil {
+			return err
+		}
+		peers[addr] = peer
+	}
+	partitions := splitPartitions(config.rand(), params.Nodes, params.Ratios)
+	allowed := make(map[NodeAddress]map[NodeAddress]struct{}, len(params.Nodes))
+	allow := func(from []NodeAddress, to []NodeAddress) {
+		for _, f := range from {
+			if allowed[f] == nil {
+				allowed[f] = map[NodeAddress]struct{}{}
+			}
+			for _, t := range to {
+				allowed[f][t] = struct{}{}
+			}
+		}
+	}
+	for _, l := range params.Links {
+		from, to := partitions[l.From-1], partitions[l.To-1]
+		if l.Ratio > 0 {
+			to = to[:int(math.Ceil(l.Ratio*float64(len(to))))]
+		}
+		allow(from, to)
+		if !l.OneWay {
+			allow(to, from)
+		}
+	}
+	for i, partition := range partitions {
+		for _, addr := range partition {
+			banned := []NetworkPeer{}
+			bannedSet := map[NetworkPeer]struct{}{}
+			for j, other := range partitions {
+				if i == j {
+					continue
+				}
+				for _, o := range other {
+					if _, has := allowed[addr][o]; !has {
+						banned = append(banned, peers[o])
+						bannedSet[peers[o]] = struct{}{}
+					}
+				}
+			}
+			prior := config.Gating.Last(addr)
+			trusted := make([]NetworkPeer, 0, len(prior.TrustedPeers))
+			for _, p := range prior.TrustedPeers {
+				if _, has := bannedSet[p]; !has {
+					trusted = append(trusted, p)
+				}
+			}
+			err := UpdateGatingGql(config, addr, GatingUpdate{
+				AddedPeers:      make([]NetworkPeer, 0),
+				Isolate:         prior.Isolate,
+				CleanAddedPeers: false,
+				BannedPeers:     append(append([]NetworkPeer{}, prior.BannedPeers...), banned...),
+				TrustedPeers:    trusted,
+			})
+			if err != nil {
+				return err
+			}
+			config.Gating.markPartitioned(addr, prior, banned)
+		}
+		config.Log.Infof("partition %d: %d nodes", i+1, len(partition))
+	}
+	for i, partition := range partitions {
+		output(i+1, partition)
+	}
+	return nil
+}
+
+type PartitionAction struct{}
+
+func (PartitionAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	var params PartitionParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	return Partition(config, params, func(ix int, nodes []NodeAddress) {
+		output(fmt.Sprintf("partition%d", ix), nodes, false, false)
+	})
+}
+
+func (PartitionAction) Name() string { return "partition" }
+
+func (PartitionAction) Schema(params RawParams) ActionSchema {
+	outputs := []OutputSpec{}
+	var ratios []float64
+	if err := json.Unmarshal(params["ratios"], &ratios); err == nil {
+		for i := range ratios {
+			outputs = append(outputs, OutputSpec{Name: fmt.Sprintf("partition%d", i+1), Kind: ArrayKind})
+		}
+	} else {
+		outputs = append(outputs, OutputSpec{Name: "partition", Kind: ArrayKind, Indexed: true})
+	}
+	return ActionSchema{Params: PartitionParams{}, Outputs: outputs}
+}
+
+var _ DeclaredAction = PartitionAction{}
+
+type HealParams struct {
+	Nodes []NodeAddress `json:"nodes"`
+}
+
+// Heal restores gating configuration nodes had before they were partitioned,
+// lifting the bans of partitioning. Unless the prior configuration isolates
+// the node, it's also reconnected to the peers banned by partitioning.
+// Nodes not partitioned by this orchestrator (e.g. before the experiment
+// was resumed) get an unrestricted configuration.
+func Heal(config Config, params HealParams) error {
+	for _, addr := range params.Nodes {
+		p, has := config.Gating.takePartitioned(addr)
+		if !has {
+			p.prior = openGating()
+		}
+		update := p.prior
+		update.AddedPeers = append(make([]NetworkPeer, 0, len(p.prior.AddedPeers)+len(p.banned)), p.prior.AddedPeers...)
+		if !update.Isolate {
+			update.AddedPeers = append(update.AddedPeers, p.banned...)
+		}
+		update.CleanAddedPeers = false
+		if err := UpdateGatingGql(config, addr, update); err != nil {
+			if has {
+				config.Gating.markPartitioned(addr, p.prior, p.banned)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+type HealAction struct{}
+
+func (HealAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	var params HealParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	return Heal(config, params)
+}
+
+func (HealAction) Name() string { return "heal" }
+
+func (HealAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: HealParams{}, Outputs: nil}
+}
+
+var _ DeclaredAction = HealAction{}
@@ -0,0 +1,132 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"itn_orchestrator/mocknode"
+
+	"github.com/stretchr/testify/require"
+)
+
+func lastGatingUpdate(t *testing.T, node *mocknode.Node) GatingUpdate {
+	var update GatingUpdate
+	mutations := node.Mutations()
+	for i := len(mutations) - 1; i >= 0; i-- {
+		if mutations[i].Operation == "updateGating" {
+			require.NoError(t, json.Unmarshal(mutations[i].Variables, &struct {
+				Input *GatingUpdate `json:"input"`
+			}{&update}))
+			return update
+		}
+	}
+	require.Fail(t, "no gating updates")
+	return update
+}
+
+func startPartitionNodes(t *testing.T, n int) (Config, map[NodeAddress]*mocknode.Node, map[NodeAddress]NetworkPeer) {
+	opts := make([]mocknode.Options, n)
+	for i := range opts {
+		opts[i] = mocknode.Options{Libp2pPort: uint16(10501 + i), PeerId: fmt.Sprintf("peer%d", i)}
+	}
+	config, nodes := startMockNodes(t, opts...)
+	config.Gating = NewGatingTracker("")
+	byAddr := map[NodeAddress]*mocknode.Node{}
+	peers := map[NodeAddress]NetworkPeer{}
+	for i, node := range nodes {
+		addr := NodeAddress(node.Address())
+		byAddr[addr] = node
+		peers[addr] = NetworkPeer{Host: node.Host(), Libp2pPort: 10501 + i, PeerId: fmt.Sprintf("peer%d", i)}
+	}
+	return config, byAddr, peers
+}
+
+func peersOf(peers map[NodeAddress]NetworkPeer, addrs ...NodeAddress) []NetworkPeer {
+	res := []NetworkPeer{}
+	for _, addr := range addrs {
+		res = append(res, peers[addr])
+	}
+	return res
+}
+
+func TestPartitionAndHeal(t *testing.T) {
+	config, nodes, peers := startPartitionNodes(t, 4)
+	addrs := make([]NodeAddress, 0, len(nodes))
+	for addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	// Prior configuration to be restored by heal
+	trusted := peersOf(peers, addrs[1])
+	require.NoError(t, UpdateGatingGql(config, addrs[0], GatingUpdate{
+		AddedPeers: []NetworkPeer{}, BannedPeers: []NetworkPeer{}, TrustedPeers: trusted,
+	}))
+	var partitions [][]NodeAddress
+	require.NoError(t, Partition(config, PartitionParams{Nodes: addrs, Ratios: []float64{1, 1}}, func(ix int, p []NodeAddress) {
+		require.Equal(t, len(partitions)+1, ix)
+		partitions = append(partitions, p)
+	}))
+	require.Len(t, partitions, 2)
+	require.Len(t, partitions[0], 2)
+	require.ElementsMatch(t, addrs, append(append([]NodeAddress{}, partitions[0]...), partitions[1]...))
+	for i, p := range partitions {
+		other := partitions[1-i]
+		for _, addr := range p {
+			update := lastGatingUpdate(t, nodes[addr])
+			require.ElementsMatch(t, peersOf(peers, other...), update.BannedPeers)
+			require.False(t, update.Isolate)
+		}
+	}
+
+	require.NoError(t, Heal(config, HealParams{Nodes: addrs}))
+	for i, p := range partitions {
+		for _, addr := range p {
+			update := lastGatingUpdate(t, nodes[addr])
+			require.Empty(t, update.BannedPeers)
+			require.ElementsMatch(t, peersOf(peers, partitions[1-i]...), update.AddedPeers)
+			if addr == addrs[0] {
+				require.Equal(t, trusted, update.TrustedPeers)
+			} else {
+				require.Empty(t, update.TrustedPeers)
+			}
+		}
+	}
+
+	// Isolated node stays isolated, banned peers aren't added back
+	require.NoError(t, UpdateGatingGql(config, addrs[0], GatingUpdate{
+		AddedPeers: []NetworkPeer{}, BannedPeers: []NetworkPeer{}, TrustedPeers: trusted, Isolate: true, CleanAddedPeers: true,
+	}))
+	require.NoError(t, Partition(config, PartitionParams{Nodes: addrs, Ratios: []float64{1, 1}}, func(int, []NodeAddress) {}))
+	require.NoError(t, Heal(config, HealParams{Nodes: addrs[:1]}))
+	update := lastGatingUpdate(t, nodes[addrs[0]])
+	require.True(t, update.Isolate)
+	require.Empty(t, update.BannedPeers)
+	require.Empty(t, update.AddedPeers)
+	require.Equal(t, trusted, update.TrustedPeers)
+}
+
+func TestPartitionLinks(t *testing.T) {
+	config, nodes, peers := startPartitionNodes(t, 4)
+	addrs := make([]NodeAddress, 0, len(nodes))
+	for addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	var partitions [][]NodeAddress
+	require.NoError(t, Partition(config, PartitionParams{
+		Nodes:  addrs,
+		Ratios: []float64{1, 1},
+		Links:  []PartitionLink{{From: 1, To: 2, Ratio: 0.5, OneWay: true}},
+	}, func(_ int, p []NodeAddress) {
+		partitions = append(partitions, p)
+	}))
+	for _, addr := range partitions[0] {
+		// Only the linked node of the second partition is accepted
+		require.Equal(t, peersOf(peers, partitions[1][1]), lastGatingUpdate(t, nodes[addr]).BannedPeers)
+	}
+	for _, addr := range partitions[1] {
+		require.ElementsMatch(t, peersOf(peers, partitions[0]...), lastGatingUpdate(t, nodes[addr]).BannedPeers)
+	}
+
+	err := Partition(config, PartitionParams{Nodes: addrs, Ratios: []float64{1, 1}, Links: []PartitionLink{{From: 1, To: 3}}}, nil)
+	require.Error(t, err)
+}
@@ -27,6 +27,8 @@ type PaymentParams struct {
 type ScheduledPaymentsReceipt struct {
 	Address NodeAddress `json:"address"`
 	Handle  string      `json:"handle"`
+	// Kind of scheduled transactions, as labeled in metrics
+	Kind string `json:"kind,omitempty"`
 }
 
 func PaymentKeygenRequirements(gap int, params PaymentSubParams) (int, uint64) {
@@ -57,54 +59,23 @@ func schedulePaymentsDo(config Config, params PaymentSubParams, nodeAddress Node
 	handle, err := SchedulePaymentsGql(config, nodeAddress, paymentInput)
 	if err == nil {
 		config.Log.Infof("scheduled payment batch %d with tps %f for %s: %s", batchIx, tps, nodeAddress, handle)
+		observeScheduled(nodeAddress, "payments", tps)
 	}
 	return handle, err
 }
 
 func SchedulePayments(config Config, params PaymentParams, output func(ScheduledPaymentsReceipt)) error {
-	tps, nodes := selectNodes(params.Tps, params.MinTps, params.Nodes)
-	feePayersPerNode := len(params.FeePayers) / len(nodes)
-	successfulNodes := make([]NodeAddress, 0, len(nodes))
-	remTps := params.Tps
-	remFeePayers := params.FeePayers
-	var err error
-	for nodeIx, nodeAddress := range nodes {
-		feePayers := remFeePayers[:feePayersPerNode]
-		var handle string
-		handle, err = schedulePaymentsDo(config, params.PaymentSubParams, nodeAddress, len(successfulNodes), tps, feePayers)
-		if err != nil {
-			config.Log.Warnf("error scheduling payments for %s: %v", nodeAddress, err)
-			n := len(nodes) - nodeIx - 1
-			if n > 0 {
-				tps = remTps / float64(n)
-				feePayersPerNode = len(remFeePayers) / n
-			}
-			continue
-		}
-		successfulNodes = append(successfulNodes, nodeAddress)
-		remFeePayers = remFeePayers[feePayersPerNode:]
-		remTps -= tps
-		output(ScheduledPaymentsReceipt{
-			Address: nodeAddress,
-			Handle:  handle,
-		})
+	schedule := func(config Config, nodeAddress NodeAddress, batchIx int, tps float64, feePayers []itn_json_types.MinaPrivateKey) (string, error) {
+		return schedulePaymentsDo(config, params.PaymentSubParams, nodeAddress, batchIx, tps, feePayers)
 	}
-	if err != nil {
-		// last schedule payment request didn't work well
-		for _, nodeAddress := range successfulNodes {
-			handle, err2 := schedulePaymentsDo(config, params.PaymentSubParams, nodeAddress, len(successfulNodes), tps, remFeePayers)
-			if err2 != nil {
-				config.Log.Warnf("error scheduling second batch of payments for %s: %v", nodeAddress, err2)
-				continue
-			}
+	return scheduleOnNodes(config, "payments", params.Tps, params.MinTps, params.Nodes, params.FeePayers, schedule,
+		func(nodeAddress NodeAddress, handle string) {
 			output(ScheduledPaymentsReceipt{
 				Address: nodeAddress,
 				Handle:  handle,
+				Kind:    "payments",
 			})
-			return nil
-		}
-	}
-	return err
+		})
 }
 
 type PaymentsAction struct{}
@@ -115,6 +86,7 @@ func (PaymentsAction) Run(config Config, rawParams json.RawMessage, output Outpu
 		return err
 	}
 	return SchedulePayments(config, params, func(receipt ScheduledPaymentsReceipt) {
+		config.Receipts.Add(receipt)
 		output("receipt", receipt, true, false)
 		output("participant", receipt.Address, true, false)
 	})
@@ -122,4 +94,11 @@ func (PaymentsAction) Run(config Config, rawParams json.RawMessage, output Outpu
 
 func (PaymentsAction) Name() string { return "payments" }
 
-var _ Action = PaymentsAction{}
+func (PaymentsAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: PaymentParams{}, Outputs: []OutputSpec{
+		{Name: "receipt", Kind: ObjectKind, Multi: true},
+		{Name: "participant", Kind: StringKind, Multi: true},
+	}}
+}
+
+var _ DeclaredAction = PaymentsAction{}
@@ -0,0 +1,75 @@
+package itn_orchestrator
+
+// ExperimentPlan is the generated experiment: the script and summaries
+// of the experiment are rendered from it
+type ExperimentPlan struct {
+	Seed           int64 `json:"seed"`
+	ZkappSoftLimit int   `json:"zkappSoftLimit"`
+	// Command generating funding keys, nil if private keys are used directly
+	FundKeysCommand *FundParams `json:"fundKeys,omitempty"`
+	// Commands funding keys of rounds, performed before the first round
+	FundCommands []FundParams     `json:"fundCommands"`
+	Rounds       []GeneratedRound `json:"rounds"`
+}
+
+// LoadWindow is a part of a round during which transactions are sent with a constant tps
+type LoadWindow struct {
+	// Start of the window, seconds after start of the round
+	StartSec    int     `json:"startSec"`
+	DurationMin int     `json:"durationMin"`
+	PaymentTps  float64 `json:"paymentTps"`
+	ZkappTps    float64 `json:"zkappTps"`
+	MaxCost     bool    `json:"maxCost,omitempty"`
+}
+
+// Payments returns number of payments sent within the window
+func (w LoadWindow) Payments() int {
+	return int(w.PaymentTps * float64(w.DurationMin) * 60)
+}
+
+// Zkapps returns number of zkapp transactions sent within the window
+func (w LoadWindow) Zkapps() int {
+	return int(w.ZkappTps * float64(w.DurationMin) * 60)
+}
+
+// DisruptionKind is either a kind of stop or a fault kind
+type DisruptionKind string
+
+const (
+	StopCleanDisruption DisruptionKind = "stop-clean"
+	StopDisruption      DisruptionKind = "stop"
+)
+
+// Disruption is a stop or fault injection applied to a ratio of nodes
+type Disruption struct {
+	Kind  DisruptionKind `json:"kind"`
+	Ratio float64        `json:"ratio"`
+}
+
+// StopEvent is a set of disruptions performed at once within a round
+type StopEvent struct {
+	// Time of the event, seconds after start of the round
+	AtSec       int          `json:"atSec"`
+	Disruptions []Disruption `json:"disruptions"`
+}
+
+// Plan generates the experiment, random seed is chosen and set to params if none is set
+func (p *GenParams) Plan() *ExperimentPlan {
+	if p.Seed == 0 {
+		p.Seed = NewSeed()
+	}
+	p.rng = nil
+	p.overrunMin = 0
+	rounds := make([]GeneratedRound, p.Rounds)
+	for r := range rounds {
+		rounds[r] = p.Generate(r)
+	}
+	fundKeysCmd, fundCmds := planFunding(p, fundCommands(rounds))
+	return &ExperimentPlan{
+		Seed:            p.Seed,
+		ZkappSoftLimit:  p.ZkappSoftLimit,
+		FundKeysCommand: fundKeysCmd,
+		FundCommands:    fundCmds,
+		Rounds:          rounds,
+	}
+}
@@ -0,0 +1,132 @@
+package itn_orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan(t *testing.T) {
+	p := someParams()
+	p.Seed = 3
+	p.Rounds = 12
+	p.Partitions = 2
+	p.PartitionMin = 5
+	p.RotationKeys = []string{"B62qpPita1s7Dbnr7MVb3UK8fdssZixL1a4536aeMYxbTJEtRGGyS8U"}
+	p.RotationServers = []string{"localhost:1"}
+	var script []any
+	plan := Encode(&p, func(cmd GeneratedCommand) {
+		script = append(script, cmd)
+	}, func(comment string) {
+		script = append(script, comment)
+	})
+
+	// Script is rendered from the plan
+	var rendered []any
+	plan.Encode(func(cmd GeneratedCommand) {
+		rendered = append(rendered, cmd)
+	}, func(comment string) {
+		rendered = append(rendered, comment)
+	})
+	require.Equal(t, script, rendered)
+
+	require.Equal(t, int64(3), plan.Seed)
+	require.NotNil(t, plan.FundKeysCommand)
+	require.Len(t, plan.Rounds, 12)
+	fundCmds := 0
+	for r, round := range plan.Rounds {
+		require.Equal(t, r, round.Round)
+		require.NotNil(t, round.Rotation)
+		require.Equal(t, 2, round.Partitions)
+		require.Equal(t, fmt.Sprintf("%s/test/round-%d/zkapps", p.FundKeyPrefix, r), round.ZkappKeysDir)
+		if round.PaymentFundCommand != nil {
+			require.Equal(t, round.PaymentKeysDir+"/key", round.PaymentFundCommand.Prefix)
+			fundCmds++
+		}
+		fundCmds++
+	}
+	require.Equal(t, 10, plan.Rounds[9].PauseMin)
+	require.Zero(t, plan.Rounds[9].LargePauseMin)
+	require.Equal(t, 240, plan.Rounds[7].LargePauseMin)
+	require.Len(t, plan.FundCommands, fundCmds)
+
+	// Timeline of the plan doesn't include commands
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	var timeline struct {
+		Rounds []map[string]json.RawMessage `json:"rounds"`
+	}
+	require.NoError(t, json.Unmarshal(data, &timeline))
+	require.Len(t, timeline.Rounds, 12)
+	require.Equal(t, "11", string(timeline.Rounds[11]["round"]))
+	require.NotContains(t, timeline.Rounds[0], "Commands")
+	require.Contains(t, timeline.Rounds[0], "stops")
+}
+
+func TestPlanWaitReady(t *testing.T) {
+	p := someParams()
+	p.Seed = 7
+	p.Rounds = 6
+	p.StopsPerRound = 3
+	p.WaitReadyTimeoutMin = 10
+	plan := p.Plan()
+	budgetSec := waitReadyDelaySec + p.WaitReadyTimeoutMin*60
+	for r, round := range plan.Rounds {
+		if r > 0 {
+			prev := plan.Rounds[r-1]
+			require.Equal(t, prev.StartMin+prev.DurationMin+prev.PauseMin+prev.LargePauseMin, round.StartMin)
+		}
+		// Waits of the round and budgets of waiting for nodes to get ready add up to its duration
+		elapsed := 0
+		for _, cmd := range round.Commands {
+			switch params := cmd.Params.(type) {
+			case WaitParams:
+				if params.Seconds > 0 {
+					elapsed += params.Seconds
+				}
+			case WaitReadyRefParams:
+				elapsed += budgetSec
+			}
+		}
+		if r < len(plan.Rounds)-1 {
+			require.Equal(t, round.DurationMin*60, elapsed)
+		}
+		require.GreaterOrEqual(t, round.DurationMin, p.RoundDurationMin)
+		for i := 1; i < len(round.Stops); i++ {
+			require.GreaterOrEqual(t, round.Stops[i].AtSec, round.Stops[i-1].AtSec+budgetSec)
+		}
+	}
+	// Three stops don't fit into 50 minutes along with the waits
+	require.Greater(t, plan.Rounds[0].DurationMin, p.RoundDurationMin)
+}
+
+func TestReadScriptSeed(t *testing.T) {
+	p := someParams()
+	p.Seed = 42
+	p.Rounds = 1
+	var script bytes.Buffer
+	encoder := json.NewEncoder(&script)
+	Encode(&p, func(cmd GeneratedCommand) {
+		require.NoError(t, encoder.Encode(cmd))
+	}, func(comment string) {
+		require.NoError(t, encoder.Encode(comment))
+	})
+	seed, r, err := ReadScriptSeed(bytes.NewReader(script.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, int64(42), seed)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, script.Bytes(), read)
+
+	seed, r, err = ReadScriptSeed(strings.NewReader(`{"action":"discovery","params":{}}`))
+	require.NoError(t, err)
+	require.Zero(t, seed)
+	read, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, `{"action":"discovery","params":{}}`, string(read))
+}
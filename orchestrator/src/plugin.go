@@ -0,0 +1,95 @@
+package itn_orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// PluginConfig declares an action implemented by an external executable
+type PluginConfig struct {
+	Name string   `json:"name"`
+	Exec string   `json:"exec"`
+	Args []string `json:"args,omitempty"`
+}
+
+// PluginOutput is a line written by a plugin to its stdout
+type PluginOutput struct {
+	Name      string          `json:"name"`
+	Multi     bool            `json:"multi"`
+	Value     json.RawMessage `json:"value"`
+	Sensitive bool            `json:"sensitive,omitempty"`
+}
+
+// PluginAction writes resolved params of the step to stdin of the executable.
+// Each JSON object written by the executable to stdout is treated as an output of the step,
+// stderr of the executable is passed through. Step fails if the executable exits with non-zero code.
+type PluginAction struct {
+	config PluginConfig
+}
+
+func (a PluginAction) Name() string { return a.config.Name }
+
+func (a PluginAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	ctx, cancelF := context.WithCancel(config.Ctx)
+	defer cancelF()
+	cmd := exec.CommandContext(ctx, a.config.Exec, a.config.Args...)
+	cmd.Stdin = bytes.NewReader(rawParams)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin %s: %v", a.config.Exec, err)
+	}
+	outErr := readPluginOutputs(stdout, output)
+	if outErr != nil {
+		cancelF()
+	}
+	if err := cmd.Wait(); err != nil && outErr == nil {
+		return fmt.Errorf("plugin %s failed: %v", a.config.Exec, err)
+	}
+	return outErr
+}
+
+func readPluginOutputs(r io.Reader, output OutputF) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var out PluginOutput
+		if err := decoder.Decode(&out); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to decode plugin output: %v", err)
+		}
+		if out.Name == "" {
+			return errors.New("plugin output without a name")
+		}
+		if err := output(out.Name, out.Value, out.Multi, out.Sensitive); err != nil {
+			return err
+		}
+	}
+}
+
+var _ Action = PluginAction{}
+
+// RegisterPlugins adds plugin actions declared in the config, plugins
+// can't replace built-in actions or each other
+func RegisterPlugins(plugins []PluginConfig) error {
+	for _, p := range plugins {
+		if p.Name == "" || p.Exec == "" {
+			return fmt.Errorf("plugin %q: both name and exec are required", p.Name)
+		}
+		if actions[p.Name] != nil || compositeActions[p.Name] != nil {
+			return fmt.Errorf("plugin %s: action with the same name already exists", p.Name)
+		}
+		addAction(actions, PluginAction{config: p})
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginAction(t *testing.T) {
+	log := logging.Logger("test")
+	config := Config{Ctx: context.Background(), Log: log}
+	script := `p=$(cat); echo "{\"name\":\"params\",\"value\":$p}"; echo '{"name":"n","multi":true,"value":1}{"name":"n","multi":true,"value":2}'`
+	action := PluginAction{config: PluginConfig{Name: "echo", Exec: "sh", Args: []string{"-c", script}}}
+	outCache := EmptyOutputCache()
+	require.NoError(t, action.Run(config, json.RawMessage(`{"a":"b"}`), outputF(outCache, log, nil, 0)))
+	entry, has := outCache.lookup("", 0, "params")
+	require.True(t, has)
+	require.JSONEq(t, `{"a":"b"}`, string(entry.Values[0]))
+	entry, has = outCache.lookup("", 0, "n")
+	require.True(t, has)
+	require.Equal(t, []json.RawMessage{json.RawMessage("1"), json.RawMessage("2")}, entry.Values)
+
+	failing := PluginAction{config: PluginConfig{Name: "fail", Exec: "sh", Args: []string{"-c", "exit 3"}}}
+	require.Error(t, failing.Run(config, json.RawMessage(`{}`), outputF(EmptyOutputCache(), log, nil, 0)))
+
+	require.Error(t, RegisterPlugins([]PluginConfig{{Name: "wait", Exec: "sh"}}))
+}
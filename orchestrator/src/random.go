@@ -0,0 +1,53 @@
+package itn_orchestrator
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedSource is a source of random numbers safe for concurrent use
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// NewRand returns a generator of random numbers seeded with the seed, safe for concurrent use
+// (except for its Read method)
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// NewSeed returns a seed to use when none is provided
+func NewSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+var defaultRand = NewRand(NewSeed())
+
+// rand returns the generator of random numbers of the config,
+// a randomly seeded one if none was set up
+func (config Config) rand() *rand.Rand {
+	if config.Rand != nil {
+		return config.Rand
+	}
+	return defaultRand
+}
@@ -0,0 +1,47 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodeToStrings(t *testing.T, p GenParams) []string {
+	var res []string
+	Encode(&p, func(cmd GeneratedCommand) {
+		b, err := json.Marshal(cmd)
+		require.NoError(t, err)
+		res = append(res, string(b))
+	}, func(comment string) {
+		res = append(res, comment)
+	})
+	return res
+}
+
+func TestSeededGeneration(t *testing.T) {
+	p := someParams()
+	p.Rounds = 4
+	p.StopsPerRound = 3
+	p.Seed = 42
+	script := encodeToStrings(t, p)
+	require.Contains(t, script, "Seed: 42")
+	require.Equal(t, script, encodeToStrings(t, p))
+	p.Seed = 43
+	require.NotEqual(t, script, encodeToStrings(t, p))
+
+	// Random seed is chosen and recorded when none is set
+	p.Seed = 0
+	Encode(&p, func(GeneratedCommand) {}, func(string) {})
+	require.NotZero(t, p.Seed)
+}
+
+func TestSeededSelectNodes(t *testing.T) {
+	nodes := []NodeAddress{"a:1", "b:1", "c:1", "d:1", "e:1"}
+	sel := func(seed int64) []NodeAddress {
+		_, selected := selectNodes(NewRand(seed), 1, 0.4, append([]NodeAddress{}, nodes...))
+		return selected
+	}
+	require.Equal(t, sel(7), sel(7))
+	require.Len(t, sel(7), 2)
+}
@@ -0,0 +1,173 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// NodeStatus is the state of a node relevant to its readiness
+type NodeStatus struct {
+	Synced      bool `json:"synced"`
+	BlockHeight int  `json:"blockHeight"`
+}
+
+// ReadinessProbe retrieves the status of a node
+type ReadinessProbe interface {
+	NodeStatus(config Config, addr NodeAddress) (NodeStatus, error)
+}
+
+// ExecProbe retrieves the node status by running the executable as
+// `<exec> <args...> node-status <address>`, which prints the status as JSON to stdout
+type ExecProbe struct {
+	Exec string   `json:"exec"`
+	Args []string `json:"args,omitempty"`
+}
+
+func (p ExecProbe) NodeStatus(config Config, addr NodeAddress) (NodeStatus, error) {
+	cmd := exec.CommandContext(config.Ctx, p.Exec, append(append([]string{}, p.Args...), "node-status", string(addr))...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return NodeStatus{}, fmt.Errorf("failed to get status of %s: %v", addr, err)
+	}
+	var status NodeStatus
+	if err := json.Unmarshal(out, &status); err != nil {
+		return NodeStatus{}, fmt.Errorf("failed to parse status of %s: %v", addr, err)
+	}
+	return status, nil
+}
+
+var _ ReadinessProbe = ExecProbe{}
+
+func readinessProbe(config *ExecProbe) ReadinessProbe {
+	if config == nil || config.Exec == "" {
+		return nil
+	}
+	return *config
+}
+
+type WaitReadyParams struct {
+	Nodes []NodeAddress `json:"nodes"`
+	// Least block height of a ready node
+	MinHeight int `json:"minHeight,omitempty"`
+	// Ready node is at most maxLag blocks behind the highest node of the group
+	MaxLag int `json:"maxLag,omitempty"`
+	// Delay before the first poll, e.g. to let stop-daemon take effect
+	DelaySec   int `json:"delaySec,omitempty"`
+	PollSec    int `json:"pollSec,omitempty"`
+	TimeoutSec int `json:"timeoutSec"`
+}
+
+const defaultWaitReadyPollSec = 10
+
+// WaitReady polls nodes until each of them is synced and its block height is at least the
+// expected one. Expected height is the maximum of minHeight and the height of the highest node
+// of the group (minus maxLag). Nodes that aren't ready by the timeout are reported as not ready.
+func WaitReady(config Config, params WaitReadyParams, probe ReadinessProbe, output func(addr NodeAddress, ready bool)) error {
+	if params.TimeoutSec <= 0 {
+		return errors.New("timeout should be positive")
+	}
+	pollSec := params.PollSec
+	if pollSec <= 0 {
+		pollSec = defaultWaitReadyPollSec
+	}
+	deadline := time.Now().Add(time.Duration(params.DelaySec+params.TimeoutSec) * time.Second)
+	sleep := func(d time.Duration) bool {
+		if config.Ctx.Err() != nil {
+			return false
+		}
+		select {
+		case <-config.Ctx.Done():
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+	if !sleep(time.Duration(params.DelaySec) * time.Second) {
+		return config.Ctx.Err()
+	}
+	pending := append([]NodeAddress{}, params.Nodes...)
+	maxHeight := 0
+	// Last error of the probe, per node
+	probeErrs := map[NodeAddress]error{}
+	for {
+		statuses := make(map[NodeAddress]NodeStatus, len(pending))
+		for _, addr := range pending {
+			status, err := probe.NodeStatus(config, addr)
+			if err != nil {
+				// Node is expected to be unreachable while restarting
+				config.Log.Infof("node %s isn't ready: %v", addr, err)
+				probeErrs[addr] = err
+				continue
+			}
+			delete(probeErrs, addr)
+			statuses[addr] = status
+			maxHeight = max(maxHeight, status.BlockHeight)
+		}
+		expected := max(params.MinHeight, maxHeight-params.MaxLag)
+		notReady := make([]NodeAddress, 0, len(pending))
+		for _, addr := range pending {
+			status, has := statuses[addr]
+			if has && status.Synced && status.BlockHeight >= expected {
+				config.Log.Infof("node %s is ready at height %d", addr, status.BlockHeight)
+				output(addr, true)
+			} else {
+				notReady = append(notReady, addr)
+			}
+		}
+		pending = notReady
+		if len(pending) == 0 {
+			return nil
+		}
+		wait := time.Duration(pollSec) * time.Second
+		if time.Now().Add(wait).After(deadline) {
+			break
+		}
+		if !sleep(wait) {
+			return config.Ctx.Err()
+		}
+	}
+	for _, addr := range pending {
+		if err := probeErrs[addr]; err != nil {
+			config.Log.Warnf("node %s isn't ready after %d seconds, status query failed: %v", addr, params.TimeoutSec, err)
+		} else {
+			config.Log.Warnf("node %s isn't ready after %d seconds", addr, params.TimeoutSec)
+		}
+		output(addr, false)
+	}
+	return nil
+}
+
+type WaitReadyAction struct{}
+
+func (WaitReadyAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	var params WaitReadyParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	if config.Readiness == nil {
+		return errors.New("no readiness probe configured")
+	}
+	return WaitReady(config, params, config.Readiness, func(addr NodeAddress, ready bool) {
+		if ready {
+			output("ready", addr, true, false)
+		} else {
+			output("notReady", addr, true, false)
+		}
+	})
+}
+
+func (WaitReadyAction) Name() string { return "wait-ready" }
+
+func (WaitReadyAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: WaitReadyParams{}, Outputs: []OutputSpec{
+		{Name: "ready", Kind: StringKind, Multi: true},
+		{Name: "notReady", Kind: StringKind, Multi: true},
+	}}
+}
+
+var _ DeclaredAction = WaitReadyAction{}
@@ -0,0 +1,94 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns statuses of a node from the list, one per poll, repeating the last one
+type testProbe struct {
+	mu       sync.Mutex
+	statuses map[NodeAddress][]NodeStatus
+}
+
+func (p *testProbe) NodeStatus(_ Config, addr NodeAddress) (NodeStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := p.statuses[addr]
+	if len(statuses) == 0 {
+		return NodeStatus{}, errors.New("unreachable")
+	}
+	res := statuses[0]
+	if len(statuses) > 1 {
+		p.statuses[addr] = statuses[1:]
+	}
+	return res, nil
+}
+
+func TestWaitReady(t *testing.T) {
+	probe := &testProbe{statuses: map[NodeAddress][]NodeStatus{
+		"a:1": {{Synced: true, BlockHeight: 10}},
+		"b:1": {{Synced: false, BlockHeight: 3}, {Synced: true, BlockHeight: 11}},
+		"c:1": {{Synced: true, BlockHeight: 5}},
+	}}
+	config := Config{Ctx: context.Background(), Log: logging.Logger("test"), Readiness: probe}
+	params, err := json.Marshal(WaitReadyParams{
+		Nodes:      []NodeAddress{"a:1", "b:1", "c:1", "d:1"},
+		MaxLag:     2,
+		PollSec:    1,
+		TimeoutSec: 2,
+	})
+	require.NoError(t, err)
+	outputs := map[string][]NodeAddress{}
+	err = WaitReadyAction{}.Run(config, params, func(name string, value any, multiple bool, sensitive bool) error {
+		require.True(t, multiple)
+		outputs[name] = append(outputs[name], value.(NodeAddress))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []NodeAddress{"a:1", "b:1"}, outputs["ready"])
+	require.Equal(t, []NodeAddress{"c:1", "d:1"}, outputs["notReady"])
+
+	ctx, cancelF := context.WithCancel(context.Background())
+	cancelF()
+	config.Ctx = ctx
+	err = WaitReady(config, WaitReadyParams{Nodes: []NodeAddress{"c:1"}, PollSec: 1, TimeoutSec: 10}, probe, func(NodeAddress, bool) {})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestExecProbe(t *testing.T) {
+	exec := filepath.Join(t.TempDir(), "status")
+	script := `#!/bin/sh
+[ "$1" = "--net" ] && [ "$3" = "node-status" ] || exit 1
+case "$4" in
+  a:1) echo '{"synced":true,"blockHeight":12}' ;;
+  b:1) echo 'garbage' ;;
+  *) exit 1 ;;
+esac
+`
+	require.NoError(t, os.WriteFile(exec, []byte(script), 0755))
+	config := Config{Ctx: context.Background(), Log: logging.Logger("test")}
+	probe := readinessProbe(&ExecProbe{Exec: exec, Args: []string{"--net", "itn"}})
+	status, err := probe.NodeStatus(config, "a:1")
+	require.NoError(t, err)
+	require.Equal(t, NodeStatus{Synced: true, BlockHeight: 12}, status)
+	_, err = probe.NodeStatus(config, "b:1")
+	require.ErrorContains(t, err, "failed to parse status of b:1")
+	_, err = probe.NodeStatus(config, "c:1")
+	require.ErrorContains(t, err, "failed to get status of c:1")
+
+	require.Nil(t, readinessProbe(nil))
+	require.Nil(t, readinessProbe(&ExecProbe{}))
+	params, err := json.Marshal(WaitReadyParams{Nodes: []NodeAddress{"a:1"}, TimeoutSec: 1})
+	require.NoError(t, err)
+	err = WaitReadyAction{}.Run(config, params, func(string, any, bool, bool) error { return nil })
+	require.EqualError(t, err, "no readiness probe configured")
+}
@@ -0,0 +1,89 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Time given to stop outstanding transactions after the experiment is canceled or failed
+const stopOutstandingTimeout = 2 * time.Minute
+
+// ReceiptTracker keeps receipts of scheduled transactions (both payments
+// and zkapp commands) that weren't stopped by a stop step yet
+type ReceiptTracker struct {
+	mu       sync.Mutex
+	receipts map[ScheduledPaymentsReceipt]struct{}
+}
+
+func NewReceiptTracker() *ReceiptTracker {
+	return &ReceiptTracker{receipts: map[ScheduledPaymentsReceipt]struct{}{}}
+}
+
+func (t *ReceiptTracker) Add(receipt ScheduledPaymentsReceipt) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.receipts[receipt] = struct{}{}
+}
+
+func (t *ReceiptTracker) Remove(receipt ScheduledPaymentsReceipt) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.receipts, receipt)
+}
+
+// Outstanding returns receipts not stopped yet, ordered by address and handle
+func (t *ReceiptTracker) Outstanding() []ScheduledPaymentsReceipt {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := make([]ScheduledPaymentsReceipt, 0, len(t.receipts))
+	for r := range t.receipts {
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Address != res[j].Address {
+			return res[i].Address < res[j].Address
+		}
+		return res[i].Handle < res[j].Handle
+	})
+	return res
+}
+
+// StopOutstandingTransactions stops all scheduled transactions that weren't stopped
+// by the script. It's meant to be called after the experiment is canceled or failed,
+// hence it doesn't use the (possibly canceled) context of the config.
+// Every receipt is attempted, failures are logged and returned joined.
+func StopOutstandingTransactions(config Config) error {
+	receipts := config.Receipts.Outstanding()
+	if len(receipts) == 0 {
+		return nil
+	}
+	config.Log.Infof("Stopping %d outstanding scheduled transaction batches", len(receipts))
+	ctx, cancelF := context.WithTimeout(context.Background(), stopOutstandingTimeout)
+	defer cancelF()
+	config.Ctx = ctx
+	errs := []error{}
+	for _, receipt := range receipts {
+		resp, err := StopTransactionsGql(config, receipt.Address, receipt.Handle)
+		if err != nil {
+			config.Log.Warnf("failed to stop outstanding transactions: %v", err)
+			errs = append(errs, err)
+			continue
+		}
+		config.Receipts.Remove(receipt)
+		observeStopped(receipt.Address, receipt.Kind)
+		config.Log.Infof("stopped outstanding transactions at %s on %s: %s", receipt.Handle, receipt.Address, resp)
+	}
+	return errors.Join(errs...)
+}
@@ -37,10 +37,16 @@ func (RestartAction) Run(config Config, rawParams json.RawMessage, output Output
 		if err := cmd.Run(); err != nil {
 			return err
 		}
+		// Restarted node doesn't recognize the session, it's re-authenticated on the next request
+		config.Nodes.Expire(addr)
 	}
 	return nil
 }
 
 func (RestartAction) Name() string { return "restart" }
 
-var _ Action = RestartAction{}
+func (RestartAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: RestartParams{}, Outputs: nil}
+}
+
+var _ DeclaredAction = RestartAction{}
@@ -0,0 +1,222 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// Name of the param that overrides retry policies for a single step, accepted by every action
+const retryParam = "retry"
+
+// RetryPolicy describes how a failed operation is retried.
+// Zero values of fields are inherited from the less specific policy.
+type RetryPolicy struct {
+	// Total number of attempts, 1 means no retries
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// Delay before the first retry
+	BaseDelayMs int `json:"baseDelayMs,omitempty"`
+	// Upper bound of the delay
+	MaxDelayMs int `json:"maxDelayMs,omitempty"`
+	// Factor the delay is multiplied by after each retry, 1 for a constant delay
+	Multiplier float64 `json:"multiplier,omitempty"`
+	// Delay is randomly adjusted by up to the given fraction of it, e.g. 0.1 for ±10%
+	Jitter float64 `json:"jitter,omitempty"`
+	// HTTP status codes of retryable errors (applicable to GraphQL and uptime data requests)
+	RetryOnStatus []int `json:"retryOnStatus,omitempty"`
+	// Substrings of messages of retryable errors.
+	// When neither status codes nor messages are given, every error is retryable
+	// (only connection errors and 5xx statuses for GraphQL requests).
+	RetryOnErrors []string `json:"retryOnErrors,omitempty"`
+	// Only connection errors and 5xx statuses are retryable by default
+	onlyTransient bool
+}
+
+// RetryConfig is a policy applied to all retried operations
+// with optional overrides for each kind of operation
+type RetryConfig struct {
+	RetryPolicy
+	// Requests to GraphQL API of nodes
+	Graphql *RetryPolicy `json:"graphql,omitempty"`
+	// Discovery of participants
+	Discovery *RetryPolicy `json:"discovery,omitempty"`
+	// Retrieval of uptime submissions from the online URL
+	Submissions *RetryPolicy `json:"submissions,omitempty"`
+	// Mina CLI commands (funding, balance queries)
+	MinaCli *RetryPolicy `json:"minaCli,omitempty"`
+}
+
+// Defaults reproduce the behavior preceding the configurable policies
+var (
+	defaultGraphqlRetry     = RetryPolicy{MaxAttempts: 1, BaseDelayMs: 1000, Multiplier: 2, onlyTransient: true}
+	defaultDiscoveryRetry   = RetryPolicy{MaxAttempts: 3, BaseDelayMs: 10 * 60000, Multiplier: 2}
+	defaultSubmissionsRetry = RetryPolicy{MaxAttempts: 5, BaseDelayMs: 60000, Multiplier: 1}
+	defaultMinaCliRetry     = RetryPolicy{MaxAttempts: 5, BaseDelayMs: 60000, Multiplier: 2}
+)
+
+func (p RetryPolicy) override(o *RetryPolicy) RetryPolicy {
+	if o == nil {
+		return p
+	}
+	if o.MaxAttempts != 0 {
+		p.MaxAttempts = o.MaxAttempts
+	}
+	if o.BaseDelayMs != 0 {
+		p.BaseDelayMs = o.BaseDelayMs
+	}
+	if o.MaxDelayMs != 0 {
+		p.MaxDelayMs = o.MaxDelayMs
+	}
+	if o.Multiplier != 0 {
+		p.Multiplier = o.Multiplier
+	}
+	if o.Jitter != 0 {
+		p.Jitter = o.Jitter
+	}
+	if o.RetryOnStatus != nil || o.RetryOnErrors != nil {
+		p.RetryOnStatus = o.RetryOnStatus
+		p.RetryOnErrors = o.RetryOnErrors
+	}
+	return p
+}
+
+func overridePolicy(p, o *RetryPolicy) *RetryPolicy {
+	if p == nil {
+		return o
+	}
+	res := p.override(o)
+	return &res
+}
+
+// Merge returns the config with policies overridden by the other config
+func (c RetryConfig) Merge(o RetryConfig) RetryConfig {
+	return RetryConfig{
+		RetryPolicy: c.RetryPolicy.override(&o.RetryPolicy),
+		Graphql:     overridePolicy(c.Graphql, o.Graphql),
+		Discovery:   overridePolicy(c.Discovery, o.Discovery),
+		Submissions: overridePolicy(c.Submissions, o.Submissions),
+		MinaCli:     overridePolicy(c.MinaCli, o.MinaCli),
+	}
+}
+
+// graphql returns policy of GraphQL requests, which doesn't inherit the number of attempts
+// of the top-level policy, as requests of mutations (e.g. scheduling payments) aren't idempotent
+func (c RetryConfig) graphql() RetryPolicy {
+	common := c.RetryPolicy
+	common.MaxAttempts = 0
+	return defaultGraphqlRetry.override(&common).override(c.Graphql)
+}
+
+func (c RetryConfig) discovery() RetryPolicy {
+	return defaultDiscoveryRetry.override(&c.RetryPolicy).override(c.Discovery)
+}
+
+func (c RetryConfig) submissions() RetryPolicy {
+	return defaultSubmissionsRetry.override(&c.RetryPolicy).override(c.Submissions)
+}
+
+func (c RetryConfig) minaCli() RetryPolicy {
+	return defaultMinaCliRetry.override(&c.RetryPolicy).override(c.MinaCli)
+}
+
+// withStepRetry applies retry param of the step (if any) to the config
+func withStepRetry(config Config, params json.RawMessage) (Config, error) {
+	var p struct {
+		Retry *RetryConfig `json:"retry"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return config, fmt.Errorf("failed to decode %s param: %v", retryParam, err)
+	}
+	if p.Retry != nil {
+		config.Retry = config.Retry.Merge(*p.Retry)
+	}
+	return config, nil
+}
+
+// httpStatusError is an error of HTTP request that has the status code available for retry policies
+type httpStatusError struct {
+	code int
+	err  error
+}
+
+func (e *httpStatusError) Error() string { return e.err.Error() }
+func (e *httpStatusError) Unwrap() error { return e.err }
+
+// isTransientError tells whether the error is a connection error (other than a timeout,
+// after which the request may have been processed) or a 5xx status
+func isTransientError(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return !urlErr.Timeout()
+	}
+	var statusErr *httpStatusError
+	return errors.As(err, &statusErr) && statusErr.code >= 500
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if len(p.RetryOnStatus) == 0 && len(p.RetryOnErrors) == 0 {
+		return !p.onlyTransient || isTransientError(err)
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && slices.Contains(p.RetryOnStatus, statusErr.code) {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range p.RetryOnErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the delay before retrying after the given (one-based) attempt
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	d := float64(p.BaseDelayMs) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelayMs > 0 && d > float64(p.MaxDelayMs) {
+		d = float64(p.MaxDelayMs)
+	}
+	if p.Jitter > 0 {
+		// Global source is used deliberately, so that retries don't
+		// affect random choices of the (possibly seeded) experiment
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d * float64(time.Millisecond))
+}
+
+// retry performs the operation until it succeeds, fails with a non-retryable error or
+// the attempts are exhausted. Operation receives the zero-based number of the attempt.
+func retry(ctx context.Context, log logging.StandardLogger, policy RetryPolicy, what string, try func(attempt int) error) error {
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := try(attempt)
+		if err == nil {
+			return nil
+		}
+		if attempt+1 >= policy.MaxAttempts || !policy.retryable(err) {
+			return err
+		}
+		delay := policy.delay(attempt + 1)
+		log.Warnf("Failed to %s, retrying in %v: %s", what, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
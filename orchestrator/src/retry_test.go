@@ -0,0 +1,98 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyOverrides(t *testing.T) {
+	var config Config
+	require.NoError(t, json.Unmarshal([]byte(`{"maxAttempts":4,"minaCli":{"baseDelayMs":10}}`), &config.Retry))
+	stepConfig, err := withStepRetry(config, json.RawMessage(`{"num":1,"retry":{"minaCli":{"maxAttempts":2}}}`))
+	require.NoError(t, err)
+
+	require.Equal(t, RetryPolicy{MaxAttempts: 4, BaseDelayMs: 10, Multiplier: 2}, config.Retry.minaCli())
+	require.Equal(t, RetryPolicy{MaxAttempts: 2, BaseDelayMs: 10, Multiplier: 2}, stepConfig.Retry.minaCli())
+	require.Equal(t, 4, stepConfig.Retry.discovery().MaxAttempts)
+	require.Equal(t, defaultDiscoveryRetry.BaseDelayMs, stepConfig.Retry.discovery().BaseDelayMs)
+
+	_, err = withStepRetry(config, json.RawMessage(`{"retry":3}`))
+	require.Error(t, err)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelayMs: 100, MaxDelayMs: 300, Multiplier: 2}
+	require.Equal(t, 100*time.Millisecond, p.delay(1))
+	require.Equal(t, 200*time.Millisecond, p.delay(2))
+	require.Equal(t, 300*time.Millisecond, p.delay(3))
+	p.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := p.delay(1)
+		require.GreaterOrEqual(t, d, 50*time.Millisecond)
+		require.LessOrEqual(t, d, 150*time.Millisecond)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	log := logging.Logger("test")
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelayMs: 1, RetryOnStatus: []int{503}, RetryOnErrors: []string{"timeout"}}
+	attempts := 0
+	err := retry(context.Background(), log, policy, "test", func(int) error {
+		attempts++
+		if attempts == 1 {
+			return &httpStatusError{code: 503, err: errors.New("unavailable")}
+		}
+		if attempts == 2 {
+			return errors.New("i/o timeout")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = retry(context.Background(), log, policy, "test", func(int) error {
+		attempts++
+		return &httpStatusError{code: 400, err: errors.New("bad request")}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+
+	attempts = 0
+	policy.RetryOnStatus, policy.RetryOnErrors = nil, nil
+	err = retry(context.Background(), log, policy, "test", func(int) error {
+		attempts++
+		return errors.New("failure")
+	})
+	require.Error(t, err)
+	require.Equal(t, 3, attempts)
+}
+
+func TestGraphqlRetryPolicy(t *testing.T) {
+	var config Config
+	require.NoError(t, json.Unmarshal([]byte(`{"maxAttempts":4,"baseDelayMs":10}`), &config.Retry))
+	policy := config.Retry.graphql()
+	require.Equal(t, 1, policy.MaxAttempts, "attempts of mutations aren't inherited")
+	require.Equal(t, 10, policy.BaseDelayMs)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"graphql":{"maxAttempts":3}}`), &config.Retry))
+	policy = config.Retry.graphql()
+	require.Equal(t, 3, policy.MaxAttempts)
+	refused := &url.Error{Op: "Post", URL: "http://a:1", Err: errors.New("connection refused")}
+	timeout := &url.Error{Op: "Post", URL: "http://a:1", Err: context.DeadlineExceeded}
+	require.True(t, policy.retryable(refused))
+	require.True(t, policy.retryable(&httpStatusError{code: 502, err: errors.New("bad gateway")}))
+	require.False(t, policy.retryable(timeout))
+	require.False(t, policy.retryable(&httpStatusError{code: 400, err: errors.New("bad request")}))
+	require.False(t, policy.retryable(errors.New("graphql error")))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"graphql":{"maxAttempts":3,"retryOnErrors":["graphql"]}}`), &config.Retry))
+	require.True(t, config.Retry.graphql().retryable(errors.New("graphql error")))
+}
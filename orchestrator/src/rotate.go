@@ -55,7 +55,7 @@ func (RotateAction) Run(config Config, rawParams json.RawMessage, output OutputF
 	}
 	balances := make([]uint64, len(params.Pubkeys))
 	for i, pk := range params.Pubkeys {
-		err := retryOnMultipleServers(params.RestServers, config.Ctx, i, "rotate-get-balance", config.Log, func(restServer string) error {
+		err := retryOnMultipleServers(params.RestServers, config.Ctx, i, "rotate-get-balance", config.Log, config.Retry.minaCli(), func(restServer string) error {
 			var err error
 			balances[i], err = getBalance(config, restServer, pk)
 			return err
@@ -91,6 +91,12 @@ func (RotateAction) Run(config Config, rawParams json.RawMessage, output OutputF
 
 func (RotateAction) Name() string { return "rotate-balance" }
 
+func (RotateAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: RotateParams{}, Outputs: nil}
+}
+
+var _ DeclaredAction = RotateAction{}
+
 func getBalance(config Config, restServer, pubkey string) (result uint64, err error) {
 	args := []string{
 		"client", "get-balance",
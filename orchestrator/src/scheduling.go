@@ -0,0 +1,117 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"errors"
+	"itn_json_types"
+	"sync"
+	"time"
+)
+
+// SchedulingConfig controls how transactions are scheduled on multiple nodes
+type SchedulingConfig struct {
+	// Number of nodes transactions are scheduled on concurrently, 1 by default
+	Concurrency int `json:"concurrency,omitempty"`
+	// Timeout of a single scheduling request (including retries), no timeout when zero
+	RequestTimeoutSec int `json:"requestTimeoutSec,omitempty"`
+}
+
+// scheduleBatchF schedules a batch of transactions on the node and returns the handle of the batch
+type scheduleBatchF = func(config Config, nodeAddress NodeAddress, batchIx int, tps float64, feePayers []itn_json_types.MinaPrivateKey) (string, error)
+
+type scheduledBatch struct {
+	address   NodeAddress
+	batchIx   int
+	tps       float64
+	feePayers []itn_json_types.MinaPrivateKey
+	handle    string
+	err       error
+}
+
+func (c SchedulingConfig) scheduleBatch(config Config, schedule scheduleBatchF, b *scheduledBatch) {
+	if c.RequestTimeoutSec > 0 {
+		ctx, cancelF := context.WithTimeout(config.Ctx, time.Duration(c.RequestTimeoutSec)*time.Second)
+		defer cancelF()
+		config.Ctx = ctx
+	}
+	b.handle, b.err = schedule(config, b.address, b.batchIx, b.tps, b.feePayers)
+}
+
+// scheduleOnNodes splits TPS and fee payers evenly among the selected nodes and schedules
+// batches on up to the configured number of nodes at a time. Share of a failed node is
+// redistributed among nodes not yet tried. When nodes of the last round fail, the rest
+// of TPS and fee payers is scheduled as a second batch on one of the successful nodes.
+// Error of the last round is returned if the second batch couldn't be scheduled.
+// Batch indices (used in memos of transactions) are unique among the scheduled batches:
+// batches of a round get consecutive indices following the last scheduled batch.
+func scheduleOnNodes(config Config, what string, totalTps, minTps float64, allNodes []NodeAddress,
+	allFeePayers []itn_json_types.MinaPrivateKey, schedule scheduleBatchF, output func(NodeAddress, string)) error {
+	concurrency := config.Scheduling.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	tps, nodes := selectNodes(config.rand(), totalTps, minTps, allNodes)
+	feePayersPerNode := len(allFeePayers) / len(nodes)
+	successfulNodes := make([]NodeAddress, 0, len(nodes))
+	remTps := totalTps
+	remFeePayers := allFeePayers
+	// Index following the greatest index of a scheduled batch
+	nextBatchIx := 0
+	var err error
+	for len(nodes) > 0 {
+		round := make([]scheduledBatch, min(concurrency, len(nodes)))
+		for i := range round {
+			round[i] = scheduledBatch{
+				address:   nodes[i],
+				batchIx:   nextBatchIx + i,
+				tps:       tps,
+				feePayers: remFeePayers[i*feePayersPerNode : (i+1)*feePayersPerNode],
+			}
+		}
+		unassigned := remFeePayers[len(round)*feePayersPerNode:]
+		nodes = nodes[len(round):]
+		var wg sync.WaitGroup
+		for i := range round {
+			wg.Add(1)
+			go func(b *scheduledBatch) {
+				defer wg.Done()
+				config.Scheduling.scheduleBatch(config, schedule, b)
+			}(&round[i])
+		}
+		wg.Wait()
+		errs := []error{}
+		remFeePayers = []itn_json_types.MinaPrivateKey{}
+		for _, b := range round {
+			if b.err != nil {
+				config.Log.Warnf("error scheduling %s for %s: %v", what, b.address, b.err)
+				errs = append(errs, b.err)
+				remFeePayers = append(remFeePayers, b.feePayers...)
+				continue
+			}
+			successfulNodes = append(successfulNodes, b.address)
+			nextBatchIx = max(nextBatchIx, b.batchIx+1)
+			remTps -= b.tps
+			output(b.address, b.handle)
+		}
+		remFeePayers = append(remFeePayers, unassigned...)
+		err = errors.Join(errs...)
+		if err != nil && len(nodes) > 0 {
+			tps = remTps / float64(len(nodes))
+			feePayersPerNode = len(remFeePayers) / len(nodes)
+		}
+	}
+	if err != nil {
+		// last round of scheduling didn't work well
+		for _, nodeAddress := range successfulNodes {
+			b := scheduledBatch{address: nodeAddress, batchIx: nextBatchIx, tps: remTps, feePayers: remFeePayers}
+			config.Scheduling.scheduleBatch(config, schedule, &b)
+			if b.err != nil {
+				config.Log.Warnf("error scheduling second batch of %s for %s: %v", what, nodeAddress, b.err)
+				continue
+			}
+			output(nodeAddress, b.handle)
+			return nil
+		}
+	}
+	return err
+}
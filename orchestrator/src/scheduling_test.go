@@ -0,0 +1,106 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"itn_json_types"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type testBatch struct {
+	Address   NodeAddress
+	BatchIx   int
+	Tps       float64
+	FeePayers []itn_json_types.MinaPrivateKey
+}
+
+func runScheduleOnNodes(t *testing.T, scheduling SchedulingConfig, nodes []NodeAddress, failing NodeAddress) ([]testBatch, []NodeAddress, error) {
+	config := Config{Ctx: context.Background(), Log: logging.Logger("test"), Scheduling: scheduling}
+	feePayers := []itn_json_types.MinaPrivateKey{"k0", "k1", "k2", "k3", "k4", "k5"}
+	var mu sync.Mutex
+	var batches []testBatch
+	schedule := func(_ Config, addr NodeAddress, batchIx int, tps float64, feePayers []itn_json_types.MinaPrivateKey) (string, error) {
+		if addr == failing {
+			return "", errors.New("failed")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, testBatch{addr, batchIx, tps, feePayers})
+		return fmt.Sprintf("%s-%d", addr, batchIx), nil
+	}
+	var outputs []NodeAddress
+	err := scheduleOnNodes(config, "test", float64(len(nodes)), 1, nodes, feePayers, schedule, func(addr NodeAddress, handle string) {
+		require.Contains(t, handle, string(addr))
+		outputs = append(outputs, addr)
+	})
+	return batches, outputs, err
+}
+
+func TestScheduleOnNodesSequential(t *testing.T) {
+	batches, outputs, err := runScheduleOnNodes(t, SchedulingConfig{}, []NodeAddress{"a", "b", "c"}, "b")
+	require.NoError(t, err)
+	require.Equal(t, []testBatch{
+		{"a", 0, 1, []itn_json_types.MinaPrivateKey{"k0", "k1"}},
+		{"c", 1, 2, []itn_json_types.MinaPrivateKey{"k2", "k3", "k4", "k5"}},
+	}, batches)
+	require.Equal(t, []NodeAddress{"a", "c"}, outputs)
+}
+
+func TestScheduleOnNodesConcurrent(t *testing.T) {
+	batches, outputs, err := runScheduleOnNodes(t, SchedulingConfig{Concurrency: 3}, []NodeAddress{"a", "b", "c"}, "b")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []testBatch{
+		{"a", 0, 1, []itn_json_types.MinaPrivateKey{"k0", "k1"}},
+		{"c", 2, 1, []itn_json_types.MinaPrivateKey{"k4", "k5"}},
+		// Second batch with the share of the failed node
+		{"a", 3, 1, []itn_json_types.MinaPrivateKey{"k2", "k3"}},
+	}, batches)
+	require.Equal(t, []NodeAddress{"a", "c", "a"}, outputs)
+
+	// Index of a failed batch isn't reused while a batch with greater index is scheduled
+	batches, _, err = runScheduleOnNodes(t, SchedulingConfig{Concurrency: 2}, []NodeAddress{"a", "b", "c", "d"}, "a")
+	require.NoError(t, err)
+	ixs := map[int]NodeAddress{}
+	for _, b := range batches {
+		require.NotContains(t, ixs, b.BatchIx)
+		ixs[b.BatchIx] = b.Address
+	}
+	require.Equal(t, map[int]NodeAddress{1: "b", 2: "c", 3: "d"}, ixs)
+
+	_, outputs, err = runScheduleOnNodes(t, SchedulingConfig{Concurrency: 3}, []NodeAddress{"b"}, "b")
+	require.Error(t, err)
+	require.Empty(t, outputs)
+}
+
+func TestScheduleOnNodesBounded(t *testing.T) {
+	config := Config{Ctx: context.Background(), Log: logging.Logger("test"), Scheduling: SchedulingConfig{Concurrency: 2, RequestTimeoutSec: 10}}
+	var inFlight, maxInFlight atomic.Int32
+	schedule := func(config Config, addr NodeAddress, batchIx int, tps float64, feePayers []itn_json_types.MinaPrivateKey) (string, error) {
+		if _, hasDeadline := config.Ctx.Deadline(); !hasDeadline {
+			return "", errors.New("no request timeout")
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return "handle", nil
+	}
+	nodes := []NodeAddress{"a", "b", "c", "d", "e"}
+	outputs := 0
+	err := scheduleOnNodes(config, "test", 5, 1, nodes, nil, schedule, func(NodeAddress, string) { outputs++ })
+	require.NoError(t, err)
+	require.Equal(t, 5, outputs)
+	require.Equal(t, int32(2), maxInFlight.Load())
+}
@@ -0,0 +1,392 @@
+package itn_orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type ScriptIssue struct {
+	Line    int    `json:"line"`
+	Step    int    `json:"step"`
+	Action  string `json:"action"`
+	Message string `json:"message"`
+}
+
+func (issue ScriptIssue) String() string {
+	return fmt.Sprintf("line %d, step %d (%s): %s", issue.Line, issue.Step, issue.Action, issue.Message)
+}
+
+type scriptCommand struct {
+	line int
+	cmd  Command
+}
+
+type checkScope struct {
+	// Schemas of steps checked so far, nil for actions without a declared schema
+	schemas []*ActionSchema
+	parent  *checkScope
+	// Step of the parent scope performing the nested commands
+	step int
+}
+
+type scriptChecker struct {
+	scope  *checkScope
+	files  map[string]map[int]map[string]OutputCacheEntry
+	issues []ScriptIssue
+}
+
+// CheckScript statically checks a script without executing it: action names, parameter
+// names and kinds, and references to outputs of previous steps and output files.
+// Error is returned only when the script can not be decoded.
+func CheckScript(r io.Reader) ([]ScriptIssue, error) {
+	cmds, err := readScript(r)
+	if err != nil {
+		return nil, err
+	}
+	c := scriptChecker{scope: &checkScope{}, files: map[string]map[int]map[string]OutputCacheEntry{}}
+	for _, sc := range cmds {
+		c.checkStep(sc)
+	}
+	return c.issues, nil
+}
+
+func readScript(r io.Reader) ([]scriptCommand, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	cmds := []scriptCommand{}
+	for {
+		start := int(decoder.InputOffset())
+		for start < len(data) && strings.ContainsRune(" \t\r\n", rune(data[start])) {
+			start++
+		}
+		line := bytes.Count(data[:start], []byte{'\n'}) + 1
+		var commandOrComment CommandOrComment
+		if err := decoder.Decode(&commandOrComment); err != nil {
+			if err == io.EOF {
+				return cmds, nil
+			}
+			return nil, fmt.Errorf("error decoding command on line %d: %v", line, err)
+		}
+		if commandOrComment.command != nil {
+			cmds = append(cmds, scriptCommand{line: line, cmd: *commandOrComment.command})
+		}
+	}
+}
+
+func (c *scriptChecker) report(sc scriptCommand, format string, args ...any) {
+	c.issues = append(c.issues, ScriptIssue{
+		Line:    sc.line,
+		Step:    len(c.scope.schemas),
+		Action:  sc.cmd.Action,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *scriptChecker) checkStep(sc scriptCommand) {
+	var schema *ActionSchema
+	var action any = actions[sc.cmd.Action]
+	composite := compositeActions[sc.cmd.Action]
+	if composite != nil {
+		action = composite
+	} else if actions[sc.cmd.Action] == nil {
+		c.report(sc, "unknown action")
+	}
+	if declared, ok := action.(interface{ Schema(RawParams) ActionSchema }); ok {
+		s := declared.Schema(sc.cmd.Params)
+		schema = &s
+	}
+	var paramKinds map[string]ValueKind
+	if schema != nil && schema.Params != nil {
+		paramKinds = map[string]ValueKind{}
+		collectParamKinds(reflect.TypeOf(schema.Params), paramKinds)
+	}
+	names := make([]string, 0, len(sc.cmd.Params))
+	for name := range sc.cmd.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var deferred []string
+	if composite != nil {
+		deferred = composite.DeferredParams()
+	}
+	for _, name := range names {
+		raw := sc.cmd.Params[name]
+		if name == retryParam {
+			// Retry policy override is accepted by every action
+			if kind := jsonKind(raw); kind != ObjectKind && kind != AnyKind {
+				c.report(sc, "parameter %s is expected to be %s, got %s", name, ObjectKind, kind)
+			}
+			continue
+		}
+		expected := AnyKind
+		if paramKinds != nil {
+			kind, has := paramKinds[strings.ToLower(name)]
+			if !has {
+				c.report(sc, "unknown parameter %s", name)
+				continue
+			}
+			expected = kind
+		}
+		var actual ValueKind
+		if slices.Contains(deferred, name) {
+			// References in deferred params are resolved by the action itself,
+			// those resolved within nested commands are checked along with them
+			actual = jsonKind(raw)
+			if !slices.Contains(bodyScoped(composite), name) {
+				c.checkReferences(sc, name, raw)
+			}
+		} else {
+			actual = c.paramKind(sc, name, raw)
+		}
+		if actual != AnyKind && expected != AnyKind && actual != expected {
+			c.report(sc, "parameter %s is expected to be %s, got %s", name, expected, actual)
+		}
+	}
+	if composite != nil {
+		c.checkNested(sc, composite)
+	}
+	c.scope.schemas = append(c.scope.schemas, schema)
+}
+
+// checkNested checks every list of nested commands in its own scope
+func (c *scriptChecker) checkNested(sc scriptCommand, composite CompositeAction) {
+	lists, err := composite.Nested(sc.cmd.Params)
+	if err != nil {
+		c.report(sc, "%v", err)
+		return
+	}
+	parent := c.scope
+	for _, cmds := range lists {
+		c.scope = &checkScope{parent: parent, step: len(parent.schemas)}
+		for _, cmd := range cmds {
+			c.checkStep(scriptCommand{line: sc.line, cmd: cmd})
+		}
+		for _, name := range bodyScoped(composite) {
+			if raw, has := sc.cmd.Params[name]; has {
+				c.checkReferences(sc, name, raw)
+			}
+		}
+	}
+	c.scope = parent
+}
+
+// bodyScopedParams is implemented by composite actions resolving some of their
+// deferred params as params of a step following their nested commands
+type bodyScopedParams interface {
+	bodyScopedParams() []string
+}
+
+func bodyScoped(composite CompositeAction) []string {
+	if b, ok := composite.(bodyScopedParams); ok {
+		return b.bodyScopedParams()
+	}
+	return nil
+}
+
+// checkReferences checks references found within a deferred param without resolving them
+func (c *scriptChecker) checkReferences(sc scriptCommand, name string, raw json.RawMessage) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		if _, isRef := obj["type"]; isRef {
+			c.paramKind(sc, name, raw)
+			return
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			c.checkReferences(sc, name+"."+key, obj[key])
+		}
+		return
+	}
+	var arr []json.RawMessage
+	if err := json.Unmarshal(raw, &arr); err == nil {
+		for i, item := range arr {
+			c.checkReferences(sc, fmt.Sprintf("%s[%d]", name, i), item)
+		}
+	}
+}
+
+// paramKind returns kind of a parameter value, resolving
+// its reference when the parameter is defined as such
+func (c *scriptChecker) paramKind(sc scriptCommand, name string, raw json.RawMessage) ValueKind {
+	if bytes.Equal(raw, nullJson) {
+		return AnyKind
+	}
+	var val ComplexValue
+	if err := json.Unmarshal(raw, &val); err != nil {
+		return jsonKind(raw)
+	}
+	step := len(c.scope.schemas)
+	if val.Type != "output" {
+		c.report(sc, "parameter %s: unknown type %q", name, val.Type)
+		return AnyKind
+	}
+	if val.File != "" {
+		if val.Step < 0 {
+			c.report(sc, "parameter %s: use of negative step with file is prohibited", name)
+			return AnyKind
+		}
+		return c.fileOutputKind(sc, name, val)
+	}
+	target := val.Step
+	scope := c.scope
+	if target < 0 {
+		target += step
+		for target < 0 && scope.parent != nil {
+			target += scope.step
+			scope = scope.parent
+		}
+	} else {
+		for scope.parent != nil {
+			scope = scope.parent
+		}
+	}
+	if target < 0 || target >= len(scope.schemas) {
+		c.report(sc, "parameter %s refers to step %d which isn't performed before step %d", name, val.Step, step)
+		return AnyKind
+	}
+	schema := scope.schemas[target]
+	if schema == nil {
+		return AnyKind
+	}
+	for _, spec := range schema.Outputs {
+		if spec.Name == val.Name || (spec.Indexed && isIndexedName(val.Name, spec.Name)) {
+			if spec.Multi {
+				return ArrayKind
+			}
+			return spec.Kind
+		}
+	}
+	if val.OnEmpty == nil {
+		c.report(sc, "parameter %s refers to output %s never produced by step %d", name, val.Name, target)
+	}
+	return AnyKind
+}
+
+func (c *scriptChecker) fileOutputKind(sc scriptCommand, name string, val ComplexValue) ValueKind {
+	if _, has := c.files[val.File]; !has {
+		fileEntry, err := loadOutputFile(val.File)
+		if err != nil {
+			c.report(sc, "parameter %s: %v", name, err)
+			fileEntry = nil
+		}
+		c.files[val.File] = fileEntry
+	}
+	fileEntry := c.files[val.File]
+	if fileEntry == nil {
+		return AnyKind
+	}
+	entry, has := fileEntry[val.Step][val.Name]
+	if !has {
+		if val.OnEmpty == nil {
+			c.report(sc, "parameter %s refers to output %s (step %d) missing in file %s", name, val.Name, val.Step, val.File)
+		}
+		return AnyKind
+	}
+	if entry.Multi {
+		return ArrayKind
+	}
+	return jsonKind(entry.Values[0])
+}
+
+func isIndexedName(name, prefix string) bool {
+	suffix, found := strings.CutPrefix(name, prefix)
+	if !found || suffix == "" {
+		return false
+	}
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonKind(raw json.RawMessage) ValueKind {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return AnyKind
+	}
+	switch raw[0] {
+	case '"':
+		return StringKind
+	case '[':
+		return ArrayKind
+	case '{':
+		return ObjectKind
+	case 't', 'f':
+		return BoolKind
+	case 'n':
+		return AnyKind
+	default:
+		return NumberKind
+	}
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+func typeKind(t reflect.Type) ValueKind {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == rawMessageType {
+		return AnyKind
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return StringKind
+	case reflect.Bool:
+		return BoolKind
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return NumberKind
+	case reflect.Slice, reflect.Array:
+		return ArrayKind
+	case reflect.Struct, reflect.Map:
+		return ObjectKind
+	default:
+		return AnyKind
+	}
+}
+
+// collectParamKinds maps lower-cased JSON field names of a params struct to their kinds
+func collectParamKinds(t reflect.Type, kinds map[string]ValueKind) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			collectParamKinds(f.Type, kinds)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		kinds[strings.ToLower(name)] = typeKind(f.Type)
+	}
+}
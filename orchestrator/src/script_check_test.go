@@ -0,0 +1,109 @@
+package itn_orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckGeneratedScript(t *testing.T) {
+	params := someParams()
+	params.Rounds = 4
+	params.StopsPerRound = 2
+	params.ZkappSoftLimit = 10
+	params.WaitReadyTimeoutMin = 10
+	params.Partitions = 3
+	params.PartitionMin = 5
+	params.FaultRatios = map[FaultKind]float64{LatencyFault: 0.2, PauseFault: 0.1}
+	params.FaultDurationMin = 5
+	requireValidGeneratedScript(t, params)
+}
+
+func requireValidGeneratedScript(t *testing.T, params GenParams) {
+	var script bytes.Buffer
+	encoder := json.NewEncoder(&script)
+	writeComment := func(comment string) {
+		require.NoError(t, encoder.Encode(comment))
+	}
+	writeCommand := func(cmd GeneratedCommand) {
+		if cmd.Comment() != "" {
+			writeComment(cmd.Comment())
+		}
+		require.NoError(t, encoder.Encode(cmd))
+	}
+	Encode(&params, writeCommand, writeComment)
+	issues, err := CheckScript(&script)
+	require.NoError(t, err)
+	require.Empty(t, issues)
+}
+
+func TestCheckScriptIssues(t *testing.T) {
+	script := strings.Join([]string{
+		`"comment"`,
+		`{"action":"discovery","params":{"limit":2}}`,
+		`{"action":"wait","params":{"min":1}}`,
+		`{"action":"sample","params":{"group":{"type":"output","step":-2,"name":"participant"},"ratios":[0.5]}}`,
+		`{"action":"stop-daemon","params":{"nodes":{"type":"output","step":-1,"name":"group2"}}}`,
+		`{"action":"stop-daemon","params":{"nodes":{"type":"output","step":-3,"name":"participant"}}}`,
+		`{"action":"wait","params":{"min":{"type":"output","step":2,"name":"group1"}}}`,
+		`{"action":"restart","params":{"nodes":{"type":"output","step":-1,"name":"x","onEmpty":[]},"cleen":true}}`,
+		`{"action":"discover","params":{}}`,
+	}, "\n")
+	issues, err := CheckScript(strings.NewReader(script))
+	require.NoError(t, err)
+	lines := make([]int, len(issues))
+	for i, issue := range issues {
+		lines[i] = issue.Line
+	}
+	require.Equal(t, []int{5, 6, 7, 8, 9}, lines, "%v", issues)
+	require.Contains(t, issues[0].Message, "group2")
+	require.Contains(t, issues[1].Message, "step 1")
+	require.Contains(t, issues[2].Message, "expected to be number")
+	require.Contains(t, issues[3].Message, "unknown parameter cleen")
+	require.Contains(t, issues[4].Message, "unknown action")
+}
+
+func TestCheckParallelScript(t *testing.T) {
+	script := strings.Join([]string{
+		`{"action":"discovery","params":{"limit":2}}`,
+		`{"action":"parallel","params":{"branches":[` +
+			`[{"action":"stop-daemon","params":{"nodes":{"type":"output","step":-1,"name":"participant"}}}],` +
+			`[{"action":"discovery","params":{}},{"action":"stop-daemon","params":{"nodes":{"type":"output","step":-1,"name":"participant"}}},` +
+			`{"action":"wait","params":{"min":{"type":"output","step":-1,"name":"participant"}}}]]}}`,
+		`{"action":"stop-daemon","params":{"nodes":{"type":"output","step":-1,"name":"participant"}}}`,
+	}, "\n")
+	issues, err := CheckScript(strings.NewReader(script))
+	require.NoError(t, err)
+	require.Len(t, issues, 2, "%v", issues)
+	require.Equal(t, 2, issues[0].Line)
+	require.Contains(t, issues[0].Message, "never produced by step 1")
+	require.Equal(t, 3, issues[1].Line)
+	require.Contains(t, issues[1].Message, "never produced by step 1")
+}
+
+func TestCheckDeferredReferences(t *testing.T) {
+	script := strings.Join([]string{
+		`{"action":"join","params":{"group1":["a","b"]}}`,
+		`{"action":"repeat","params":{"count":3,"until":{"value":{"type":"output","step":-1,"name":"group"},"op":"ge","than":2},"body":[` +
+			`{"action":"join","params":{"group1":{"type":"output","step":-1,"name":"group"}}}]}}`,
+		`{"action":"if","params":{"condition":{"value":{"type":"output","step":0,"name":"group"},"op":"lt","than":2},` +
+			`"then":[{"action":"join","params":{"group1":["c"]}}]}}`,
+		`{"action":"repeat","params":{"count":3,"until":{"value":{"type":"output","step":-2,"name":"group"},"op":"ge","than":2},"body":[` +
+			`{"action":"join","params":{"group1":["c"]}}]}}`,
+		`{"action":"if","params":{"condition":{"value":{"type":"output","step":0,"name":"groups"},"op":"lt","than":{"type":"output","step":7,"name":"group"}},` +
+			`"then":[{"action":"join","params":{"group1":["c"]}}]}}`,
+	}, "\n")
+	issues, err := CheckScript(strings.NewReader(script))
+	require.NoError(t, err)
+	lines := make([]int, len(issues))
+	for i, issue := range issues {
+		lines[i] = issue.Line
+	}
+	require.Equal(t, []int{4, 5, 5}, lines, "%v", issues)
+	require.Contains(t, issues[0].Message, "until.value refers to output group never produced by step 2")
+	require.Contains(t, issues[1].Message, "condition.than refers to step 7")
+	require.Contains(t, issues[2].Message, "condition.value refers to output groups never produced by step 0")
+}
@@ -28,6 +28,16 @@ type GeneratorInputData struct {
 	StopsPerRound          *int                          `json:"stops_per_round,omitempty"`
 	Gap                    *int                          `json:"gap,omitempty"`
 	ZkappSoftLimit         *int                          `json:"zkapp_soft_limit,omitempty"`
+	WaitReadyTimeoutMin    *int                          `json:"wait_ready_timeout_min,omitempty"`
+	Partitions             *int                          `json:"partitions,omitempty"`
+	PartitionMin           *int                          `json:"partition_min,omitempty"`
+	FaultRatios            map[lib.FaultKind]float64     `json:"fault_ratios,omitempty"`
+	FaultDurationMin       *int                          `json:"fault_duration_min,omitempty"`
+	LoadProfile            *lib.LoadProfile              `json:"load_profile,omitempty"`
+	LoadSteps              *int                          `json:"load_steps,omitempty"`
+	LoadPeriodMin          *int                          `json:"load_period_min,omitempty"`
+	LoadSpikeMin           *int                          `json:"load_spike_min,omitempty"`
+	RoundTemplates         []lib.RoundTemplate           `json:"round_templates,omitempty"`
 	Mode                   *string                       `json:"mode,omitempty"`
 	FundKeyPrefix          *string                       `json:"fund_key_prefix,omitempty"`
 	PasswordEnv            *string                       `json:"password_env,omitempty"`
@@ -44,6 +54,7 @@ type GeneratorInputData struct {
 	MaxBalanceChange       *uint64                       `json:"max_balance_change,omitempty"`
 	MinBalanceChange       *uint64                       `json:"min_balance_change,omitempty"`
 	PaymentAmount          *uint64                       `json:"payment_amount,omitempty"`
+	Seed                   *int64                        `json:"seed,omitempty"`
 	Privkeys               []string                      `json:"priv_keys,omitempty"`
 	Fees                   struct {
 		Deployment *uint64 `json:"deployment,omitempty"`
@@ -84,6 +95,18 @@ func (inputData *GeneratorInputData) ApplyWithDefaults(p *lib.GenParams) {
 	lib.SetOrDefault(inputData.StopsPerRound, &p.StopsPerRound, defaults.StopsPerRound)
 	lib.SetOrDefault(inputData.Gap, &p.Gap, defaults.Gap)
 	lib.SetOrDefault(inputData.ZkappSoftLimit, &p.ZkappSoftLimit, defaults.ZkappSoftLimit)
+	lib.SetOrDefault(inputData.WaitReadyTimeoutMin, &p.WaitReadyTimeoutMin, defaults.WaitReadyTimeoutMin)
+	lib.SetOrDefault(inputData.Partitions, &p.Partitions, defaults.Partitions)
+	lib.SetOrDefault(inputData.PartitionMin, &p.PartitionMin, defaults.PartitionMin)
+	if inputData.FaultRatios != nil {
+		p.FaultRatios = inputData.FaultRatios
+	}
+	lib.SetOrDefault(inputData.FaultDurationMin, &p.FaultDurationMin, defaults.FaultDurationMin)
+	lib.SetOrDefault(inputData.LoadProfile, &p.LoadProfile, defaults.LoadProfile)
+	lib.SetOrDefault(inputData.LoadSteps, &p.LoadSteps, defaults.LoadSteps)
+	lib.SetOrDefault(inputData.LoadPeriodMin, &p.LoadPeriodMin, defaults.LoadPeriodMin)
+	lib.SetOrDefault(inputData.LoadSpikeMin, &p.LoadSpikeMin, defaults.LoadSpikeMin)
+	p.RoundTemplates = inputData.RoundTemplates
 	lib.SetOrDefault(inputData.FundKeyPrefix, &p.FundKeyPrefix, defaults.FundKeyPrefix)
 	lib.SetOrDefault(inputData.PasswordEnv, &p.PasswordEnv, defaults.PasswordEnv)
 	lib.SetOrDefault(inputData.PaymentReceiver, &p.PaymentReceiver, defaults.PaymentReceiver)
@@ -105,6 +128,7 @@ func (inputData *GeneratorInputData) ApplyWithDefaults(p *lib.GenParams) {
 	lib.SetOrDefault(inputData.MaxBalanceChange, &p.MaxBalanceChange, defaults.MaxBalanceChange)
 	lib.SetOrDefault(inputData.MinBalanceChange, &p.MinBalanceChange, defaults.MinBalanceChange)
 	lib.SetOrDefault(inputData.PaymentAmount, &p.PaymentAmount, defaults.PaymentAmount)
+	lib.SetOrDefault(inputData.Seed, &p.Seed, defaults.Seed)
 
 	lib.SetOrDefault(inputData.Fees.Deployment, &p.DeploymentFee, 1e9)
 	lib.SetOrDefault(inputData.Fees.Fund, &p.FundFee, 1e9)
@@ -1,12 +1,45 @@
 package inputs
 
-import lib "itn_orchestrator"
+import (
+	"encoding/json"
+	"fmt"
+
+	lib "itn_orchestrator"
+)
 
 type Input struct {
-	ExperimentSetup    *GeneratorInputData      `json:"experiment_setup"`
+	ExperimentSetup *GeneratorInputData `json:"experiment_setup"`
+	// Experiment file (as accepted by generator's -experiment option),
+	// either a JSON object or a string with YAML
+	Experiment         json.RawMessage          `json:"experiment,omitempty"`
 	OrchestratorConfig *OrchestratorInputConfig `json:"orchestrator_config"`
 }
 
+// GenParams returns generator params defined by the experiment file or by the experiment setup
+func (input *Input) GenParams() (lib.GenParams, error) {
+	if len(input.Experiment) == 0 {
+		if input.ExperimentSetup == nil {
+			return lib.GenParams{}, fmt.Errorf("Experiment setup is required")
+		}
+		var p lib.GenParams
+		input.ExperimentSetup.ApplyWithDefaults(&p)
+		return p, nil
+	}
+	if input.ExperimentSetup != nil {
+		return lib.GenParams{}, fmt.Errorf("Only one of experiment and experiment setup should be provided")
+	}
+	data := []byte(input.Experiment)
+	var text string
+	if err := json.Unmarshal(input.Experiment, &text); err == nil {
+		data = []byte(text)
+	}
+	f, err := lib.ParseExperimentFile(data)
+	if err != nil {
+		return lib.GenParams{}, err
+	}
+	return f.GenParams()
+}
+
 func (input *Input) GetOrchestratorConfig(defaults *lib.OrchestratorConfig) lib.OrchestratorConfig {
 	if input.OrchestratorConfig == nil {
 		return *defaults
@@ -35,4 +35,8 @@ func (SetZkappSoftLimitAction) Run(config Config, rawParams json.RawMessage, out
 
 func (SetZkappSoftLimitAction) Name() string { return "set-zkapp-soft-limit" }
 
-var _ Action = SetZkappSoftLimitAction{}
+func (SetZkappSoftLimitAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: SetZkappSoftLimitParams{}, Outputs: []OutputSpec{{Name: "participant", Kind: StringKind, Multi: true}}}
+}
+
+var _ DeclaredAction = SetZkappSoftLimitAction{}
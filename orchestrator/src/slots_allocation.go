@@ -300,4 +300,20 @@ func (AllocateSlotsAction) Run(config Config, rawParams json.RawMessage, output
 
 func (AllocateSlotsAction) Name() string { return "allocate-slots" }
 
-var _ Action = AllocateSlotsAction{}
+func (AllocateSlotsAction) Schema(params RawParams) ActionSchema {
+	outputs := []OutputSpec{
+		{Name: "nextEmptySlot", Kind: NumberKind},
+		{Name: "lastSlot", Kind: NumberKind},
+	}
+	var groups []int
+	if err := json.Unmarshal(params["groups"], &groups); err == nil {
+		for i := range groups {
+			outputs = append(outputs, OutputSpec{Name: "group" + strconv.Itoa(i), Kind: ArrayKind})
+		}
+	} else {
+		outputs = append(outputs, OutputSpec{Name: "group", Kind: ArrayKind, Indexed: true})
+	}
+	return ActionSchema{Params: AllocateSlotsParams{}, Outputs: outputs}
+}
+
+var _ DeclaredAction = AllocateSlotsAction{}
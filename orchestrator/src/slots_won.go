@@ -45,11 +45,17 @@ func (SlotsWonAction) Run(config Config, rawParams json.RawMessage, output Outpu
 
 func (SlotsWonAction) Name() string { return "slots-won" }
 
-var _ Action = SlotsWonAction{}
+func (SlotsWonAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: SlotsWonParams{}, Outputs: []OutputSpec{{Name: "slotsWon", Kind: ObjectKind, Multi: true}}}
+}
+
+var _ DeclaredAction = SlotsWonAction{}
 
 type SlotsCoveredCheckParams struct {
 	Threshold float64          `json:"threshold"`
 	SlotsWon  []SlotsWonOutput `json:"slotsWon"`
+	// Report insufficient coverage with the output instead of failing
+	NoFail bool `json:"noFail,omitempty"`
 }
 
 func SlotsCoveredCheck(config Config, params SlotsCoveredCheckParams) error {
@@ -84,9 +90,20 @@ func (SlotsCoveredCheckAction) Run(config Config, rawParams json.RawMessage, out
 	if err := json.Unmarshal(rawParams, &params); err != nil {
 		return err
 	}
-	return SlotsCoveredCheck(config, params)
+	err := SlotsCoveredCheck(config, params)
+	if err != nil {
+		if !params.NoFail {
+			return err
+		}
+		config.Log.Warn(err)
+	}
+	return output("covered", err == nil, false, false)
 }
 
 func (SlotsCoveredCheckAction) Name() string { return "slots-covered-check" }
 
-var _ Action = SlotsCoveredCheckAction{}
+func (SlotsCoveredCheckAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: SlotsCoveredCheckParams{}, Outputs: []OutputSpec{{Name: "covered", Kind: BoolKind}}}
+}
+
+var _ DeclaredAction = SlotsCoveredCheckAction{}
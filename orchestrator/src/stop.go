@@ -14,6 +14,8 @@ func StopTransactions(config Config, params StopParams) error {
 	for _, receipt := range params.Receipts {
 		resp, err := StopTransactionsGql(config, receipt.Address, receipt.Handle)
 		if err == nil {
+			config.Receipts.Remove(receipt)
+			observeStopped(receipt.Address, receipt.Kind)
 			config.Log.Infof("stopped scheduled transactions at %s on %s: %s", receipt.Handle, receipt.Address, resp)
 		} else {
 			errs = append(errs, err)
@@ -37,7 +39,11 @@ func (StopAction) Run(config Config, rawParams json.RawMessage, output OutputF)
 
 func (StopAction) Name() string { return "stop" }
 
-var _ Action = StopAction{}
+func (StopAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: StopParams{}, Outputs: nil}
+}
+
+var _ DeclaredAction = StopAction{}
 
 type StopDaemonParams struct {
 	Nodes []NodeAddress `json:"nodes"`
@@ -68,4 +74,8 @@ func (StopDaemonAction) Run(config Config, rawParams json.RawMessage, output Out
 
 func (StopDaemonAction) Name() string { return "stop-daemon" }
 
-var _ Action = StopDaemonAction{}
+func (StopDaemonAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: StopDaemonParams{}, Outputs: nil}
+}
+
+var _ DeclaredAction = StopDaemonAction{}
@@ -0,0 +1,125 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Lookback window of submissions used when it isn't configured
+const defaultSubmissionsLookback = 15 * time.Minute
+
+// SubmissionSource provides uptime submissions used to discover participants
+type SubmissionSource interface {
+	// IterateSubmissions calls the handler for submissions made after the given time
+	// (sources that don't keep time of submissions ignore it)
+	IterateSubmissions(config Config, since time.Time, handler func(MiniMetaToBeSaved)) error
+}
+
+// decodeSubmission decodes a submission in the format it's stored by the uptime backend
+func decodeSubmission(r io.Reader) (MiniMetaToBeSaved, error) {
+	var meta MetaToBeSaved
+	if err := json.NewDecoder(r).Decode(&meta); err != nil {
+		return MiniMetaToBeSaved{}, err
+	}
+	if colonIx := strings.IndexRune(meta.RemoteAddr, ':'); colonIx >= 0 {
+		meta.RemoteAddr = meta.RemoteAddr[:colonIx]
+	}
+	return meta.MiniMetaToBeSaved, nil
+}
+
+// OnlineSource retrieves a JSON array of submissions from the URL
+type OnlineSource struct {
+	URL string
+}
+
+func (s OnlineSource) IterateSubmissions(config Config, _ time.Time, handler func(MiniMetaToBeSaved)) error {
+	submissions, err := retryGetURL(config.Ctx, config.Log, s.URL, config.Retry.submissions())
+	if err != nil {
+		return err
+	}
+	for _, meta := range submissions {
+		handler(meta)
+	}
+	return nil
+}
+
+// DirectorySource reads submissions from a local directory with the same
+// layout as the uptime bucket: submissions/<date>/<time>-<submitter>.json
+type DirectorySource struct {
+	Dir string
+}
+
+func (s DirectorySource) IterateSubmissions(config Config, since time.Time, handler func(MiniMetaToBeSaved)) error {
+	root := filepath.Join(s.Dir, "submissions")
+	startAfter := prefixByTime(since)
+	dates, err := os.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("failed to list submissions directory %s: %v", root, err)
+	}
+	sinceDate := since.UTC().Format(time.DateOnly)
+	// Entries returned by os.ReadDir are sorted by name, hence dates and times are in order
+	for _, date := range dates {
+		if !date.IsDir() || date.Name() < sinceDate {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(root, date.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to list submissions directory %s: %v", date.Name(), err)
+		}
+		for _, file := range files {
+			key := path.Join("submissions", date.Name(), file.Name())
+			if file.IsDir() || key <= startAfter {
+				continue
+			}
+			if err := config.Ctx.Err(); err != nil {
+				return err
+			}
+			meta, err := readSubmissionFile(filepath.Join(root, date.Name(), file.Name()))
+			if err != nil {
+				config.Log.Warnf("Error reading submission %s: %v", key, err)
+				continue
+			}
+			handler(meta)
+		}
+	}
+	return nil
+}
+
+func readSubmissionFile(filename string) (MiniMetaToBeSaved, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return MiniMetaToBeSaved{}, err
+	}
+	defer file.Close()
+	return decodeSubmission(file)
+}
+
+// InventorySource reads a static JSON array of submissions from a file
+type InventorySource struct {
+	File string
+}
+
+func (s InventorySource) IterateSubmissions(config Config, _ time.Time, handler func(MiniMetaToBeSaved)) error {
+	data, err := os.ReadFile(s.File)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory file %s: %v", s.File, err)
+	}
+	var submissions []MiniMetaToBeSaved
+	if err := json.Unmarshal(data, &submissions); err != nil {
+		return fmt.Errorf("failed to decode inventory file %s: %v", s.File, err)
+	}
+	for _, meta := range submissions {
+		handler(meta)
+	}
+	return nil
+}
+
+var _ SubmissionSource = OnlineSource{}
+var _ SubmissionSource = DirectorySource{}
+var _ SubmissionSource = InventorySource{}
@@ -0,0 +1,53 @@
+package itn_orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectorySource(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 5, 1, 0, 5, 0, 0, time.UTC)
+	write := func(at time.Time, submitter string) {
+		key := prefixByTime(at) + "-" + submitter + ".json"
+		filename := filepath.Join(dir, filepath.FromSlash(key))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		content := `{"remote_addr":"10.0.0.1:3086","graphql_control_port":8301,"submitter":"` + submitter + `","created_at":"x"}`
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+	}
+	write(now.Add(-20*time.Minute), "old")
+	write(now.Add(-10*time.Minute), "previous-day")
+	write(now.Add(-time.Minute), "recent")
+
+	config := Config{Ctx: context.Background(), Log: logging.Logger("test")}
+	var submitters []string
+	err := DirectorySource{Dir: dir}.IterateSubmissions(config, now.Add(-15*time.Minute), func(meta MiniMetaToBeSaved) {
+		require.Equal(t, "10.0.0.1", meta.RemoteAddr)
+		require.Equal(t, uint16(8301), meta.GraphqlControlPort)
+		submitters = append(submitters, meta.Submitter)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"previous-day", "recent"}, submitters)
+}
+
+func TestInventorySource(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "inventory.json")
+	content := `[{"remote_addr":"10.0.0.1","graphql_control_port":8301,"submitter":"a"},{"remote_addr":"10.0.0.2","submitter":"b"}]`
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+	config := Config{Ctx: context.Background(), Log: logging.Logger("test")}
+	var metas []MiniMetaToBeSaved
+	err := InventorySource{File: filename}.IterateSubmissions(config, time.Now(), func(meta MiniMetaToBeSaved) {
+		metas = append(metas, meta)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []MiniMetaToBeSaved{
+		{RemoteAddr: "10.0.0.1", GraphqlControlPort: 8301, Submitter: "a"},
+		{RemoteAddr: "10.0.0.2", Submitter: "b"},
+	}, metas)
+}
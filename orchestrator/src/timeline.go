@@ -0,0 +1,335 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// Lanes of the timeline, in the order of display
+var timelineLanes = []string{"funding", "rounds", "rotation", "partitions", "payments", "zkapps", "stops"}
+
+// TimelineEvent is an interval (or an instant) of the experiment's timeline
+type TimelineEvent struct {
+	Lane     string `json:"lane"`
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	// Start of the event, seconds after start of the experiment
+	StartSec int `json:"startSec"`
+	// Duration of the event, zero for instant events
+	DurationSec int            `json:"durationSec"`
+	Args        map[string]any `json:"args,omitempty"`
+}
+
+// TimelineClock places the timeline on wall-clock and slot axes
+type TimelineClock struct {
+	// Start of the experiment (after keys are funded)
+	Start            time.Time
+	GenesisTimestamp time.Time
+	SlotDurationMs   int
+}
+
+// At returns wall-clock time of the offset from start of the experiment
+func (c TimelineClock) At(sec int) time.Time {
+	return c.Start.Add(time.Duration(sec) * time.Second)
+}
+
+// Slot returns global slot at the offset from start of the experiment,
+// false if genesis timestamp or slot duration is unknown
+func (c TimelineClock) Slot(sec int) (int, bool) {
+	if c.SlotDurationMs <= 0 || c.GenesisTimestamp.IsZero() {
+		return 0, false
+	}
+	ms := c.At(sec).Sub(c.GenesisTimestamp).Milliseconds()
+	slot := ms / int64(c.SlotDurationMs)
+	if ms < 0 && ms%int64(c.SlotDurationMs) != 0 {
+		slot--
+	}
+	return int(slot), true
+}
+
+func formatRatios(disruptions []Disruption) string {
+	parts := make([]string, len(disruptions))
+	for i, d := range disruptions {
+		parts[i] = fmt.Sprintf("%s %.1f%%", d.Kind, d.Ratio*100)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Timeline returns events of the plan: funding, rounds with pauses, rotations,
+// partitioning, load windows of payments and zkapps, and stops.
+// Funding is performed before start of the experiment, its events are placed at the start.
+func (plan *ExperimentPlan) Timeline() []TimelineEvent {
+	res := []TimelineEvent{}
+	if f := plan.FundKeysCommand; f != nil {
+		res = append(res, TimelineEvent{
+			Lane:     "funding",
+			Category: "funding",
+			Name:     fmt.Sprintf("Generating %d funding keys", f.Num),
+			Args:     map[string]any{"keys": f.Num, "amount": f.Amount, "fee": f.Fee},
+		})
+	}
+	if len(plan.FundCommands) > 0 {
+		keys, amount := 0, uint64(0)
+		for _, f := range plan.FundCommands {
+			keys += f.Num
+			amount += f.Amount
+		}
+		res = append(res, TimelineEvent{
+			Lane:     "funding",
+			Category: "funding",
+			Name:     fmt.Sprintf("Funding %d keys of rounds", keys),
+			Args:     map[string]any{"commands": len(plan.FundCommands), "keys": keys, "amount": amount},
+		})
+	}
+	for _, round := range plan.Rounds {
+		startSec := round.StartMin * 60
+		endSec := startSec + round.DurationMin*60
+		res = append(res, TimelineEvent{
+			Lane:        "rounds",
+			Category:    "round",
+			Name:        fmt.Sprintf("Round %d", round.Round),
+			StartSec:    startSec,
+			DurationSec: round.DurationMin * 60,
+			Args:        map[string]any{"round": round.Round, "senderRatio": round.SenderRatio},
+		})
+		if round.PauseMin > 0 {
+			res = append(res, TimelineEvent{
+				Lane:        "rounds",
+				Category:    "pause",
+				Name:        fmt.Sprintf("Pause after round %d", round.Round),
+				StartSec:    endSec,
+				DurationSec: round.PauseMin * 60,
+			})
+		}
+		if round.LargePauseMin > 0 {
+			res = append(res, TimelineEvent{
+				Lane:        "rounds",
+				Category:    "large-pause",
+				Name:        fmt.Sprintf("Large pause after round %d", round.Round),
+				StartSec:    endSec + round.PauseMin*60,
+				DurationSec: round.LargePauseMin * 60,
+			})
+		}
+		if r := round.Rotation; r != nil {
+			res = append(res, TimelineEvent{
+				Lane:     "rotation",
+				Category: "rotation",
+				Name:     fmt.Sprintf("Rotating %.0f%% of balances of %d keys", r.Ratio*100, len(r.Pubkeys)),
+				StartSec: startSec,
+				Args:     map[string]any{"round": round.Round, "ratio": r.Ratio, "mapping": r.Mapping},
+			})
+		}
+		if round.Partitions > 1 {
+			res = append(res, TimelineEvent{
+				Lane:        "partitions",
+				Category:    "partition",
+				Name:        fmt.Sprintf("%d partitions", round.Partitions),
+				StartSec:    startSec,
+				DurationSec: round.PartitionMin * 60,
+				Args:        map[string]any{"round": round.Round, "partitions": round.Partitions},
+			})
+		}
+		for _, w := range round.Load {
+			for _, load := range []struct {
+				lane string
+				tps  float64
+				txs  int
+			}{{"payments", w.PaymentTps, w.Payments()}, {"zkapps", w.ZkappTps, w.Zkapps()}} {
+				if load.tps <= 0 {
+					continue
+				}
+				name := fmt.Sprintf("%.2f txs/min", load.tps*60)
+				if w.MaxCost && load.lane == "zkapps" {
+					name = "max-cost " + name
+				}
+				res = append(res, TimelineEvent{
+					Lane:        load.lane,
+					Category:    "load",
+					Name:        name,
+					StartSec:    startSec + w.StartSec,
+					DurationSec: w.DurationMin * 60,
+					Args:        map[string]any{"round": round.Round, "tps": load.tps, "txs": load.txs, "maxCost": w.MaxCost},
+				})
+			}
+		}
+		for _, stop := range round.Stops {
+			ratios := map[string]float64{}
+			for _, d := range stop.Disruptions {
+				ratios[string(d.Kind)] = d.Ratio
+			}
+			res = append(res, TimelineEvent{
+				Lane:     "stops",
+				Category: "stop",
+				Name:     formatRatios(stop.Disruptions),
+				StartSec: startSec + stop.AtSec,
+				Args:     map[string]any{"round": round.Round, "ratios": ratios},
+			})
+		}
+	}
+	return res
+}
+
+func laneIndex(lane string) int {
+	for i, l := range timelineLanes {
+		if l == lane {
+			return i
+		}
+	}
+	return len(timelineLanes)
+}
+
+type traceEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat,omitempty"`
+	Ph    string         `json:"ph"`
+	Ts    int64          `json:"ts"`
+	Dur   int64          `json:"dur,omitempty"`
+	Pid   int            `json:"pid"`
+	Tid   int            `json:"tid"`
+	Scope string         `json:"s,omitempty"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+// WriteTraceEvents writes the timeline in Chrome trace-event format, with every lane
+// being a thread and timestamps counted from start of the experiment.
+// Wall-clock times and slots of events are put into their args.
+func WriteTraceEvents(w io.Writer, events []TimelineEvent, clock TimelineClock) error {
+	trace := []traceEvent{}
+	for i, lane := range timelineLanes {
+		trace = append(trace, traceEvent{Name: "thread_name", Ph: "M", Pid: 1, Tid: i, Args: map[string]any{"name": lane}})
+	}
+	for _, e := range events {
+		args := map[string]any{"start": clock.At(e.StartSec).UTC().Format(time.RFC3339)}
+		if slot, ok := clock.Slot(e.StartSec); ok {
+			args["slot"] = slot
+		}
+		if e.DurationSec > 0 {
+			args["end"] = clock.At(e.StartSec + e.DurationSec).UTC().Format(time.RFC3339)
+			if slot, ok := clock.Slot(e.StartSec + e.DurationSec); ok {
+				args["endSlot"] = slot
+			}
+		}
+		for k, v := range e.Args {
+			args[k] = v
+		}
+		te := traceEvent{
+			Name: e.Name,
+			Cat:  e.Category,
+			Ph:   "X",
+			Ts:   int64(e.StartSec) * 1e6,
+			Dur:  int64(e.DurationSec) * 1e6,
+			Pid:  1,
+			Tid:  laneIndex(e.Lane),
+			Args: args,
+		}
+		if e.DurationSec == 0 {
+			te.Ph = "i"
+			te.Scope = "t"
+		}
+		trace = append(trace, te)
+	}
+	otherData := map[string]any{"start": clock.Start.UTC().Format(time.RFC3339)}
+	if !clock.GenesisTimestamp.IsZero() {
+		otherData["genesisTimestamp"] = clock.GenesisTimestamp.UTC().Format(time.RFC3339)
+	}
+	if clock.SlotDurationMs > 0 {
+		otherData["slotDurationMs"] = clock.SlotDurationMs
+	}
+	return json.NewEncoder(w).Encode(map[string]any{
+		"traceEvents":     trace,
+		"displayTimeUnit": "ms",
+		"otherData":       otherData,
+	})
+}
+
+var timelineColors = map[string]string{
+	"funding":     "#8e6c8a",
+	"round":       "#4e79a7",
+	"pause":       "#bab0ab",
+	"large-pause": "#79706e",
+	"rotation":    "#b07aa1",
+	"partition":   "#f28e2b",
+	"load":        "#59a14f",
+	"stop":        "#e15759",
+}
+
+// timelineTickMin picks interval of axis ticks, so that there is at most a dozen of them
+func timelineTickMin(totalMin int) int {
+	for _, tick := range []int{1, 2, 5, 10, 15, 30, 60, 120, 240, 480, 720} {
+		if totalMin/tick <= 12 {
+			return tick
+		}
+	}
+	return 1440
+}
+
+// WriteTimelineHTML writes the timeline as a self-contained HTML page with an SVG Gantt chart,
+// wall-clock axis is drawn at the top and slot axis (if slots are known) at the bottom
+func WriteTimelineHTML(w io.Writer, title string, events []TimelineEvent, clock TimelineClock) error {
+	const (
+		labelWidth = 100
+		chartWidth = 1200
+		laneHeight = 28
+		axisHeight = 30
+	)
+	totalSec := 60
+	for _, e := range events {
+		totalSec = max(totalSec, e.StartSec+e.DurationSec)
+	}
+	x := func(sec int) float64 {
+		return labelWidth + float64(sec)*chartWidth/float64(totalSec)
+	}
+	laneY := func(lane string) int {
+		return axisHeight + laneIndex(lane)*laneHeight
+	}
+	height := 2*axisHeight + len(timelineLanes)*laneHeight
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString("<style>body{font-family:sans-serif;font-size:12px}svg text{font-size:11px}</style>\n</head>\n<body>\n")
+	fmt.Fprintf(&b, "<h3>%s</h3>\n", html.EscapeString(title))
+	fmt.Fprintf(&b, "<p>Start: %s", clock.Start.UTC().Format(time.RFC3339))
+	if _, ok := clock.Slot(0); ok {
+		fmt.Fprintf(&b, ", genesis: %s, slot duration: %d ms", clock.GenesisTimestamp.UTC().Format(time.RFC3339), clock.SlotDurationMs)
+	}
+	b.WriteString("</p>\n")
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", labelWidth+chartWidth+20, height)
+	for _, lane := range timelineLanes {
+		y := laneY(lane)
+		fmt.Fprintf(&b, "<rect x=\"0\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", y, labelWidth+chartWidth, laneHeight,
+			[]string{"#ffffff", "#f5f5f5"}[laneIndex(lane)%2])
+		fmt.Fprintf(&b, "<text x=\"4\" y=\"%d\">%s</text>\n", y+laneHeight*2/3, lane)
+	}
+	tickMin := timelineTickMin(totalSec / 60)
+	for min := 0; min*60 <= totalSec; min += tickMin {
+		tx := x(min * 60)
+		fmt.Fprintf(&b, "<line x1=\"%.1f\" y1=\"%d\" x2=\"%.1f\" y2=\"%d\" stroke=\"#dddddd\"/>\n", tx, axisHeight, tx, height-axisHeight)
+		fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", tx, axisHeight-8, clock.At(min*60).UTC().Format("15:04"))
+		if slot, ok := clock.Slot(min * 60); ok {
+			fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">slot %d</text>\n", tx, height-axisHeight+16, slot)
+		}
+	}
+	for _, e := range events {
+		y := laneY(e.Lane)
+		color := timelineColors[e.Category]
+		tooltip := fmt.Sprintf("%s\n%s", e.Name, clock.At(e.StartSec).UTC().Format(time.RFC3339))
+		if slot, ok := clock.Slot(e.StartSec); ok {
+			tooltip += fmt.Sprintf(" (slot %d)", slot)
+		}
+		if e.DurationSec > 0 {
+			tooltip += fmt.Sprintf(", %s", formatDur(0, e.DurationSec))
+			fmt.Fprintf(&b, "<rect x=\"%.1f\" y=\"%d\" width=\"%.1f\" height=\"%d\" fill=\"%s\" stroke=\"#ffffff\"><title>%s</title></rect>\n",
+				x(e.StartSec), y+4, x(e.StartSec+e.DurationSec)-x(e.StartSec), laneHeight-8, color, html.EscapeString(tooltip))
+		} else {
+			cx, cy := x(e.StartSec), float64(y+laneHeight/2)
+			fmt.Fprintf(&b, "<path d=\"M%.1f %.1f l5 -8 l5 8 l-5 8 z\" fill=\"%s\"><title>%s</title></path>\n",
+				cx-5, cy, color, html.EscapeString(tooltip))
+		}
+	}
+	b.WriteString("</svg>\n</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,89 @@
+package itn_orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimelineClock(t *testing.T) {
+	genesis := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	clock := TimelineClock{Start: genesis.Add(time.Hour), GenesisTimestamp: genesis, SlotDurationMs: 180000}
+	slot, ok := clock.Slot(0)
+	require.True(t, ok)
+	require.Equal(t, 20, slot)
+	slot, _ = clock.Slot(179)
+	require.Equal(t, 20, slot)
+	slot, _ = clock.Slot(-3600 - 1)
+	require.Equal(t, -1, slot)
+	_, ok = TimelineClock{Start: genesis}.Slot(0)
+	require.False(t, ok)
+}
+
+func TestTimeline(t *testing.T) {
+	p := someParams()
+	p.Seed = 11
+	p.Rounds = 9
+	p.StopsPerRound = 2
+	p.Partitions = 2
+	p.PartitionMin = 5
+	p.RotationKeys = []string{"B62qpPita1s7Dbnr7MVb3UK8fdssZixL1a4536aeMYxbTJEtRGGyS8U"}
+	p.RotationServers = []string{"localhost:1"}
+	events := p.Plan().Timeline()
+	counts := map[string]int{}
+	for _, e := range events {
+		counts[e.Lane+"/"+e.Category]++
+		require.GreaterOrEqual(t, e.StartSec, 0)
+	}
+	require.Equal(t, map[string]int{
+		"funding/funding":      2,
+		"rounds/round":         9,
+		"rounds/pause":         8,
+		"rounds/large-pause":   1,
+		"rotation/rotation":    9,
+		"partitions/partition": 9,
+		// Max-cost rounds send only zkapps
+		"payments/load": 5,
+		"zkapps/load":   9,
+		"stops/stop":    18,
+	}, counts)
+
+	genesis := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	clock := TimelineClock{Start: genesis.Add(time.Hour), GenesisTimestamp: genesis, SlotDurationMs: 180000}
+	var trace bytes.Buffer
+	require.NoError(t, WriteTraceEvents(&trace, events, clock))
+	var decoded struct {
+		TraceEvents []struct {
+			Name string         `json:"name"`
+			Ph   string         `json:"ph"`
+			Ts   int64          `json:"ts"`
+			Dur  int64          `json:"dur"`
+			Tid  int            `json:"tid"`
+			Args map[string]any `json:"args"`
+		} `json:"traceEvents"`
+	}
+	require.NoError(t, json.Unmarshal(trace.Bytes(), &decoded))
+	require.Len(t, decoded.TraceEvents, len(timelineLanes)+len(events))
+	for _, e := range decoded.TraceEvents {
+		if e.Name == "Round 1" {
+			require.Equal(t, "X", e.Ph)
+			require.Equal(t, int64(60*60e6), e.Ts)
+			require.Equal(t, int64(50*60e6), e.Dur)
+			require.Equal(t, laneIndex("rounds"), e.Tid)
+			require.Equal(t, float64(40), e.Args["slot"])
+			require.Equal(t, "2026-10-01T02:00:00Z", e.Args["start"])
+		}
+	}
+
+	var page bytes.Buffer
+	require.NoError(t, WriteTimelineHTML(&page, "test <exp>", events, clock))
+	html := page.String()
+	require.Contains(t, html, "<title>test &lt;exp&gt;</title>")
+	require.Contains(t, html, ">slot 20</text>")
+	require.Equal(t, len(events)-counts["funding/funding"]-counts["rotation/rotation"]-counts["stops/stop"],
+		strings.Count(html, "<rect")-len(timelineLanes))
+}
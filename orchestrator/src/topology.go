@@ -0,0 +1,305 @@
+package itn_orchestrator
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TopologyJournalEntry records a single gating update request
+type TopologyJournalEntry struct {
+	Time    time.Time    `json:"time"`
+	Address NodeAddress  `json:"address"`
+	Update  GatingUpdate `json:"update"`
+	// Error of the request, the update isn't applied if set
+	Error string `json:"error,omitempty"`
+}
+
+// TopologySnapshot is the gating configuration of nodes.
+// Added peers of a configuration are all peers added since the added
+// peers were last cleaned (if ever, as indicated by cleanAddedPeers).
+type TopologySnapshot struct {
+	Time  time.Time                    `json:"time"`
+	Nodes map[NodeAddress]GatingUpdate `json:"nodes"`
+}
+
+// GatingTracker keeps the gating configuration applied to every node by the
+// orchestrator, along with configurations of partitioned nodes prior to
+// partitioning. Every gating update request is appended to the journal file,
+// if one is configured. Methods of a nil tracker behave as of an empty one.
+type GatingTracker struct {
+	mu          sync.Mutex
+	journalFile string
+	state       map[NodeAddress]GatingUpdate
+	partitioned map[NodeAddress]partitionedNode
+	now         func() time.Time
+}
+
+func NewGatingTracker(journalFile string) *GatingTracker {
+	return &GatingTracker{
+		journalFile: journalFile,
+		state:       map[NodeAddress]GatingUpdate{},
+		partitioned: map[NodeAddress]partitionedNode{},
+		now:         time.Now,
+	}
+}
+
+func openGating() GatingUpdate {
+	return GatingUpdate{
+		AddedPeers:      make([]NetworkPeer, 0),
+		Isolate:         false,
+		CleanAddedPeers: false,
+		BannedPeers:     make([]NetworkPeer, 0),
+		TrustedPeers:    make([]NetworkPeer, 0),
+	}
+}
+
+// applyGatingUpdate returns the configuration of a node after the update
+func applyGatingUpdate(state GatingUpdate, update GatingUpdate) GatingUpdate {
+	res := update
+	res.AddedPeers = append([]NetworkPeer{}, update.AddedPeers...)
+	if !update.CleanAddedPeers {
+		res.CleanAddedPeers = state.CleanAddedPeers
+		seen := map[NetworkPeer]struct{}{}
+		for _, p := range update.AddedPeers {
+			seen[p] = struct{}{}
+		}
+		for _, p := range state.AddedPeers {
+			if _, has := seen[p]; !has {
+				res.AddedPeers = append(res.AddedPeers, p)
+			}
+		}
+	}
+	return res
+}
+
+// Record journals a gating update request and, unless the request failed,
+// updates the node's configuration
+func (t *GatingTracker) Record(addr NodeAddress, update GatingUpdate, reqErr error) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry := TopologyJournalEntry{Time: t.now(), Address: addr, Update: update}
+	if reqErr != nil {
+		entry.Error = reqErr.Error()
+	} else {
+		state, has := t.state[addr]
+		if !has {
+			state = openGating()
+		}
+		t.state[addr] = applyGatingUpdate(state, update)
+	}
+	if t.journalFile == "" {
+		return nil
+	}
+	file, err := os.OpenFile(t.journalFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open topology journal %s: %v", t.journalFile, err)
+	}
+	if err := json.NewEncoder(file).Encode(entry); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write topology journal %s: %v", t.journalFile, err)
+	}
+	return file.Close()
+}
+
+// Last returns the gating configuration of the node, or
+// an unrestricted configuration if the node's gating wasn't updated
+func (t *GatingTracker) Last(addr NodeAddress) GatingUpdate {
+	if t != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if update, has := t.state[addr]; has {
+			return update
+		}
+	}
+	return openGating()
+}
+
+// Snapshot returns gating configuration of the given nodes,
+// or of all nodes with gating updated if no nodes are given
+func (t *GatingTracker) Snapshot(nodes []NodeAddress) TopologySnapshot {
+	res := TopologySnapshot{Time: time.Now(), Nodes: map[NodeAddress]GatingUpdate{}}
+	if t == nil {
+		return res
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res.Time = t.now()
+	if len(nodes) == 0 {
+		for addr, update := range t.state {
+			res.Nodes[addr] = update
+		}
+		return res
+	}
+	for _, addr := range nodes {
+		if update, has := t.state[addr]; has {
+			res.Nodes[addr] = update
+		} else {
+			res.Nodes[addr] = openGating()
+		}
+	}
+	return res
+}
+
+// ReplayTopologyJournal reconstructs gating configuration of nodes from
+// the journal, using entries recorded before the given time (all if zero)
+func ReplayTopologyJournal(r io.Reader, before time.Time) (TopologySnapshot, error) {
+	res := TopologySnapshot{Time: before, Nodes: map[NodeAddress]GatingUpdate{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var entry TopologyJournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return res, fmt.Errorf("failed to decode topology journal entry: %v", err)
+		}
+		if !before.IsZero() && !entry.Time.Before(before) {
+			break
+		}
+		res.Time = entry.Time
+		if entry.Error != "" {
+			continue
+		}
+		state, has := res.Nodes[entry.Address]
+		if !has {
+			state = openGating()
+		}
+		res.Nodes[entry.Address] = applyGatingUpdate(state, entry.Update)
+	}
+	return res, scanner.Err()
+}
+
+type SnapshotTopologyParams struct {
+	File string `json:"file"`
+	// Nodes to include into the snapshot, all nodes with gating updated by default
+	Nodes []NodeAddress `json:"nodes,omitempty"`
+}
+
+type SnapshotTopologyAction struct{}
+
+func (SnapshotTopologyAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	var params SnapshotTopologyParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	if params.File == "" {
+		return errors.New("no file specified")
+	}
+	snapshot := config.Gating.Snapshot(params.Nodes)
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(params.File, data, 0644); err != nil {
+		return fmt.Errorf("failed to write topology snapshot: %v", err)
+	}
+	config.Log.Infof("saved topology of %d nodes to %s", len(snapshot.Nodes), params.File)
+	return output("file", params.File, false, false)
+}
+
+func (SnapshotTopologyAction) Name() string { return "snapshot-topology" }
+
+func (SnapshotTopologyAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: SnapshotTopologyParams{}, Outputs: []OutputSpec{{Name: "file", Kind: StringKind}}}
+}
+
+var _ DeclaredAction = SnapshotTopologyAction{}
+
+type RestoreTopologyParams struct {
+	// Snapshot file written by snapshot-topology
+	File string `json:"file,omitempty"`
+	// Topology journal to reconstruct the configuration from, instead of a snapshot
+	Journal string `json:"journal,omitempty"`
+	// Only journal entries recorded before the time (RFC3339) are used, all entries by default
+	Before string `json:"before,omitempty"`
+	// Nodes to restore, all nodes of the snapshot by default.
+	// Nodes missing in the snapshot get an unrestricted configuration.
+	Nodes []NodeAddress `json:"nodes,omitempty"`
+}
+
+func loadTopologySnapshot(params RestoreTopologyParams) (TopologySnapshot, error) {
+	var snapshot TopologySnapshot
+	if (params.File == "") == (params.Journal == "") {
+		return snapshot, errors.New("exactly one of file and journal should be specified")
+	}
+	if params.Journal != "" {
+		file, err := os.Open(params.Journal)
+		if err != nil {
+			return snapshot, fmt.Errorf("failed to open topology journal: %v", err)
+		}
+		defer file.Close()
+		var before time.Time
+		if params.Before != "" {
+			before, err = time.Parse(time.RFC3339, params.Before)
+			if err != nil {
+				return snapshot, fmt.Errorf("invalid time %s: %v", params.Before, err)
+			}
+		}
+		return ReplayTopologyJournal(file, before)
+	}
+	data, err := os.ReadFile(params.File)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to read topology snapshot: %v", err)
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("failed to decode topology snapshot: %v", err)
+	}
+	return snapshot, nil
+}
+
+// RestoreTopology re-applies gating configuration of nodes from a snapshot or a journal.
+// Added peers of the snapshot replace the ones nodes have, as snapshots hold
+// the accumulated configuration of nodes.
+func RestoreTopology(config Config, params RestoreTopologyParams, output func(NodeAddress)) error {
+	snapshot, err := loadTopologySnapshot(params)
+	if err != nil {
+		return err
+	}
+	nodes := params.Nodes
+	if len(nodes) == 0 {
+		for addr := range snapshot.Nodes {
+			nodes = append(nodes, addr)
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+	}
+	for _, addr := range nodes {
+		update, has := snapshot.Nodes[addr]
+		if !has {
+			update = openGating()
+		}
+		update.CleanAddedPeers = true
+		if err := UpdateGatingGql(config, addr, update); err != nil {
+			return err
+		}
+		output(addr)
+	}
+	return nil
+}
+
+type RestoreTopologyAction struct{}
+
+func (RestoreTopologyAction) Run(config Config, rawParams json.RawMessage, output OutputF) error {
+	var params RestoreTopologyParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return err
+	}
+	return RestoreTopology(config, params, func(addr NodeAddress) {
+		output("node", addr, true, false)
+	})
+}
+
+func (RestoreTopologyAction) Name() string { return "restore-topology" }
+
+func (RestoreTopologyAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: RestoreTopologyParams{}, Outputs: []OutputSpec{{Name: "node", Kind: StringKind, Multi: true}}}
+}
+
+var _ DeclaredAction = RestoreTopologyAction{}
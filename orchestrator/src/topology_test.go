@@ -0,0 +1,103 @@
+package itn_orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopologyJournal(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "journal.jsonl")
+	tracker := NewGatingTracker(journal)
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	tracker.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	p1 := NetworkPeer{Host: "1.1.1.1", Libp2pPort: 1, PeerId: "p1"}
+	p2 := NetworkPeer{Host: "2.2.2.2", Libp2pPort: 2, PeerId: "p2"}
+	update := openGating()
+	update.AddedPeers = []NetworkPeer{p1}
+	require.NoError(t, tracker.Record("a:1", update, nil))
+	update.AddedPeers = []NetworkPeer{p2}
+	update.Isolate = true
+	update.TrustedPeers = []NetworkPeer{p2}
+	require.NoError(t, tracker.Record("a:1", update, nil))
+	// Failed requests are journaled, but not applied
+	require.NoError(t, tracker.Record("a:1", openGating(), errors.New("failed")))
+
+	expected := GatingUpdate{
+		AddedPeers:   []NetworkPeer{p2, p1},
+		Isolate:      true,
+		BannedPeers:  []NetworkPeer{},
+		TrustedPeers: []NetworkPeer{p2},
+	}
+	require.Equal(t, expected, tracker.Last("a:1"))
+	require.Equal(t, map[NodeAddress]GatingUpdate{"a:1": expected}, tracker.Snapshot(nil).Nodes)
+	require.Equal(t, map[NodeAddress]GatingUpdate{"b:1": openGating()}, tracker.Snapshot([]NodeAddress{"b:1"}).Nodes)
+
+	file, err := os.Open(journal)
+	require.NoError(t, err)
+	defer file.Close()
+	snapshot, err := ReplayTopologyJournal(file, time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, map[NodeAddress]GatingUpdate{"a:1": expected}, snapshot.Nodes)
+
+	_, err = file.Seek(0, 0)
+	require.NoError(t, err)
+	snapshot, err = ReplayTopologyJournal(file, start.Add(2*time.Minute))
+	require.NoError(t, err)
+	first := openGating()
+	first.AddedPeers = []NetworkPeer{p1}
+	require.Equal(t, map[NodeAddress]GatingUpdate{"a:1": first}, snapshot.Nodes)
+}
+
+func TestSnapshotAndRestoreTopology(t *testing.T) {
+	config, nodes, peers := startPartitionNodes(t, 2)
+	journal := filepath.Join(t.TempDir(), "journal.jsonl")
+	config.Gating = NewGatingTracker(journal)
+	addrs := make([]NodeAddress, 0, len(nodes))
+	for addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	before := time.Now().Add(-time.Second)
+	require.NoError(t, Isolate(config, IsolateParams{Nodes: addrs}))
+	isolated := map[NodeAddress]GatingUpdate{}
+	for _, addr := range addrs {
+		isolated[addr] = lastGatingUpdate(t, nodes[addr])
+	}
+	snapshotFile := filepath.Join(t.TempDir(), "topology.json")
+	addrsJson, err := json.Marshal(addrs)
+	require.NoError(t, err)
+	outCache := EmptyOutputCache()
+	runMockScript(t, config, outCache, 0,
+		`{"action":"snapshot-topology","params":{"file":"`+snapshotFile+`"}}`,
+		`{"action":"reset-gating","params":{"nodes":`+string(addrsJson)+`}}`,
+		`{"action":"restore-topology","params":{"file":{"type":"output","step":0,"name":"file"}}}`,
+	)
+	require.ElementsMatch(t, addrs, outputValues[NodeAddress](t, outCache, 2, "node"))
+	for _, addr := range addrs {
+		update := lastGatingUpdate(t, nodes[addr])
+		require.True(t, update.Isolate)
+		require.Equal(t, isolated[addr].TrustedPeers, update.TrustedPeers)
+		require.True(t, update.CleanAddedPeers)
+		require.ElementsMatch(t, peersOf(peers, addrs...), append(update.AddedPeers, peers[addr]))
+	}
+
+	// Topology before the experiment is restored from the journal
+	require.NoError(t, RestoreTopology(config, RestoreTopologyParams{Journal: journal, Before: before.Format(time.RFC3339), Nodes: addrs}, func(NodeAddress) {}))
+	restored := openGating()
+	restored.CleanAddedPeers = true
+	for _, addr := range addrs {
+		require.Equal(t, restored, lastGatingUpdate(t, nodes[addr]))
+	}
+
+	err = RestoreTopology(config, RestoreTopologyParams{File: snapshotFile, Journal: journal}, func(NodeAddress) {})
+	require.Error(t, err)
+}
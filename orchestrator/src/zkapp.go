@@ -35,6 +35,8 @@ type ZkappCommandParams struct {
 type ScheduledZkappCommandsReceipt struct {
 	Address NodeAddress `json:"address"`
 	Handle  string      `json:"handle"`
+	// Kind of scheduled transactions, as labeled in metrics
+	Kind string `json:"kind,omitempty"`
 }
 
 func tpsGap(tps float64, gapSec int) int {
@@ -58,7 +60,9 @@ func ZkappBalanceRequirements(tps float64, p ZkappSubParams) (int, uint64, uint6
 	return newAccounts, minNewZkappBalance, maxNewZkappBalance, initBalance
 }
 
-func ZkappKeygenRequirements(initZkappBalance uint64, params ZkappSubParams) (int, uint64) {
+// ZkappKeygenRequirements returns the number of keys and the amount to fund them with for sending
+// zkapp commands with the params, zkapps are deployed anew by each of the deployments (e.g. load steps)
+func ZkappKeygenRequirements(initZkappBalance uint64, params ZkappSubParams, deployments int) (int, uint64) {
 	maxParticipants := int(math.Ceil(params.Tps / params.MinTps))
 	minTpsGap := tpsGap(params.MinTps, params.Gap)
 	// Minimal number of keys allocated per node
@@ -69,7 +73,7 @@ func ZkappKeygenRequirements(initZkappBalance uint64, params ZkappSubParams) (in
 	keys := maxParticipants + tpsGap*2
 	txCost := params.MaxBalanceChange*8 + params.MaxFee
 	totalTxs := uint64(math.Ceil(float64(params.DurationMin) * 60 * params.Tps))
-	balance := uint64(keys)*zkappsToDeployPerKey*(initZkappBalance+params.DeploymentFee)*2 + 3*txCost*totalTxs
+	balance := uint64(deployments)*uint64(keys)*zkappsToDeployPerKey*(initZkappBalance+params.DeploymentFee)*2 + 3*txCost*totalTxs
 	return keys, balance
 }
 
@@ -103,6 +107,7 @@ func scheduleZkappCommandsDo(config Config, params ZkappCommandParams, nodeAddre
 	handle, err := ScheduleZkappCommands(config, nodeAddress, paymentInput)
 	if err == nil {
 		config.Log.Infof("scheduled zkapp batch %d with tps %f for %s: %s", batchIx, tps, nodeAddress, handle)
+		observeScheduled(nodeAddress, "zkapp", tps)
 	}
 	return handle, err
 }
@@ -123,49 +128,17 @@ func SendZkappCommands(config Config, params ZkappCommandParams, output func(Sch
 	if len(params.Nodes) == 0 {
 		return errors.New("no nodes specified")
 	}
-	tps, nodes := selectNodes(params.Tps, params.MinTps, params.Nodes)
-	feePayersPerNode := len(params.FeePayers) / len(nodes)
-	successfulNodes := make([]NodeAddress, 0, len(nodes))
-	remTps := params.Tps
-	remFeePayers := params.FeePayers
-	var err error
-	for nodeIx, nodeAddress := range nodes {
-		feePayers := remFeePayers[:feePayersPerNode]
-		var handle string
-		handle, err = scheduleZkappCommandsDo(config, params, nodeAddress, len(successfulNodes), tps, feePayers)
-		if err != nil {
-			config.Log.Warnf("error scheduling zkapp txs for %s: %v", nodeAddress, err)
-			n := len(nodes) - nodeIx - 1
-			if n > 0 {
-				tps = remTps / float64(n)
-				feePayersPerNode = len(remFeePayers) / n
-			}
-			continue
-		}
-		successfulNodes = append(successfulNodes, nodeAddress)
-		remFeePayers = remFeePayers[feePayersPerNode:]
-		remTps -= tps
-		output(ScheduledZkappCommandsReceipt{
-			Address: nodeAddress,
-			Handle:  handle,
-		})
+	schedule := func(config Config, nodeAddress NodeAddress, batchIx int, tps float64, feePayers []itn_json_types.MinaPrivateKey) (string, error) {
+		return scheduleZkappCommandsDo(config, params, nodeAddress, batchIx, tps, feePayers)
 	}
-	if err != nil {
-		// last schedule payment request didn't work well
-		for _, nodeAddress := range successfulNodes {
-			handle, err2 := scheduleZkappCommandsDo(config, params, nodeAddress, len(successfulNodes), tps, remFeePayers)
-			if err2 != nil {
-				config.Log.Warnf("error scheduling second batch of zkapp txs for %s: %v", nodeAddress, err2)
-				continue
-			}
+	return scheduleOnNodes(config, "zkapp txs", params.Tps, params.MinTps, params.Nodes, params.FeePayers, schedule,
+		func(nodeAddress NodeAddress, handle string) {
 			output(ScheduledZkappCommandsReceipt{
 				Address: nodeAddress,
 				Handle:  handle,
+				Kind:    "zkapp",
 			})
-			return nil
-		}
-	}
-	return err
+		})
 }
 
 type ZkappCommandsAction struct{}
@@ -176,6 +149,7 @@ func (ZkappCommandsAction) Run(config Config, rawParams json.RawMessage, output
 		return err
 	}
 	return SendZkappCommands(config, params, func(receipt ScheduledZkappCommandsReceipt) {
+		config.Receipts.Add(ScheduledPaymentsReceipt(receipt))
 		output("receipt", receipt, true, false)
 		output("participant", receipt.Address, true, false)
 	})
@@ -183,4 +157,11 @@ func (ZkappCommandsAction) Run(config Config, rawParams json.RawMessage, output
 
 func (ZkappCommandsAction) Name() string { return "zkapp-txs" }
 
-var _ Action = ZkappCommandsAction{}
+func (ZkappCommandsAction) Schema(RawParams) ActionSchema {
+	return ActionSchema{Params: ZkappCommandParams{}, Outputs: []OutputSpec{
+		{Name: "receipt", Kind: ObjectKind, Multi: true},
+		{Name: "participant", Kind: StringKind, Multi: true},
+	}}
+}
+
+var _ DeclaredAction = ZkappCommandsAction{}